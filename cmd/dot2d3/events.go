@@ -0,0 +1,135 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// liveEvents fans HTML conversions out to the preview pages watching the
+// same session, so a preview follows conversions posted from the form or
+// from other tools (e.g. an editor's save hook).
+var liveEvents = newEventBroker()
+
+// eventBroker delivers messages to the subscribers of a session.
+type eventBroker struct {
+	mu   sync.Mutex
+	subs map[string]map[chan []byte]struct{}
+}
+
+func newEventBroker() *eventBroker {
+	return &eventBroker{subs: make(map[string]map[chan []byte]struct{})}
+}
+
+// subscribe adds a subscriber to session. Unless create is set, the
+// session must already have subscribers, so clients can only join
+// sessions the server issued; ok reports whether it did.
+func (b *eventBroker) subscribe(session string, create bool) (ch chan []byte, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subs[session] == nil {
+		if !create {
+			return nil, false
+		}
+		b.subs[session] = make(map[chan []byte]struct{})
+	}
+	ch = make(chan []byte, 4)
+	b.subs[session][ch] = struct{}{}
+	return ch, true
+}
+
+// newSessionID returns an unguessable session ID, since knowing one is
+// enough to read and push a session's conversions.
+func newSessionID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+func (b *eventBroker) unsubscribe(session string, ch chan []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.subs[session], ch)
+	if len(b.subs[session]) == 0 {
+		delete(b.subs, session)
+	}
+}
+
+// publish sends msg to every subscriber of session. Subscribers that are
+// behind skip the message rather than stall the publisher; only the
+// latest conversion matters to a preview.
+func (b *eventBroker) publish(session string, msg []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[session] {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+}
+
+// ConvertEvent is the data of a "convert" server-sent event.
+type ConvertEvent struct {
+	HTML string `json:"html"`
+}
+
+// publishConversion sends rendered HTML to the session's /events streams.
+func publishConversion(session string, html []byte) {
+	msg, err := json.Marshal(ConvertEvent{HTML: string(html)})
+	if err != nil {
+		return
+	}
+	liveEvents.publish(session, msg)
+}
+
+// handleEvents streams a session's conversions as server-sent events:
+// one "convert" event, with a ConvertEvent as data, per conversion posted
+// to /convert?session=<id>. Without a session parameter the server starts
+// a new session, whose ID comes first as the data of a "session" event;
+// with one, the stream joins that session, which must be live.
+func handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming is not supported.", http.StatusInternalServerError)
+		return
+	}
+
+	session := r.URL.Query().Get("session")
+	create := session == ""
+	if create {
+		var err error
+		if session, err = newSessionID(); err != nil {
+			http.Error(w, "Failed to start a session: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	ch, ok := liveEvents.subscribe(session, create)
+	if !ok {
+		http.Error(w, "Unknown session.", http.StatusNotFound)
+		return
+	}
+	defer liveEvents.unsubscribe(session, ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	fmt.Fprint(w, ": connected\n\n")
+	fmt.Fprintf(w, "event: session\ndata: %s\n\n", session)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-ch:
+			fmt.Fprintf(w, "event: convert\ndata: %s\n\n", msg)
+			flusher.Flush()
+		}
+	}
+}
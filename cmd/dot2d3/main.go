@@ -2,24 +2,46 @@
 package main
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/d3"
 	"github.com/anthonybishopric/dot2d3/pkg/dot"
 )
 
 var (
 	outputFile = flag.String("o", "", "Output file (default: stdout)")
 	title      = flag.String("t", "", "HTML page title (default: graph ID or 'Graph Visualization')")
+	titleAttr  = flag.Bool("title-from-attr", false, "Use the graph's label attribute as the title when -t is not set")
 	jsonOnly   = flag.Bool("json", false, "Output only JSON data (no HTML)")
+	format     = flag.String("format", "html", "Output format: html, json, jsonl (a record per node and edge), mermaid, or graphml")
+	meta       = flag.Bool("meta", false, "Include graph statistics under \"meta\" in JSON output")
+	roots      = flag.String("roots", "", "Comma-separated nodes: keep only them and what is reachable from them (HTML and JSON output)")
+	pathFile   = flag.String("path", "", "DOT file of edges to highlight as a path (HTML output); fails if the path is invalid")
+	mainOnly   = flag.Bool("main-component", false, "Render only the largest connected component (HTML output)")
+	werror     = flag.Bool("Werror", false, "Treat validation warnings as errors")
+	astOnly    = flag.Bool("ast", false, "Output the parsed syntax tree as JSON, with source positions")
+	openOutput = flag.Bool("open", false, "Open the HTML in the default browser (written to a temp file unless -o is set)")
 	serve      = flag.String("serve", "", "Start HTTP server on specified address (e.g., ':8080' or 'localhost:8080')")
+	timeout    = flag.Duration("timeout", 30*time.Second, "Time limit for fetching an http:// or https:// input")
 	help       = flag.Bool("h", false, "Show help")
+
+	convertTimeout = flag.Duration("convert-timeout", 10*time.Second, "Server mode: time limit for parsing, converting and rendering each request's graph")
 )
 
 func main() {
@@ -29,7 +51,8 @@ func main() {
 Usage:
   dot2d3 [options] [input.dot]
 
-If no input file is specified, reads from stdin.
+If no input file is specified, reads from stdin. An http:// or https://
+input is fetched.
 
 Options:
 `)
@@ -40,6 +63,17 @@ Examples:
   dot2d3 -o output.html graph.dot
   dot2d3 -t "My Graph" -o output.html graph.dot
   dot2d3 --json graph.dot > graph.json
+  dot2d3 --json -meta graph.dot > graph.json
+  dot2d3 -roots A,B -o output.html graph.dot
+  dot2d3 -path path.dot -o output.html graph.dot
+  dot2d3 -main-component -o output.html graph.dot
+  dot2d3 -format=mermaid graph.dot > graph.mmd
+  dot2d3 -format=jsonl graph.dot > graph.jsonl
+  dot2d3 -format=graphml graph.dot > graph.graphml
+  dot2d3 -Werror -o output.html graph.dot
+  dot2d3 -ast graph.dot > ast.json
+  dot2d3 -open graph.dot
+  dot2d3 -timeout 5s https://example.com/graph.dot > output.html
   echo 'digraph { A -> B -> C }' | dot2d3 > quick.html
 
 Server mode:
@@ -80,6 +114,12 @@ func runServer(addr string) {
 	// POST /convert - accepts DOT in body, returns HTML (or JSON with ?format=json)
 	mux.HandleFunc("POST /convert", handleConvert)
 
+	// POST /validate - checks a path against a graph, returns only the result
+	mux.HandleFunc("POST /validate", handleValidate)
+
+	// GET /events[?session=<id>] - server-sent events for live preview
+	mux.HandleFunc("GET /events", handleEvents)
+
 	// GET / - simple health/info endpoint
 	mux.HandleFunc("GET /", handleIndex)
 
@@ -321,7 +361,25 @@ POST /convert
   JSON body: {"graph": "...", "path": "..."}
   Query params:
     format=json  - Return JSON instead of HTML
+    format=matrix - Return {"nodes": [...], "matrix": [[...]]} edge counts,
+                   for graphs of up to 2000 nodes
     title=...    - Set the page title
+    from=A&to=B  - Highlight up to 100 paths from A to B, each in its own color
+    maxlen=N     - With from/to, only paths of at most N edges (12 at most)
+    image=png|jpeg|webp - Add export buttons, exporting images in this format
+    quality=Q    - With image=jpeg or webp, the encoding quality from 0 to 1
+    session=...  - Also send the HTML to that session's GET /events streams
+
+POST /validate
+  JSON body: {"graph": "...", "path": "..."}
+  Returns the path validation result only, without rendering
+
+GET /events
+  Starts a session, sent first as a "session" event with the ID as
+  data, then server-sent "convert" events, data {"html": "..."}, for
+  each HTML conversion posted with that session; ?session=... joins
+  a live session instead
+  This page's session: <span id="live-session"></span>
 
 Examples:
   curl -X POST -H "Content-Type: application/json" \
@@ -492,8 +550,54 @@ document.querySelector('textarea[name="path"]').addEventListener('input', clearH
 // Load history on page load
 renderHistory();
 
+// Live preview: conversions posted by other tools with this tab's
+// ?session= arrive as server-sent events. The server picks the session,
+// and a new one on each reconnect; the tab's own conversions are shown
+// from their responses, so they aren't posted to the session
+let previewHTML = null;
+
+function showPreview(html) {
+    if (html === previewHTML) return;
+    previewHTML = html;
+    document.getElementById('preview').srcdoc = html;
+}
+
+function showPreviewError(html) {
+    previewHTML = null;
+    document.getElementById('preview').srcdoc = html;
+}
+
+if (window.EventSource) {
+    const events = new EventSource('/events');
+    events.addEventListener('session', function(e) {
+        document.getElementById('live-session').textContent = e.data;
+    });
+    events.addEventListener('convert', function(e) {
+        showPreview(JSON.parse(e.data).html);
+    });
+}
+
+// Convert as you type, once typing pauses; only explicit conversions are
+// saved to history
+let autoConvertTimer = null;
+['graph', 'path'].forEach(function(name) {
+    document.querySelector('textarea[name="' + name + '"]').addEventListener('input', function() {
+        clearTimeout(autoConvertTimer);
+        autoConvertTimer = setTimeout(function() {
+            if (document.querySelector('textarea[name="graph"]').value.trim()) {
+                convert(false);
+            }
+        }, 600);
+    });
+});
+
 document.querySelector('form').addEventListener('submit', function(e) {
     e.preventDefault();
+    clearTimeout(autoConvertTimer);
+    convert(true);
+});
+
+function convert(saveToHistory) {
     const graphDOT = document.querySelector('textarea[name="graph"]').value;
     const pathDOT = document.querySelector('textarea[name="path"]').value;
 
@@ -514,29 +618,29 @@ document.querySelector('form').addEventListener('submit', function(e) {
         return r.text().then(html => ({ isError: false, html }));
     })
     .then(result => {
-        const iframe = document.getElementById('preview');
         if (result.isError) {
             const err = result.data;
-            iframe.srcdoc = '<div style="padding:20px;font-family:sans-serif;">' +
+            showPreviewError('<div style="padding:20px;font-family:sans-serif;">' +
                 '<h2 style="color:#c62828;margin-top:0;">Path Validation Error</h2>' +
                 '<p><strong>Error:</strong> ' + err.error + '</p>' +
                 (err.lastValidNode ? '<p><strong>Last valid node:</strong> ' + err.lastValidNode + '</p>' : '') +
-                '</div>';
+                '</div>');
         } else {
-            iframe.srcdoc = result.html;
+            showPreview(result.html);
             // Save to history on successful conversion (but not when restoring from history)
-            if (!isRestoringFromHistory) {
+            if (saveToHistory && !isRestoringFromHistory) {
                 addToHistory(graphDOT, pathDOT, isFromSharedLink);
             }
-            isRestoringFromHistory = false;
-            isFromSharedLink = false;
+            if (saveToHistory) {
+                isRestoringFromHistory = false;
+                isFromSharedLink = false;
+            }
         }
     })
     .catch(err => {
-        const iframe = document.getElementById('preview');
-        iframe.srcdoc = '<div style="padding:20px;color:#c62828;">' + err.message + '</div>';
+        showPreviewError('<div style="padding:20px;color:#c62828;">' + err.message + '</div>');
     });
-});
+}
 
 // Pre-populate form from URL params (for when viewing shared link editor)
 // Supports both old base64 format and new LZ-String compressed format
@@ -663,6 +767,13 @@ type ConvertRequest struct {
 	Path  string `json:"path,omitempty"`
 }
 
+// ConvertResponse is the JSON envelope returned by /convert?include=validation.
+// Validation is null when no path was supplied.
+type ConvertResponse struct {
+	HTML       string                    `json:"html"`
+	Validation *dot.PathValidationResult `json:"validation"`
+}
+
 // ConvertError is the JSON error response for path validation failures.
 type ConvertError struct {
 	Error         string                    `json:"error"`
@@ -672,9 +783,9 @@ type ConvertError struct {
 
 func handleConvert(w http.ResponseWriter, r *http.Request) {
 	// Read request body
-	body, err := io.ReadAll(r.Body)
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
 	if err != nil {
-		http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+		requestBodyError(w, err)
 		return
 	}
 	defer r.Body.Close()
@@ -708,8 +819,25 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Parsing, conversion and output share one deadline; the parser and
+	// converter stop working once it passes
+	ctx, cancel := context.WithTimeout(r.Context(), *convertTimeout)
+	defer cancel()
+
 	// Parse main graph DOT
-	graph, err := dot.Parse("request", []byte(graphDOT))
+	parseStart := time.Now()
+	hook := convertHook
+	graph, err := withDeadline(ctx, func() (*ast.Graph, error) {
+		if hook != nil {
+			hook()
+		}
+		return dot.ParseContext(ctx, "request", []byte(graphDOT))
+	})
+	parseDuration := time.Since(parseStart)
+	if ctx.Err() != nil {
+		convertTimedOut(w)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to parse graph DOT: "+err.Error(), http.StatusBadRequest)
 		return
@@ -717,11 +845,16 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 
 	// Build render options
 	opts := dot.RenderOptions{
-		Title: r.URL.Query().Get("title"),
+		Title:            r.URL.Query().Get("title"),
+		InlineImagesOnly: true, // never reference files on the server
 	}
 
 	if pathDOT != "" {
-		pathAST, err := dot.Parse("path", []byte(pathDOT))
+		pathAST, err := dot.ParseContext(ctx, "path", []byte(pathDOT))
+		if ctx.Err() != nil {
+			convertTimedOut(w)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to parse path DOT: "+err.Error(), http.StatusBadRequest)
 			return
@@ -729,15 +862,99 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 		opts.PathAST = pathAST
 	}
 
+	// ?from=A&to=B highlights the paths between two nodes of at most
+	// ?maxlen=N edges, which is clamped to maxServerPathLength so a small
+	// request can't ask for every path through a large graph
+	opts.PathFrom, opts.PathTo = r.URL.Query().Get("from"), r.URL.Query().Get("to")
+	if (opts.PathFrom == "") != (opts.PathTo == "") {
+		http.Error(w, "Both from and to are needed to highlight paths.", http.StatusBadRequest)
+		return
+	}
+	opts.MaxPathLength = maxServerPathLength
+	if maxLen := r.URL.Query().Get("maxlen"); maxLen != "" {
+		n, err := strconv.Atoi(maxLen)
+		if err != nil || n < 0 {
+			http.Error(w, "maxlen must be a non-negative integer.", http.StatusBadRequest)
+			return
+		}
+		if n > 0 && n < maxServerPathLength {
+			opts.MaxPathLength = n
+		}
+	}
+
+	// ?image=jpeg adds the export buttons, with the browser encoding the
+	// exported image as jpeg, optionally at ?quality=0.8
+	if image := r.URL.Query().Get("image"); image != "" {
+		opts.ShowExport = true
+		opts.ImageFormat = image
+	}
+	if quality := r.URL.Query().Get("quality"); quality != "" {
+		q, err := strconv.ParseFloat(quality, 64)
+		if err != nil {
+			http.Error(w, "quality must be a number from 0 to 1.", http.StatusBadRequest)
+			return
+		}
+		opts.ImageQuality = q
+	}
+	if err := d3.ValidateImageOptions(opts); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	// Check query params for output format
 	format := r.URL.Query().Get("format")
+	switch format {
+	case "png", "jpeg", "webp":
+		// Images are only drawn in the browser, from the laid-out page
+		http.Error(w, "The server doesn't rasterize graphs; use image="+format+" to export from the rendered page.", http.StatusNotImplemented)
+		return
+	}
 
 	// Generate output
-	var output []byte
+	var output, html []byte
 	var outputContentType string
 
-	if format == "json" {
-		output, err = dot.ToJSON(graph)
+	renderStart := time.Now()
+	d3g, err := withDeadline(ctx, func() (*d3.Graph, error) {
+		return dot.ToD3GraphContext(ctx, graph)
+	})
+	if ctx.Err() != nil {
+		convertTimedOut(w)
+		return
+	}
+	if errors.Is(err, d3.ErrLimitExceeded) {
+		http.Error(w, "Graph is too large: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to convert graph: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, id := range []string{opts.PathFrom, opts.PathTo} {
+		if id != "" && !slices.ContainsFunc(d3g.Nodes, func(n d3.Node) bool { return n.ID == id }) {
+			http.Error(w, fmt.Sprintf("Node %q is not in the graph.", id), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if format == "matrix" && len(d3g.Nodes) > maxMatrixNodes {
+		http.Error(w, fmt.Sprintf("Graph is too large for a matrix: more than %d nodes.", maxMatrixNodes), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if format == "json" || format == "matrix" {
+		// The matrix grows with the square of the node count, so it is
+		// written without indentation; both are built under the deadline
+		output, err = withDeadline(ctx, func() ([]byte, error) {
+			if format == "matrix" {
+				return json.Marshal(d3g.AdjacencyMatrix())
+			}
+			return json.MarshalIndent(d3g, "", "  ")
+		})
+		if ctx.Err() != nil {
+			convertTimedOut(w)
+			return
+		}
 		outputContentType = "application/json"
 		if err != nil {
 			http.Error(w, "Failed to generate JSON: "+err.Error(), http.StatusInternalServerError)
@@ -745,8 +962,22 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		// Generate HTML with path validation
-		var pathResult *dot.PathValidationResult
-		output, pathResult, err = dot.ToHTMLWithValidation(graph, opts)
+		// Highlighting ?from= to ?to= searches paths, so it runs under the
+		// same deadline as the conversion
+		type rendered struct {
+			html       []byte
+			pathResult *dot.PathValidationResult
+		}
+		res, err := withDeadline(ctx, func() (rendered, error) {
+			html, pathResult, err := d3.RenderHTMLWithValidation(d3g, opts)
+			return rendered{html, pathResult}, err
+		})
+		if ctx.Err() != nil {
+			convertTimedOut(w)
+			return
+		}
+		output, html = res.html, res.html
+		pathResult := res.pathResult
 		outputContentType = "text/html; charset=utf-8"
 
 		if err != nil {
@@ -754,8 +985,17 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		// If path validation failed, return JSON error
-		if pathResult != nil && !pathResult.Valid {
+		// Clients asking for the validation result get it alongside the
+		// HTML, whether or not the path was valid
+		if r.URL.Query().Get("include") == "validation" {
+			output, err = json.Marshal(ConvertResponse{HTML: string(output), Validation: pathResult})
+			if err != nil {
+				http.Error(w, "Failed to generate JSON: "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+			outputContentType = "application/json"
+		} else if pathResult != nil && !pathResult.Valid {
+			// If path validation failed, return JSON error
 			w.Header().Set("Content-Type", "application/json")
 			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(pathResult)
@@ -763,10 +1003,151 @@ func handleConvert(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	renderDuration := time.Since(renderStart)
+
+	// Live previews watching this session show the new rendering
+	if session := r.URL.Query().Get("session"); session != "" && html != nil {
+		publishConversion(session, html)
+	}
+
+	setMetricsHeaders(w, parseDuration, renderDuration, d3g)
 	w.Header().Set("Content-Type", outputContentType)
 	w.Write(output)
 }
 
+// maxRequestSize is the largest request body /convert and /validate read.
+const maxRequestSize = 10 << 20
+
+// requestBodyError answers a request whose body couldn't be read, with 413
+// for one over maxRequestSize.
+func requestBodyError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Request body is larger than %d bytes.", maxRequestSize), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, "Failed to read request body: "+err.Error(), http.StatusBadRequest)
+}
+
+// maxMatrixNodes is the most nodes the server writes a ?format=matrix
+// response for; the matrix has a cell for every pair.
+const maxMatrixNodes = 2000
+
+// maxServerPathLength bounds the edges in each path the server highlights
+// for ?from= and ?to=, and is used when ?maxlen= is absent or larger.
+const maxServerPathLength = 12
+
+// convertHook, when set, runs before each conversion's parse; tests use it
+// to make conversions slow.
+var convertHook func()
+
+// withDeadline runs f, returning early with ctx's error if ctx is done
+// first. f keeps running in the background with its result dropped, so
+// long-running work in f should stop on ctx itself.
+func withDeadline[T any](ctx context.Context, f func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := f()
+		done <- result{v, err}
+	}()
+	select {
+	case res := <-done:
+		return res.v, res.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+// convertTimedOut answers a conversion that ran past -convert-timeout.
+func convertTimedOut(w http.ResponseWriter) {
+	http.Error(w, fmt.Sprintf("Conversion took longer than %s; try a smaller graph.", *convertTimeout), http.StatusServiceUnavailable)
+}
+
+// handleValidate checks a path against a graph without rendering either,
+// for validating a path as it is typed. The body is a ConvertRequest with
+// both fields set; the response is the PathValidationResult, with status
+// 200 whether or not the path is valid.
+func handleValidate(w http.ResponseWriter, r *http.Request) {
+	var req ConvertRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestSize)).Decode(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			requestBodyError(w, err)
+			return
+		}
+		http.Error(w, "Failed to parse JSON request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Graph == "" {
+		http.Error(w, "Graph DOT content is empty.", http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "Path DOT content is empty.", http.StatusBadRequest)
+		return
+	}
+
+	// Validation parses and converts the graph like /convert, under the
+	// same deadline
+	ctx, cancel := context.WithTimeout(r.Context(), *convertTimeout)
+	defer cancel()
+
+	graph, err := dot.ParseContext(ctx, "request", []byte(req.Graph))
+	if ctx.Err() != nil {
+		convertTimedOut(w)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to parse graph DOT: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	pathAST, err := dot.ParseContext(ctx, "path", []byte(req.Path))
+	if ctx.Err() != nil {
+		convertTimedOut(w)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to parse path DOT: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	d3g, err := dot.ToD3GraphContext(ctx, graph)
+	if ctx.Err() != nil {
+		convertTimedOut(w)
+		return
+	}
+	if errors.Is(err, d3.ErrLimitExceeded) {
+		http.Error(w, "Graph is too large: "+err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to convert graph: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(d3.ApplyPathHighlighting(d3g, pathAST))
+}
+
+// setMetricsHeaders reports conversion cost so clients can monitor it
+// without instrumenting their own requests. Durations are in milliseconds.
+func setMetricsHeaders(w http.ResponseWriter, parse, render time.Duration, g *d3.Graph) {
+	h := w.Header()
+	h.Set("X-Parse-Duration", formatMillis(parse))
+	h.Set("X-Render-Duration", formatMillis(render))
+	h.Set("X-Node-Count", strconv.Itoa(len(g.Nodes)))
+	h.Set("X-Edge-Count", strconv.Itoa(len(g.Links)))
+}
+
+func formatMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
 func runCLI() {
 	var input []byte
 	var filename string
@@ -779,7 +1160,7 @@ func runCLI() {
 		filename = "<stdin>"
 	} else {
 		filename = args[0]
-		input, err = os.ReadFile(filename)
+		input, err = readInput(filename, *timeout)
 	}
 
 	if err != nil {
@@ -794,15 +1175,53 @@ func runCLI() {
 		os.Exit(1)
 	}
 
-	// Generate output
+	if err := reportWarnings(os.Stderr, dot.Validate(graph), *werror); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if ignored := dot.IgnoredAttributes(graph); len(ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "note: ignored attributes: %s\n", strings.Join(ignored, ", "))
+	}
+
+	// Generate output. writeOutput is set instead of output for formats
+	// written as they are encoded
 	var output []byte
+	var writeOutput func(io.Writer) error
 	if *jsonOnly {
-		output, err = dot.ToJSON(graph)
-	} else {
+		*format = "json"
+	}
+	switch {
+	case *pathFile != "" && (*roots != "" || *astOnly || *format != "html"):
+		err = fmt.Errorf("-path needs HTML output and can't be combined with -roots")
+	case *mainOnly && (*astOnly || *format != "html"):
+		err = fmt.Errorf("-main-component needs HTML output")
+	case *roots != "" && (*astOnly || (*format != "json" && *format != "html")):
+		err = fmt.Errorf("-roots needs HTML or JSON output")
+	case *roots != "":
+		output, err = renderReachable(graph, splitList(*roots))
+	case *astOnly:
+		output, err = json.MarshalIndent(graph, "", "  ")
+	case *format == "json":
+		output, err = dot.ToJSONWithOptions(graph, dot.ConvertOptions{Meta: *meta})
+	case *format == "jsonl":
+		writeOutput = func(w io.Writer) error { return dot.WriteJSONL(w, graph) }
+	case *format == "mermaid":
+		output, err = dot.ToMermaid(graph)
+	case *format == "graphml":
+		output, err = dot.ToGraphML(graph)
+	case *format == "html":
 		opts := dot.RenderOptions{
-			Title: *title,
+			Title:             *title,
+			TitleFromLabel:    *titleAttr,
+			MainComponentOnly: *mainOnly,
 		}
-		output, err = dot.ToHTML(graph, opts)
+		if *pathFile != "" {
+			output, err = renderPath(graph, *pathFile, opts)
+		} else {
+			output, err = dot.ToHTML(graph, opts)
+		}
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
 	}
 
 	if err != nil {
@@ -810,14 +1229,199 @@ func runCLI() {
 		os.Exit(1)
 	}
 
+	if *openOutput {
+		if *astOnly || *format != "html" {
+			fmt.Fprintf(os.Stderr, "Error: -open needs HTML output\n")
+			os.Exit(1)
+		}
+		path, err := openHTML(output, *outputFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening output: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stderr, "Opened %s\n", path)
+		return
+	}
+
 	// Write output
+	if writeOutput == nil {
+		writeOutput = func(w io.Writer) error {
+			_, err := w.Write(output)
+			return err
+		}
+	}
 	if *outputFile == "" {
-		fmt.Print(string(output))
+		w := bufio.NewWriter(os.Stdout)
+		if err := writeOutput(w); err != nil {
+			fmt.Fprintf(os.Stderr, "Error generating output: %v\n", err)
+			os.Exit(1)
+		}
+		if err := w.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			os.Exit(1)
+		}
 	} else {
-		if err := os.WriteFile(*outputFile, output, 0644); err != nil {
+		if err := writeFile(*outputFile, writeOutput); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
 			os.Exit(1)
 		}
 		fmt.Fprintf(os.Stderr, "Written to %s\n", *outputFile)
 	}
 }
+
+// writeFile creates or truncates the file at path and fills it with write.
+func writeFile(path string, write func(io.Writer) error) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if err := write(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// maxFetchSize is the largest DOT source fetched from a URL.
+const maxFetchSize = 10 << 20
+
+// readInput reads the DOT source named on the command line: a file, or an
+// http:// or https:// URL fetched within timeout.
+func readInput(name string, timeout time.Duration) ([]byte, error) {
+	if !strings.HasPrefix(name, "http://") && !strings.HasPrefix(name, "https://") {
+		return os.ReadFile(name)
+	}
+
+	client := &http.Client{Timeout: timeout}
+	resp, err := client.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", name, resp.Status)
+	}
+
+	src, err := io.ReadAll(io.LimitReader(resp.Body, maxFetchSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", name, err)
+	}
+	if len(src) > maxFetchSize {
+		return nil, fmt.Errorf("fetching %s: larger than %d bytes", name, maxFetchSize)
+	}
+	return src, nil
+}
+
+// splitList splits a comma-separated flag value, trimming spaces.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// renderReachable renders the part of graph downstream of roots as HTML or
+// JSON, per the -format flag.
+func renderReachable(graph *ast.Graph, roots []string) ([]byte, error) {
+	d3g, err := dot.Reachable(graph, roots, true)
+	if err != nil {
+		return nil, err
+	}
+	if *format == "json" {
+		if *meta {
+			d3g.Meta = d3g.ComputeMeta()
+		}
+		return json.MarshalIndent(d3g, "", "  ")
+	}
+	return d3.RenderHTML(d3g, dot.RenderOptions{
+		Title:             *title,
+		TitleFromLabel:    *titleAttr,
+		MainComponentOnly: *mainOnly,
+	})
+}
+
+// renderPath renders graph as HTML with the edges of the DOT file at
+// pathFile highlighted. An invalid path is an error.
+func renderPath(graph *ast.Graph, pathFile string, opts dot.RenderOptions) ([]byte, error) {
+	src, err := os.ReadFile(pathFile)
+	if err != nil {
+		return nil, err
+	}
+	opts.PathAST, err = dot.Parse(pathFile, src)
+	if err != nil {
+		return nil, fmt.Errorf("parsing path: %w", err)
+	}
+	output, result, err := dot.ToHTMLWithValidation(graph, opts)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil && !result.Valid {
+		return nil, fmt.Errorf("invalid path: %s", result.Error)
+	}
+	return output, nil
+}
+
+// startCommand starts a command without waiting for it; tests replace it.
+var startCommand = func(name string, args ...string) error {
+	return exec.Command(name, args...).Start()
+}
+
+// browserCommand returns the command that opens path in the default
+// browser on the given OS.
+func browserCommand(goos, path string) (string, []string) {
+	switch goos {
+	case "darwin":
+		return "open", []string{path}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", path}
+	default:
+		return "xdg-open", []string{path}
+	}
+}
+
+// openHTML writes html to path, or to a new temp file if path is empty,
+// and opens it in the default browser. It returns the path it opened.
+func openHTML(html []byte, path string) (string, error) {
+	if path == "" {
+		f, err := os.CreateTemp("", "dot2d3-*.html")
+		if err != nil {
+			return "", err
+		}
+		path = f.Name()
+		_, err = f.Write(html)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return "", err
+		}
+	} else if err := os.WriteFile(path, html, 0644); err != nil {
+		return "", err
+	}
+
+	name, args := browserCommand(runtime.GOOS, path)
+	if err := startCommand(name, args...); err != nil {
+		return "", fmt.Errorf("running %s: %w", name, err)
+	}
+	return path, nil
+}
+
+// reportWarnings prints each validation warning to w. With fatal set
+// (-Werror), any warning is returned as an error.
+func reportWarnings(w io.Writer, warnings []dot.Warning, fatal bool) error {
+	for _, warning := range warnings {
+		fmt.Fprintf(w, "warning: %s\n", warning)
+	}
+	if fatal && len(warnings) > 0 {
+		return fmt.Errorf("%d warning(s) treated as errors (-Werror)", len(warnings))
+	}
+	return nil
+}
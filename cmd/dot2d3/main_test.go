@@ -0,0 +1,828 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/anthonybishopric/dot2d3/pkg/d3"
+	"github.com/anthonybishopric/dot2d3/pkg/dot"
+)
+
+// TestMain runs the dot2d3 command itself, rather than the tests, when
+// runDot2d3 re-executes the test binary, so tests can check flag handling
+// and exit codes.
+func TestMain(m *testing.M) {
+	if os.Getenv("DOT2D3_RUN_MAIN") == "1" {
+		os.Args[0] = "dot2d3"
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runDot2d3 runs dot2d3 with args in a subprocess, returning its stdout,
+// stderr and exit code.
+func runDot2d3(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "DOT2D3_RUN_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	code := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		code = exitErr.ExitCode()
+	} else if err != nil {
+		t.Fatalf("running dot2d3: %v", err)
+	}
+	return stdout.String(), stderr.String(), code
+}
+
+func TestConvertMetricsHeaders(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(`digraph { A -> B -> C }`))
+	rec := httptest.NewRecorder()
+
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	for _, name := range []string{"X-Parse-Duration", "X-Render-Duration"} {
+		v := rec.Header().Get(name)
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			t.Errorf("expected numeric %s, got %q", name, v)
+		}
+	}
+
+	if got := rec.Header().Get("X-Node-Count"); got != "3" {
+		t.Errorf("expected X-Node-Count 3, got %q", got)
+	}
+	if got := rec.Header().Get("X-Edge-Count"); got != "2" {
+		t.Errorf("expected X-Edge-Count 2, got %q", got)
+	}
+}
+
+func TestConvertMetricsHeadersJSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/convert?format=json", strings.NewReader(`digraph { A -> B }`))
+	rec := httptest.NewRecorder()
+
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get("X-Edge-Count"); got != "1" {
+		t.Errorf("expected X-Edge-Count 1, got %q", got)
+	}
+}
+
+func TestConvertMatrix(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/convert?format=matrix",
+		strings.NewReader(`digraph { A -> B; A -> B; B -> C; C -> C }`))
+	rec := httptest.NewRecorder()
+
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected JSON content type, got %q", ct)
+	}
+
+	var resp struct {
+		Nodes  []string `json:"nodes"`
+		Matrix [][]int  `json:"matrix"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON response: %v", err)
+	}
+	if len(resp.Nodes) != 3 || len(resp.Matrix) != 3 {
+		t.Fatalf("expected a 3x3 matrix, got %+v", resp)
+	}
+
+	index := make(map[string]int)
+	for i, id := range resp.Nodes {
+		index[id] = i
+	}
+	want := map[[2]string]int{{"A", "B"}: 2, {"B", "C"}: 1, {"C", "C"}: 1}
+	for _, from := range resp.Nodes {
+		for _, to := range resp.Nodes {
+			if got := resp.Matrix[index[from]][index[to]]; got != want[[2]string{from, to}] {
+				t.Errorf("matrix[%s][%s]: expected %d, got %d", from, to, want[[2]string{from, to}], got)
+			}
+		}
+	}
+}
+
+func TestConvertMatrixTooLarge(t *testing.T) {
+	var src strings.Builder
+	src.WriteString("digraph {")
+	for i := 0; i <= maxMatrixNodes; i++ {
+		fmt.Fprintf(&src, " n%d;", i)
+	}
+	src.WriteString(" }")
+
+	req := httptest.NewRequest(http.MethodPost, "/convert?format=matrix", strings.NewReader(src.String()))
+	rec := httptest.NewRecorder()
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "more than 2000 nodes") {
+		t.Errorf("expected the node cap in the error, got %q", rec.Body.String())
+	}
+}
+
+func TestConvertTooLarge(t *testing.T) {
+	// 500 x 500 group edges: past the default edge limit from a few KB
+	var left, right strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&left, " a%d", i)
+		fmt.Fprintf(&right, " b%d", i)
+	}
+	src := "digraph { {" + left.String() + "} -> {" + right.String() + "} }"
+	req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(src))
+	rec := httptest.NewRecorder()
+
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status 413, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "more than 200000 edges") {
+		t.Errorf("expected edge limit in error, got %q", rec.Body.String())
+	}
+}
+
+func TestRequestBodyTooLarge(t *testing.T) {
+	big := "digraph { A -> B /*" + strings.Repeat("x", maxRequestSize) + "*/ }"
+
+	rec := httptest.NewRecorder()
+	handleConvert(rec, httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(big)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected /convert to answer 413, got %d", rec.Code)
+	}
+
+	body, _ := json.Marshal(ConvertRequest{Graph: big, Path: "digraph { A -> B }"})
+	rec = httptest.NewRecorder()
+	handleValidate(rec, httptest.NewRequest(http.MethodPost, "/validate", bytes.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected /validate to answer 413, got %d", rec.Code)
+	}
+}
+
+func TestConvertTimeout(t *testing.T) {
+	defer func(d time.Duration) { *convertTimeout = d }(*convertTimeout)
+	defer func() { convertHook = nil }()
+	*convertTimeout = 20 * time.Millisecond
+	convertHook = func() { time.Sleep(200 * time.Millisecond) }
+
+	req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(`digraph { A -> B }`))
+	rec := httptest.NewRecorder()
+
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status 503, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !strings.Contains(rec.Body.String(), "longer than 20ms") {
+		t.Errorf("expected the timeout in the error, got %q", rec.Body.String())
+	}
+	if rec.Header().Get("X-Parse-Duration") != "" {
+		t.Error("expected no metrics headers for a timed-out conversion")
+	}
+
+	// Fast conversions, rendering included, finish within the deadline
+	convertHook = nil
+	*convertTimeout = 5 * time.Second
+	rec = httptest.NewRecorder()
+	handleConvert(rec, httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(`digraph { A -> B }`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200 without the slow hook, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestConvertParseErrorHasNoMetrics(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/convert", strings.NewReader(`digraph { A -> }`))
+	rec := httptest.NewRecorder()
+
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status 400, got %d", rec.Code)
+	}
+	if rec.Header().Get("X-Node-Count") != "" {
+		t.Error("expected no metrics headers on failure")
+	}
+}
+
+func TestConvertIncludeValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		missing []string
+	}{
+		{"valid path", "digraph { A -> B -> C }", nil},
+		{"missing edge", "digraph { A -> C }", []string{"A -> C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ConvertRequest{Graph: "digraph { A -> B -> C }", Path: tt.path})
+			req := httptest.NewRequest(http.MethodPost, "/convert?include=validation", strings.NewReader(string(body)))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			handleConvert(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+
+			var resp map[string]json.RawMessage
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid JSON envelope: %v", err)
+			}
+			if len(resp) != 2 {
+				t.Errorf("expected exactly html and validation keys, got %d keys", len(resp))
+			}
+
+			var html string
+			if err := json.Unmarshal(resp["html"], &html); err != nil || !strings.Contains(html, "<svg") {
+				t.Errorf("expected html string with rendered graph, got %s", resp["html"])
+			}
+
+			var envelope ConvertResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &envelope); err != nil || envelope.Validation == nil {
+				t.Fatalf("expected validation object, got %s", resp["validation"])
+			}
+			if !envelope.Validation.Valid {
+				t.Errorf("expected path to be valid, got error %q", envelope.Validation.Error)
+			}
+			if strings.Join(envelope.Validation.MissingEdges, ",") != strings.Join(tt.missing, ",") {
+				t.Errorf("expected missing edges %v, got %v", tt.missing, envelope.Validation.MissingEdges)
+			}
+		})
+	}
+}
+
+func TestConvertPathsBetween(t *testing.T) {
+	tests := []struct {
+		name   string
+		query  string
+		status int
+	}{
+		{"paths", "?from=A&to=C", http.StatusOK},
+		{"bounded", "?from=A&to=C&maxlen=1", http.StatusOK},
+		{"missing to", "?from=A", http.StatusBadRequest},
+		{"unknown node", "?from=A&to=X", http.StatusBadRequest},
+		{"bad maxlen", "?from=A&to=C&maxlen=two", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/convert"+tt.query, strings.NewReader(`digraph { A -> B -> C; A -> C }`))
+			rec := httptest.NewRecorder()
+
+			handleConvert(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/convert?from=A&to=C", strings.NewReader(`digraph { A -> B -> C; A -> C }`))
+	rec := httptest.NewRecorder()
+	handleConvert(rec, req)
+	for _, want := range []string{
+		`{"source":"A","target":"C","order":1,"onPath":true,"pathIndex":1}`,
+		`{"source":"B","target":"C","onPath":true,"pathIndex":2}`,
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected %s in the rendered graph", want)
+		}
+	}
+}
+
+func TestConvertPathsBetweenBounded(t *testing.T) {
+	// A clique hanging off x, which every path to t goes through, holds
+	// more dead-end paths than could ever be walked; the server's search
+	// gives up on them and still answers well within its deadline
+	var src strings.Builder
+	src.WriteString("digraph { s -> x -> t;")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&src, " x -> c%d; c%d -> x;", i, i)
+		for j := 0; j < 20; j++ {
+			if i != j {
+				fmt.Fprintf(&src, " c%d -> c%d;", i, j)
+			}
+		}
+	}
+	src.WriteString(" }")
+
+	start := time.Now()
+	req := httptest.NewRequest(http.MethodPost, "/convert?from=s&to=t&maxlen=1000", strings.NewReader(src.String()))
+	rec := httptest.NewRecorder()
+	handleConvert(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if elapsed := time.Since(start); elapsed > *convertTimeout {
+		t.Errorf("expected the search to stop early, took %s", elapsed)
+	}
+	if !strings.Contains(rec.Body.String(), `{"source":"x","target":"t","onPath":true,"pathIndex":1}`) {
+		t.Error("expected the path through x to be highlighted")
+	}
+}
+func TestConvertImageFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		status      int
+		contentType string
+		imageType   string
+	}{
+		{"png", "?image=png", http.StatusOK, "text/html; charset=utf-8", `const imageType = "image/png";`},
+		{"jpeg", "?image=jpeg&quality=0.8", http.StatusOK, "text/html; charset=utf-8", `const imageType = "image/jpeg";`},
+		{"webp", "?image=webp&quality=0.5", http.StatusOK, "text/html; charset=utf-8", `const imageType = "image/webp";`},
+		{"unknown image", "?image=gif", http.StatusBadRequest, "text/plain; charset=utf-8", ""},
+		{"bad quality", "?image=jpeg&quality=high", http.StatusBadRequest, "text/plain; charset=utf-8", ""},
+		{"quality out of range", "?image=webp&quality=2", http.StatusBadRequest, "text/plain; charset=utf-8", ""},
+		{"quality not a number", "?image=webp&quality=NaN", http.StatusBadRequest, "text/plain; charset=utf-8", ""},
+		{"infinite quality", "?image=jpeg&quality=Inf", http.StatusBadRequest, "text/plain; charset=utf-8", ""},
+		{"server png", "?format=png", http.StatusNotImplemented, "text/plain; charset=utf-8", ""},
+		{"server webp", "?format=webp", http.StatusNotImplemented, "text/plain; charset=utf-8", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/convert"+tt.query, strings.NewReader(`digraph { A -> B }`))
+			rec := httptest.NewRecorder()
+
+			handleConvert(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("expected Content-Type %q, got %q", tt.contentType, got)
+			}
+			if tt.imageType != "" && !strings.Contains(rec.Body.String(), tt.imageType) {
+				t.Errorf("expected %s in the rendered page", tt.imageType)
+			}
+		})
+	}
+}
+
+func TestValidatePath(t *testing.T) {
+	tests := []struct {
+		name          string
+		path          string
+		valid         bool
+		lastValidNode string
+		missing       []string
+	}{
+		{"valid path", "digraph { A -> B -> C }", true, "", nil},
+		{"unknown node", "digraph { A -> B -> X }", false, "B", nil},
+		{"missing edge", "digraph { A -> C }", true, "", []string{"A -> C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(ConvertRequest{Graph: "digraph { A -> B -> C }", Path: tt.path})
+			req := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(string(body)))
+			rec := httptest.NewRecorder()
+
+			handleValidate(rec, req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("expected application/json, got %q", ct)
+			}
+
+			var result d3.PathValidationResult
+			if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			if result.Valid != tt.valid {
+				t.Errorf("expected valid=%v, got %v (error %q)", tt.valid, result.Valid, result.Error)
+			}
+			if tt.valid && result.Error != "" {
+				t.Errorf("expected no error, got %q", result.Error)
+			}
+			if result.LastValidNode != tt.lastValidNode {
+				t.Errorf("expected last valid node %q, got %q", tt.lastValidNode, result.LastValidNode)
+			}
+			if strings.Join(result.MissingEdges, ",") != strings.Join(tt.missing, ",") {
+				t.Errorf("expected missing edges %v, got %v", tt.missing, result.MissingEdges)
+			}
+		})
+	}
+}
+
+func TestValidatePathRequiresPath(t *testing.T) {
+	body, _ := json.Marshal(ConvertRequest{Graph: "digraph { A -> B }"})
+	req := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+
+	handleValidate(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", rec.Code)
+	}
+}
+
+func TestValidatePathTimeout(t *testing.T) {
+	defer func(d time.Duration) { *convertTimeout = d }(*convertTimeout)
+	*convertTimeout = time.Nanosecond
+
+	body, _ := json.Marshal(ConvertRequest{Graph: "digraph { A -> B }", Path: "digraph { A -> B }"})
+	req := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(string(body)))
+	rec := httptest.NewRecorder()
+
+	handleValidate(rec, req)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 past the deadline, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRenderPath(t *testing.T) {
+	g, err := dot.Parse("test.dot", []byte(`digraph { A -> B -> C; D -> E }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	dir := t.TempDir()
+	writePath := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	// Disconnected paths are all highlighted
+	html, err := renderPath(g, writePath("valid.dot", `digraph { A -> B; D -> E }`), dot.RenderOptions{})
+	if err != nil {
+		t.Fatalf("renderPath error: %v", err)
+	}
+	for _, want := range []string{
+		`{"source":"A","target":"B","onPath":true}`,
+		`{"source":"D","target":"E","onPath":true}`,
+		`{"source":"B","target":"C"}`,
+	} {
+		if !strings.Contains(string(html), want) {
+			t.Errorf("expected HTML to contain %s", want)
+		}
+	}
+
+	_, err = renderPath(g, writePath("invalid.dot", `digraph { A -> X }`), dot.RenderOptions{})
+	if err == nil || !strings.Contains(err.Error(), "invalid path: edge 'A -> X' references unknown node 'X'") {
+		t.Errorf("expected an invalid path error, got %v", err)
+	}
+
+	if _, err := renderPath(g, writePath("broken.dot", `digraph { A -> }`), dot.RenderOptions{}); err == nil {
+		t.Error("expected a parse error for a malformed path")
+	}
+}
+
+func TestCLIPath(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, src string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	graph := writeFile("graph.dot", `digraph { A -> B -> C }`)
+	valid := writeFile("valid.dot", `digraph { A -> B }`)
+	invalid := writeFile("invalid.dot", `digraph { A -> X }`)
+
+	stdout, stderr, code := runDot2d3(t, "-path", valid, graph)
+	if code != 0 {
+		t.Fatalf("expected a valid path to succeed, got exit %d: %s", code, stderr)
+	}
+	if !strings.Contains(stdout, `{"source":"A","target":"B","onPath":true}`) {
+		t.Error("expected the path to be highlighted in the HTML")
+	}
+
+	tests := []struct {
+		name   string
+		args   []string
+		stderr string
+	}{
+		{"invalid path", []string{"-path", invalid, graph}, "invalid path: edge 'A -> X' references unknown node 'X'"},
+		{"with roots", []string{"-path", valid, "-roots", "A", graph}, "-path needs HTML output and can't be combined with -roots"},
+		{"with json", []string{"-path", valid, "-format", "json", graph}, "-path needs HTML output"},
+		{"missing path file", []string{"-path", filepath.Join(dir, "missing.dot"), graph}, "missing.dot"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, code := runDot2d3(t, tt.args...)
+			if code != 1 {
+				t.Errorf("expected exit 1, got %d", code)
+			}
+			if !strings.Contains(stderr, tt.stderr) {
+				t.Errorf("expected stderr to contain %q, got %q", tt.stderr, stderr)
+			}
+			if stdout != "" {
+				t.Errorf("expected no output on failure, got %.40q", stdout)
+			}
+		})
+	}
+}
+
+func TestReadInputURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/graph.dot":
+			fmt.Fprint(w, `digraph Remote { A -> B }`)
+		case "/slow.dot":
+			time.Sleep(200 * time.Millisecond)
+			fmt.Fprint(w, `digraph { A }`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	src, err := readInput(srv.URL+"/graph.dot", time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	graph, err := dot.Parse(srv.URL+"/graph.dot", src)
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	html, err := dot.ToHTML(graph, dot.RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !strings.Contains(string(html), `{"source":"A","target":"B"}`) {
+		t.Error("expected the fetched graph to be rendered")
+	}
+
+	if _, err := readInput(srv.URL+"/missing.dot", time.Second); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 error, got %v", err)
+	}
+	if _, err := readInput(srv.URL+"/slow.dot", 50*time.Millisecond); err == nil {
+		t.Error("expected a timeout error")
+	}
+
+	// Anything else is a file name
+	path := filepath.Join(t.TempDir(), "local.dot")
+	if err := os.WriteFile(path, []byte(`graph { A }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if src, err := readInput(path, time.Second); err != nil || string(src) != `graph { A }` {
+		t.Errorf("expected the file's contents, got %q, %v", src, err)
+	}
+}
+
+func TestReportWarnings(t *testing.T) {
+	g, err := dot.Parse("test.dot", []byte(`digraph { A [color=notacolor] }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	warnings := dot.Validate(g)
+
+	var out bytes.Buffer
+	if err := reportWarnings(&out, warnings, false); err != nil {
+		t.Errorf("expected warnings to be informational without -Werror, got %v", err)
+	}
+	if !strings.Contains(out.String(), `warning: test.dot:1:20: invalid color "notacolor"`) {
+		t.Errorf("expected warning to be printed, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := reportWarnings(&out, warnings, true); err == nil {
+		t.Error("expected -Werror to fail on a warning")
+	}
+	if !strings.Contains(out.String(), "invalid color") {
+		t.Errorf("expected warning to be printed under -Werror, got %q", out.String())
+	}
+
+	if err := reportWarnings(&out, nil, true); err != nil {
+		t.Errorf("expected no error without warnings, got %v", err)
+	}
+}
+
+func TestCLIWerror(t *testing.T) {
+	graph := filepath.Join(t.TempDir(), "graph.dot")
+	if err := os.WriteFile(graph, []byte(`digraph { A [color=notacolor] }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, code := runDot2d3(t, graph)
+	if code != 0 || !strings.Contains(stdout, "<svg") {
+		t.Errorf("expected warnings alone not to fail, got exit %d: %s", code, stderr)
+	}
+	if !strings.Contains(stderr, `warning: `+graph+`:1:20: invalid color "notacolor"`) {
+		t.Errorf("expected the warning on stderr, got %q", stderr)
+	}
+
+	stdout, stderr, code = runDot2d3(t, "-Werror", graph)
+	if code != 1 {
+		t.Errorf("expected -Werror to exit 1, got %d", code)
+	}
+	if !strings.Contains(stderr, "invalid color") || !strings.Contains(stderr, "1 warning(s) treated as errors (-Werror)") {
+		t.Errorf("expected the warning and the -Werror error on stderr, got %q", stderr)
+	}
+	if stdout != "" {
+		t.Errorf("expected no output under -Werror, got %.40q", stdout)
+	}
+}
+
+func TestCLIJSONL(t *testing.T) {
+	dir := t.TempDir()
+	graph := filepath.Join(dir, "graph.dot")
+	if err := os.WriteFile(graph, []byte(`digraph { A -> B }`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"type":"node","id":"A","label":"A"}` + "\n" +
+		`{"type":"node","id":"B","label":"B"}` + "\n" +
+		`{"type":"edge","source":"A","target":"B"}` + "\n"
+
+	stdout, stderr, code := runDot2d3(t, "-format=jsonl", graph)
+	if code != 0 {
+		t.Fatalf("expected success, got exit %d: %s", code, stderr)
+	}
+	if stdout != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, stdout)
+	}
+
+	out := filepath.Join(dir, "graph.jsonl")
+	if _, stderr, code := runDot2d3(t, "-format=jsonl", "-o", out, graph); code != 0 {
+		t.Fatalf("expected success, got exit %d: %s", code, stderr)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Errorf("expected\n%s\nin %s, got\n%s", want, out, got)
+	}
+}
+
+func TestOpenHTML(t *testing.T) {
+	var gotName string
+	var gotArgs []string
+	orig := startCommand
+	startCommand = func(name string, args ...string) error {
+		gotName, gotArgs = name, args
+		return nil
+	}
+	defer func() { startCommand = orig }()
+
+	html := []byte("<html>graph</html>")
+	wantName, _ := browserCommand(runtime.GOOS, "")
+
+	// Without -o: a temp file
+	path, err := openHTML(html, "")
+	if err != nil {
+		t.Fatalf("openHTML error: %v", err)
+	}
+	defer os.Remove(path)
+	if !strings.HasSuffix(path, ".html") {
+		t.Errorf("expected a temp .html file, got %q", path)
+	}
+	if gotName != wantName || len(gotArgs) == 0 || gotArgs[len(gotArgs)-1] != path {
+		t.Errorf("expected %s to be run with %q, got %s %v", wantName, path, gotName, gotArgs)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(html) {
+		t.Errorf("expected the temp file to hold the HTML, got %q (%v)", data, err)
+	}
+
+	// With -o: the output file
+	out := filepath.Join(t.TempDir(), "out.html")
+	path, err = openHTML(html, out)
+	if err != nil {
+		t.Fatalf("openHTML error: %v", err)
+	}
+	if path != out || gotArgs[len(gotArgs)-1] != out {
+		t.Errorf("expected %q to be opened, got %q with args %v", out, path, gotArgs)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != string(html) {
+		t.Errorf("expected the output file to hold the HTML, got %q (%v)", data, err)
+	}
+}
+
+func TestBrowserCommand(t *testing.T) {
+	tests := []struct {
+		goos string
+		want string
+	}{
+		{"darwin", "open [g.html]"},
+		{"windows", "rundll32 [url.dll,FileProtocolHandler g.html]"},
+		{"linux", "xdg-open [g.html]"},
+	}
+	for _, tt := range tests {
+		name, args := browserCommand(tt.goos, "g.html")
+		if got := fmt.Sprint(name, " ", args); got != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.goos, tt.want, got)
+		}
+	}
+}
+
+func TestEventsStreamConversions(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /convert", handleConvert)
+	mux.HandleFunc("GET /events", handleEvents)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("events request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("expected text/event-stream, got %q", ct)
+	}
+	stream := bufio.NewReader(resp.Body)
+	readLines := func(n int) []string {
+		var lines []string
+		for len(lines) < n {
+			line, err := stream.ReadString('\n')
+			if err != nil {
+				t.Fatalf("reading stream: %v (got %q)", err, lines)
+			}
+			lines = append(lines, line)
+		}
+		return lines
+	}
+	if lines := readLines(2); lines[0] != ": connected\n" {
+		t.Fatalf("expected a connected comment, got %q", lines)
+	}
+	lines := readLines(3)
+	if lines[0] != "event: session\n" || !strings.HasPrefix(lines[1], "data: ") {
+		t.Fatalf("expected a session event, got %q", lines)
+	}
+	session := strings.TrimSpace(strings.TrimPrefix(lines[1], "data: "))
+	if len(session) != 32 {
+		t.Errorf("expected a 128-bit hex session ID, got %q", session)
+	}
+
+	// Conversions for another session, or without one, aren't sent
+	for _, url := range []string{"/convert?session=other", "/convert"} {
+		r, err := http.Post(srv.URL+url, "text/plain", strings.NewReader(`digraph { X }`))
+		if err != nil {
+			t.Fatalf("convert request failed: %v", err)
+		}
+		r.Body.Close()
+	}
+	r, err := http.Post(srv.URL+"/convert?session="+session, "text/plain", strings.NewReader(`digraph { A -> B }`))
+	if err != nil {
+		t.Fatalf("convert request failed: %v", err)
+	}
+	r.Body.Close()
+
+	lines = readLines(2)
+	if lines[0] != "event: convert\n" || !strings.HasPrefix(lines[1], "data: ") {
+		t.Fatalf("expected a convert event, got %q", lines)
+	}
+	var event ConvertEvent
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &event); err != nil {
+		t.Fatalf("event data is not JSON: %v", err)
+	}
+	if !strings.Contains(event.HTML, `"id":"A"`) || strings.Contains(event.HTML, `"id":"X"`) {
+		t.Error("expected the event to carry this session's rendered graph")
+	}
+}
+
+func TestEventsUnknownSession(t *testing.T) {
+	// Clients can't pick their own session IDs
+	rec := httptest.NewRecorder()
+	handleEvents(rec, httptest.NewRequest(http.MethodGet, "/events?session=mine", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("expected status 404 for a session the server didn't start, got %d", rec.Code)
+	}
+}
@@ -11,6 +11,7 @@ type Node interface {
 // Statement is the interface for statement nodes.
 type Statement interface {
 	Node
+	Comments() *Trivia
 	stmtNode()
 }
 
@@ -22,50 +23,74 @@ type EdgeEndpoint interface {
 
 // Graph represents a complete DOT graph.
 type Graph struct {
-	Position   token.Position
-	Strict     bool        // strict keyword present
-	Directed   bool        // digraph vs graph
-	ID         *Ident      // optional graph ID
-	Statements []Statement // statements in the graph body
+	Position   token.Position `json:"position"`
+	Strict     bool           `json:"strict,omitempty"`     // strict keyword present
+	Directed   bool           `json:"directed,omitempty"`   // digraph vs graph
+	ID         *Ident         `json:"id,omitempty"`         // optional graph ID
+	Statements []Statement    `json:"statements,omitempty"` // statements in the graph body
+	Trivia
 }
 
 func (g *Graph) Pos() token.Position { return g.Position }
 
+// Comment is a source comment, captured when the lexer's ScanComments
+// option is set.
+type Comment struct {
+	Position token.Position `json:"position"`
+	Text     string         `json:"text"` // including the //, /* */ or # delimiters
+}
+
+func (c *Comment) Pos() token.Position { return c.Position }
+
+// Trivia holds the comments the parser attached to a statement or graph.
+// Leading comments precede it on their own lines; trailing comments
+// follow it on its last line, or sit inside it. For a graph or subgraph,
+// Trailing also holds comments in an otherwise empty body, and for the
+// top-level graph, comments after its closing brace.
+type Trivia struct {
+	Leading  []*Comment `json:"leading,omitempty"`
+	Trailing []*Comment `json:"trailing,omitempty"`
+}
+
+// Comments returns the trivia itself, so that embedding types expose it.
+func (t *Trivia) Comments() *Trivia { return t }
+
 // Ident represents an identifier.
 type Ident struct {
-	Position token.Position
-	Name     string
-	Quoted   bool // was it a quoted string?
-	HTML     bool // was it an HTML string?
+	Position token.Position `json:"position"`
+	Name     string         `json:"name"`
+	Quoted   bool           `json:"quoted,omitempty"` // was it a quoted string?
+	HTML     bool           `json:"html,omitempty"`   // was it an HTML string?
 }
 
 func (i *Ident) Pos() token.Position { return i.Position }
 
 // NodeID represents a node identifier with optional port.
 type NodeID struct {
-	Position token.Position
-	ID       *Ident
-	Port     *Port // optional
+	Position token.Position `json:"position"`
+	ID       *Ident         `json:"id,omitempty"`
+	Port     *Port          `json:"port,omitempty"` // optional
 }
 
-func (n *NodeID) Pos() token.Position      { return n.Position }
-func (n *NodeID) edgeEndpointNode()        {}
-func (n *NodeID) String() string           { return n.ID.Name }
+func (n *NodeID) Pos() token.Position { return n.Position }
+func (n *NodeID) edgeEndpointNode()   {}
+func (n *NodeID) String() string      { return n.ID.Name }
 
 // Port represents a port specification: :ID[:compass_pt]
 type Port struct {
-	Position token.Position
-	ID       *Ident // port name
-	Compass  *Ident // optional compass point (n, ne, e, se, s, sw, w, nw, c, _)
+	Position token.Position `json:"position"`
+	ID       *Ident         `json:"id,omitempty"`      // port name
+	Compass  *Ident         `json:"compass,omitempty"` // optional compass point (n, ne, e, se, s, sw, w, nw, c, _)
 }
 
 func (p *Port) Pos() token.Position { return p.Position }
 
 // NodeStmt represents a node statement: ID [attr_list]
 type NodeStmt struct {
-	Position token.Position
-	NodeID   *NodeID
-	Attrs    *AttrList // optional
+	Position token.Position `json:"position"`
+	NodeID   *NodeID        `json:"nodeId,omitempty"`
+	Attrs    *AttrList      `json:"attrs,omitempty"` // optional
+	Trivia
 }
 
 func (n *NodeStmt) Pos() token.Position { return n.Position }
@@ -73,10 +98,11 @@ func (n *NodeStmt) stmtNode()           {}
 
 // EdgeStmt represents an edge statement.
 type EdgeStmt struct {
-	Position token.Position
-	Left     EdgeEndpoint // first node/subgraph
-	Rights   []EdgeRight  // subsequent edges
-	Attrs    *AttrList    // optional
+	Position token.Position `json:"position"`
+	Left     EdgeEndpoint   `json:"left"`             // first node/subgraph
+	Rights   []EdgeRight    `json:"rights,omitempty"` // subsequent edges
+	Attrs    *AttrList      `json:"attrs,omitempty"`  // optional
+	Trivia
 }
 
 func (e *EdgeStmt) Pos() token.Position { return e.Position }
@@ -84,18 +110,20 @@ func (e *EdgeStmt) stmtNode()           {}
 
 // EdgeRight represents the right side of an edge.
 type EdgeRight struct {
-	Position token.Position
-	Directed bool         // true for ->, false for --
-	Endpoint EdgeEndpoint // target node/subgraph
+	Position   token.Position `json:"position"`
+	OpPosition token.Position `json:"opPosition"`         // position of the -> or -- operator
+	Directed   bool           `json:"directed,omitempty"` // true for ->, false for --
+	Endpoint   EdgeEndpoint   `json:"endpoint"`           // target node/subgraph
 }
 
 func (e *EdgeRight) Pos() token.Position { return e.Position }
 
 // AttrStmt represents a default attribute statement: (graph|node|edge) attr_list
 type AttrStmt struct {
-	Position token.Position
-	Kind     AttrKind
-	Attrs    *AttrList
+	Position token.Position `json:"position"`
+	Kind     AttrKind       `json:"kind"`
+	Attrs    *AttrList      `json:"attrs,omitempty"`
+	Trivia
 }
 
 func (a *AttrStmt) Pos() token.Position { return a.Position }
@@ -125,9 +153,10 @@ func (k AttrKind) String() string {
 
 // AttrAssign represents a top-level attribute assignment: ID = ID
 type AttrAssign struct {
-	Position token.Position
-	Key      *Ident
-	Value    *Ident
+	Position token.Position `json:"position"`
+	Key      *Ident         `json:"key,omitempty"`
+	Value    *Ident         `json:"value,omitempty"`
+	Trivia
 }
 
 func (a *AttrAssign) Pos() token.Position { return a.Position }
@@ -135,8 +164,8 @@ func (a *AttrAssign) stmtNode()           {}
 
 // AttrList represents a list of attributes: [attr1=val1, attr2=val2]
 type AttrList struct {
-	Position token.Position
-	Attrs    []*Attr
+	Position token.Position `json:"position"`
+	Attrs    []*Attr        `json:"attrs,omitempty"`
 }
 
 func (a *AttrList) Pos() token.Position { return a.Position }
@@ -156,18 +185,19 @@ func (a *AttrList) Get(key string) string {
 
 // Attr represents a single attribute: ID = ID
 type Attr struct {
-	Position token.Position
-	Key      *Ident
-	Value    *Ident
+	Position token.Position `json:"position"`
+	Key      *Ident         `json:"key,omitempty"`
+	Value    *Ident         `json:"value,omitempty"`
 }
 
 func (a *Attr) Pos() token.Position { return a.Position }
 
 // Subgraph represents a subgraph: subgraph [ID] { stmt_list }
 type Subgraph struct {
-	Position   token.Position
-	ID         *Ident      // optional
-	Statements []Statement // statements in the subgraph body
+	Position   token.Position `json:"position"`
+	ID         *Ident         `json:"id,omitempty"`         // optional
+	Statements []Statement    `json:"statements,omitempty"` // statements in the subgraph body
+	Trivia
 }
 
 func (s *Subgraph) Pos() token.Position { return s.Position }
@@ -177,8 +207,8 @@ func (s *Subgraph) edgeEndpointNode()   {}
 // NodeGroup represents edge shorthand: {A B C}
 // Used during parsing and expanded into individual edges.
 type NodeGroup struct {
-	Position token.Position
-	Nodes    []*NodeID
+	Position token.Position `json:"position"`
+	Nodes    []*NodeID      `json:"nodes,omitempty"`
 }
 
 func (n *NodeGroup) Pos() token.Position { return n.Position }
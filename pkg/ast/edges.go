@@ -0,0 +1,90 @@
+package ast
+
+// Edge is a single source-target pair produced by expanding an edge
+// statement.
+type Edge struct {
+	Source   string
+	Target   string
+	Directed bool      // true for ->, false for --
+	Attrs    *AttrList // attributes of the originating statement, may be nil
+}
+
+// Edges returns every edge in g in statement order. Edge chains are split
+// into consecutive pairs, and node groups and subgraph endpoints expand to
+// one edge per node, so A -> {B C} yields A->B and A->C. Edges declared
+// inside subgraphs, including subgraphs used as endpoints, are included.
+func Edges(g *Graph) []Edge {
+	var edges []Edge
+	collectEdges(g.Statements, &edges)
+	return edges
+}
+
+func collectEdges(stmts []Statement, edges *[]Edge) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *EdgeStmt:
+			collectEndpointEdges(s.Left, edges)
+			left := endpointNodes(s.Left)
+			for _, r := range s.Rights {
+				collectEndpointEdges(r.Endpoint, edges)
+				right := endpointNodes(r.Endpoint)
+				for _, src := range left {
+					for _, dst := range right {
+						*edges = append(*edges, Edge{Source: src, Target: dst, Directed: r.Directed, Attrs: s.Attrs})
+					}
+				}
+				left = right
+			}
+		case *Subgraph:
+			collectEdges(s.Statements, edges)
+		}
+	}
+}
+
+// collectEndpointEdges adds the edges declared inside a subgraph endpoint.
+func collectEndpointEdges(ep EdgeEndpoint, edges *[]Edge) {
+	if sg, ok := ep.(*Subgraph); ok {
+		collectEdges(sg.Statements, edges)
+	}
+}
+
+// endpointNodes returns the distinct node IDs an edge endpoint stands for.
+func endpointNodes(ep EdgeEndpoint) []string {
+	var ids []string
+	seen := make(map[string]bool)
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var walk func(ep EdgeEndpoint)
+	walk = func(ep EdgeEndpoint) {
+		switch e := ep.(type) {
+		case *NodeID:
+			add(e.ID.Name)
+		case *NodeGroup:
+			for _, n := range e.Nodes {
+				add(n.ID.Name)
+			}
+		case *Subgraph:
+			for _, stmt := range e.Statements {
+				switch s := stmt.(type) {
+				case *NodeStmt:
+					add(s.NodeID.ID.Name)
+				case *EdgeStmt:
+					walk(s.Left)
+					for _, r := range s.Rights {
+						walk(r.Endpoint)
+					}
+				case *Subgraph:
+					walk(s)
+				}
+			}
+		}
+	}
+	walk(ep)
+
+	return ids
+}
@@ -0,0 +1,61 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/lexer"
+	"github.com/anthonybishopric/dot2d3/pkg/parser"
+)
+
+func TestEdges(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"chain", `digraph { A -> B -> C }`, []string{"A->B", "B->C"}},
+		{"node group", `digraph { A -> {B C} }`, []string{"A->B", "A->C"}},
+		{"group to group", `graph { {A B} -- {C D} }`, []string{"A--C", "A--D", "B--C", "B--D"}},
+		{"subgraph", `digraph { subgraph s { X -> Y } Y -> Z }`, []string{"X->Y", "Y->Z"}},
+		{"subgraph endpoint", `digraph { A -> subgraph s { B -> C } }`, []string{"B->C", "A->B", "A->C"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New("test", []byte(tt.input)))
+			g, err := p.Parse()
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			edges := ast.Edges(g)
+			if len(edges) != len(tt.want) {
+				t.Fatalf("expected %d edges, got %d: %+v", len(tt.want), len(edges), edges)
+			}
+			for i, e := range edges {
+				op := "--"
+				if e.Directed {
+					op = "->"
+				}
+				if got := e.Source + op + e.Target; got != tt.want[i] {
+					t.Errorf("edge %d: expected %s, got %s", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestEdgesAttrs(t *testing.T) {
+	p := parser.New(lexer.New("test", []byte(`digraph { A -> B -> C [color=red] }`)))
+	g, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	for _, e := range ast.Edges(g) {
+		if e.Attrs.Get("color") != "red" {
+			t.Errorf("expected %s->%s to carry color=red", e.Source, e.Target)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ast
+
+import "encoding/json"
+
+// Statements and edge endpoints are interfaces, so their JSON form carries a
+// "type" field naming the concrete node.
+
+func (n *NodeStmt) MarshalJSON() ([]byte, error) {
+	type plain NodeStmt
+	return marshalTyped("NodeStmt", (*plain)(n))
+}
+
+func (e *EdgeStmt) MarshalJSON() ([]byte, error) {
+	type plain EdgeStmt
+	return marshalTyped("EdgeStmt", (*plain)(e))
+}
+
+func (a *AttrStmt) MarshalJSON() ([]byte, error) {
+	type plain AttrStmt
+	return marshalTyped("AttrStmt", (*plain)(a))
+}
+
+func (a *AttrAssign) MarshalJSON() ([]byte, error) {
+	type plain AttrAssign
+	return marshalTyped("AttrAssign", (*plain)(a))
+}
+
+func (s *Subgraph) MarshalJSON() ([]byte, error) {
+	type plain Subgraph
+	return marshalTyped("Subgraph", (*plain)(s))
+}
+
+func (n *NodeID) MarshalJSON() ([]byte, error) {
+	type plain NodeID
+	return marshalTyped("NodeID", (*plain)(n))
+}
+
+func (n *NodeGroup) MarshalJSON() ([]byte, error) {
+	type plain NodeGroup
+	return marshalTyped("NodeGroup", (*plain)(n))
+}
+
+// MarshalText encodes the kind by name ("graph", "node", "edge").
+func (k AttrKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// marshalTyped encodes v, a struct, with a leading "type" field.
+func marshalTyped(typ string, v any) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	prefix := `{"type":"` + typ + `"`
+	if len(b) > 2 {
+		prefix += ","
+	}
+	return append([]byte(prefix), b[1:]...), nil
+}
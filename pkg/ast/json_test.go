@@ -0,0 +1,67 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/anthonybishopric/dot2d3/pkg/lexer"
+	"github.com/anthonybishopric/dot2d3/pkg/parser"
+)
+
+func TestGraphJSON(t *testing.T) {
+	p := parser.New(lexer.New("test.dot", []byte(`digraph G { rankdir=LR; node [shape=box]; A; A -> {B C}; subgraph s { D } }`)))
+	g, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := json.Marshal(g)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	var decoded struct {
+		Directed   bool `json:"directed"`
+		Statements []struct {
+			Type     string `json:"type"`
+			Kind     string `json:"kind"`
+			Position struct {
+				Filename string `json:"filename"`
+				Line     int    `json:"line"`
+				Column   int    `json:"column"`
+			} `json:"position"`
+			Rights []struct {
+				Endpoint struct {
+					Type string `json:"type"`
+				} `json:"endpoint"`
+			} `json:"rights"`
+		} `json:"statements"`
+	}
+	if err := json.Unmarshal(out, &decoded); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if !decoded.Directed {
+		t.Error("expected directed graph")
+	}
+
+	want := []string{"AttrAssign", "AttrStmt", "NodeStmt", "EdgeStmt", "Subgraph"}
+	if len(decoded.Statements) != len(want) {
+		t.Fatalf("expected %d statements, got %s", len(want), out)
+	}
+	for i, stmt := range decoded.Statements {
+		if stmt.Type != want[i] {
+			t.Errorf("statement %d: expected type %s, got %s", i, want[i], stmt.Type)
+		}
+	}
+
+	if decoded.Statements[1].Kind != "node" {
+		t.Errorf("expected attr kind node, got %q", decoded.Statements[1].Kind)
+	}
+	if got := decoded.Statements[3].Rights[0].Endpoint.Type; got != "NodeGroup" {
+		t.Errorf("expected NodeGroup endpoint, got %s", got)
+	}
+	if pos := decoded.Statements[2].Position; pos.Filename != "test.dot" || pos.Line != 1 || pos.Column != 43 {
+		t.Errorf("expected position test.dot:1:43, got %+v", pos)
+	}
+}
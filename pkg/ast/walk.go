@@ -0,0 +1,114 @@
+package ast
+
+import "github.com/anthonybishopric/dot2d3/pkg/token"
+
+// Walk traverses the tree rooted at n in source order, depth first. It calls
+// fn for each node and descends into the node's children only if fn
+// returns true.
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+
+	switch n := n.(type) {
+	case *Graph:
+		walkIdent(n.ID, fn)
+		walkStmts(n.Statements, fn)
+	case *Subgraph:
+		walkIdent(n.ID, fn)
+		walkStmts(n.Statements, fn)
+	case *NodeStmt:
+		Walk(n.NodeID, fn)
+		walkAttrList(n.Attrs, fn)
+	case *EdgeStmt:
+		Walk(n.Left, fn)
+		for i := range n.Rights {
+			Walk(&n.Rights[i], fn)
+		}
+		walkAttrList(n.Attrs, fn)
+	case *EdgeRight:
+		Walk(n.Endpoint, fn)
+	case *AttrStmt:
+		walkAttrList(n.Attrs, fn)
+	case *AttrAssign:
+		walkIdent(n.Key, fn)
+		walkIdent(n.Value, fn)
+	case *AttrList:
+		for _, a := range n.Attrs {
+			Walk(a, fn)
+		}
+	case *Attr:
+		walkIdent(n.Key, fn)
+		walkIdent(n.Value, fn)
+	case *NodeID:
+		walkIdent(n.ID, fn)
+		if n.Port != nil {
+			Walk(n.Port, fn)
+		}
+	case *Port:
+		walkIdent(n.ID, fn)
+		walkIdent(n.Compass, fn)
+	case *NodeGroup:
+		for _, id := range n.Nodes {
+			Walk(id, fn)
+		}
+	}
+}
+
+// The helpers below skip nil pointers, which would otherwise reach Walk as
+// non-nil interfaces.
+
+func walkStmts(stmts []Statement, fn func(Node) bool) {
+	for _, s := range stmts {
+		Walk(s, fn)
+	}
+}
+
+func walkIdent(id *Ident, fn func(Node) bool) {
+	if id != nil {
+		Walk(id, fn)
+	}
+}
+
+func walkAttrList(list *AttrList, fn func(Node) bool) {
+	if list != nil {
+		Walk(list, fn)
+	}
+}
+
+// FindNodeDeclaration returns the position of the first node statement
+// declaring id. If id only appears in edges, it returns the position of its
+// first use as an edge endpoint. The result is false if id is not in g.
+func FindNodeDeclaration(g *Graph, id string) (token.Position, bool) {
+	var decl, use *token.Position
+	Walk(g, func(n Node) bool {
+		if decl != nil {
+			return false
+		}
+		switch n := n.(type) {
+		case *NodeStmt:
+			if n.NodeID.ID.Name == id {
+				pos := n.Pos()
+				decl = &pos
+			}
+			return false // its NodeID is the declaration, not a use
+		case *NodeID:
+			if use == nil && n.ID.Name == id {
+				pos := n.Pos()
+				use = &pos
+			}
+		case *Attr, *AttrList, *AttrStmt, *AttrAssign:
+			return false
+		}
+		return true
+	})
+
+	switch {
+	case decl != nil:
+		return *decl, true
+	case use != nil:
+		return *use, true
+	default:
+		return token.Position{}, false
+	}
+}
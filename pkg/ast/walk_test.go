@@ -0,0 +1,77 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/lexer"
+	"github.com/anthonybishopric/dot2d3/pkg/parser"
+)
+
+func parseGraph(t *testing.T, input string) *ast.Graph {
+	t.Helper()
+	p := parser.New(lexer.New("test", []byte(input)))
+	g, err := p.Parse()
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	return g
+}
+
+func TestWalk(t *testing.T) {
+	g := parseGraph(t, `digraph G { A [color=red]; A -> {B C}; subgraph s { D:p } }`)
+
+	var idents []string
+	ast.Walk(g, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			idents = append(idents, id.Name)
+		}
+		return true
+	})
+
+	want := "G A color red A B C s D p"
+	if got := strings.Join(idents, " "); got != want {
+		t.Errorf("expected identifiers %q, got %q", want, got)
+	}
+
+	// Returning false prunes the subtree
+	pruned := 0
+	ast.Walk(g, func(n ast.Node) bool {
+		if _, ok := n.(*ast.Ident); ok {
+			pruned++
+		}
+		_, isSubgraph := n.(*ast.Subgraph)
+		return !isSubgraph
+	})
+	if pruned != 7 {
+		t.Errorf("expected 7 identifiers outside the subgraph, got %d", pruned)
+	}
+}
+
+func TestFindNodeDeclaration(t *testing.T) {
+	g := parseGraph(t, "digraph {\n  A -> B\n  B [label=x]\n  subgraph s { C -> A }\n}")
+
+	tests := []struct {
+		id         string
+		line, col  int
+		wantExists bool
+	}{
+		{"B", 3, 3, true}, // declared after its first edge use
+		{"A", 2, 3, true}, // edge-only: first endpoint
+		{"C", 4, 16, true},
+		{"missing", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			pos, ok := ast.FindNodeDeclaration(g, tt.id)
+			if ok != tt.wantExists {
+				t.Fatalf("expected found=%v, got %v", tt.wantExists, ok)
+			}
+			if pos.Line != tt.line || pos.Column != tt.col {
+				t.Errorf("expected %d:%d, got %d:%d", tt.line, tt.col, pos.Line, pos.Column)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package d3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// EachNode calls fn with a pointer to each node in order, so fn may modify
+// the node in place.
+func (g *Graph) EachNode(fn func(*Node)) {
+	for i := range g.Nodes {
+		fn(&g.Nodes[i])
+	}
+}
+
+// EachLink calls fn with a pointer to each link in order, so fn may modify
+// the link in place.
+func (g *Graph) EachLink(fn func(*Link)) {
+	for i := range g.Links {
+		fn(&g.Links[i])
+	}
+}
+
+// Attr returns the value of the node's Graphviz attribute key, with node
+// defaults already applied: the typed field the converter parsed it into,
+// formatted back as an attribute value, or else the Attributes entry. It
+// returns "" for attributes the node doesn't set, including a converted
+// node's label when it is just the ID and its group when it comes from the
+// node's subgraph.
+func (n *Node) Attr(key string) string {
+	var v string
+	switch key {
+	case "label":
+		if !n.labelFromID {
+			v = n.Label
+		}
+	case "color":
+		v = n.Color
+	case "fillcolor":
+		v = n.FillColor
+		if len(n.FillStops) > 1 {
+			v = strings.Join(n.FillStops, ":")
+		}
+	case "shape":
+		v = n.Shape
+		if n.Decorated {
+			for decorated, base := range decoratedShapes {
+				if base == n.Shape {
+					v = decorated
+				}
+			}
+		}
+	case "style":
+		v = n.Style
+	case "class":
+		v = n.Class
+	case "group":
+		if !n.groupFromSubgraph {
+			v = n.Group
+		}
+	case "image":
+		v = n.Image
+	case "fontcolor":
+		v = n.FontColor
+	case "fontsize":
+		v = formatAttrFloat(n.FontSize)
+	case "penwidth":
+		v = formatAttrFloat(n.PenWidth)
+	case "width":
+		v = formatAttrFloat(n.Width)
+	case "height":
+		v = formatAttrFloat(n.Height)
+	case "fixedsize":
+		if n.FixedSize {
+			v = "true"
+		}
+	case "peripheries":
+		v = formatAttrInt(n.Peripheries)
+		if n.NoOutline {
+			v = "0"
+		}
+	case "pos":
+		if n.Pos != nil {
+			v = strconv.FormatFloat(n.Pos.X, 'g', -1, 64) + "," + strconv.FormatFloat(-n.Pos.Y, 'g', -1, 64)
+			if n.Pos.Pinned {
+				v += "!"
+			}
+		}
+	case "sides":
+		v = formatAttrInt(n.Sides)
+	case "orientation":
+		v = formatAttrFloat(n.Orientation)
+	case "skew":
+		v = formatAttrFloat(n.Skew)
+	}
+	if v != "" {
+		return v
+	}
+	return n.Attributes[key]
+}
+
+// Attr returns the value of the link's Graphviz attribute key, with edge
+// defaults already applied: the typed field the converter parsed it into,
+// formatted back as an attribute value, or else the Attributes entry. It
+// returns "" for attributes the link doesn't set.
+func (l *Link) Attr(key string) string {
+	var v string
+	switch key {
+	case "label":
+		v = l.Label
+	case "color":
+		v = l.Color
+	case "style":
+		v = l.Style
+	case "class":
+		v = l.Class
+	case "fontcolor":
+		v = l.FontColor
+	case "labelfontcolor":
+		v = l.LabelFontColor
+	case "fontsize":
+		v = formatAttrFloat(l.FontSize)
+	case "penwidth":
+		v = formatAttrFloat(l.PenWidth)
+	case "minlen":
+		v = formatAttrInt(l.MinLen)
+		if l.Flat {
+			v = "0"
+		}
+	case "lhead":
+		v = l.LHead
+	case "ltail":
+		v = l.LTail
+	case "tailport":
+		v = joinPort(l.SourcePort, l.SourceCompass)
+	case "headport":
+		v = joinPort(l.TargetPort, l.TargetCompass)
+	}
+	if v != "" {
+		return v
+	}
+	return l.Attributes[key]
+}
+
+// formatAttrFloat formats a parsed numeric attribute, or returns "" for
+// the zero value that means it wasn't set.
+func formatAttrFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// formatAttrInt is formatAttrFloat for integer attributes.
+func formatAttrInt(n int) string {
+	if n == 0 {
+		return ""
+	}
+	return strconv.Itoa(n)
+}
+
+// joinPort is the inverse of splitPort.
+func joinPort(port, compass string) string {
+	if port == "" || compass == "" {
+		return port + compass
+	}
+	return port + ":" + compass
+}
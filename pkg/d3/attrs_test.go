@@ -0,0 +1,75 @@
+package d3
+
+import "testing"
+
+func TestNodeAndLinkAttr(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		B [fontsize=big]
+		node [color=red, fontsize="12pt"]
+		edge [style=dashed]
+		A [custom=yes, shape=Msquare, fillcolor="yellow:orange", peripheries=2]
+		A -> B [tailport="p:n", weight=3]
+		subgraph cluster_a { C; D [group=g, label="\N!"] }
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	nodes := make(map[string]*Node)
+	d3g.EachNode(func(n *Node) {
+		nodes[n.ID] = n
+	})
+	if len(nodes) != 4 {
+		t.Fatalf("expected EachNode to visit 4 nodes, got %d", len(nodes))
+	}
+
+	tests := []struct {
+		node, key, want string
+	}{
+		{"A", "color", "red"},
+		{"A", "custom", "yes"},
+		{"A", "shape", "Msquare"},
+		{"A", "fillcolor", "yellow:orange"},
+		{"A", "peripheries", "2"},
+		{"A", "fontsize", "12"},
+		{"A", "label", ""}, // defaults to the ID, but isn't set
+		{"C", "group", ""}, // from the subgraph, but isn't set
+		{"D", "group", "g"},
+		{"D", "label", "D!"},
+		{"B", "color", ""},
+		{"A", "missing", ""},
+		{"B", "fontsize", "big"}, // unparseable, kept in Attributes
+	}
+	for _, tt := range tests {
+		if got := nodes[tt.node].Attr(tt.key); got != tt.want {
+			t.Errorf("%s.Attr(%q) = %q, want %q", tt.node, tt.key, got, tt.want)
+		}
+	}
+	if got := nodes["A"].Attr("color"); got != nodes["A"].Color {
+		t.Errorf("expected Attr(\"color\") to return the typed Color %q, got %q", nodes["A"].Color, got)
+	}
+
+	var links []*Link
+	d3g.EachLink(func(l *Link) {
+		links = append(links, l)
+	})
+	if len(links) != 1 {
+		t.Fatalf("expected EachLink to visit 1 link, got %d", len(links))
+	}
+	for key, want := range map[string]string{
+		"style":    "dashed",
+		"tailport": "p:n",
+		"weight":   "3",
+		"headport": "",
+	} {
+		if got := links[0].Attr(key); got != want {
+			t.Errorf("link Attr(%q) = %q, want %q", key, got, want)
+		}
+	}
+
+	// The callbacks get pointers into the graph
+	d3g.EachLink(func(l *Link) { l.Color = "blue" })
+	if d3g.Links[0].Color != "blue" {
+		t.Error("expected EachLink to modify links in place")
+	}
+}
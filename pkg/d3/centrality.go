@@ -0,0 +1,111 @@
+package d3
+
+// DegreeCentrality returns the number of edges incident to each node,
+// counting both incoming and outgoing edges. A self-loop counts twice.
+func (g *Graph) DegreeCentrality() map[string]int {
+	degree := make(map[string]int, len(g.Nodes))
+	for _, n := range g.Nodes {
+		degree[n.ID] = 0
+	}
+	for _, l := range g.Links {
+		degree[l.Source]++
+		degree[l.Target]++
+	}
+	return degree
+}
+
+// BetweennessCentrality returns, for each node, the number of shortest
+// paths between other pairs of nodes that pass through it, computed with
+// Brandes' algorithm. Edges are followed in their direction for digraphs;
+// in undirected graphs each pair is counted once. Values are not
+// normalized.
+func (g *Graph) BetweennessCentrality() map[string]float64 {
+	neighbors := make(map[string][]string, len(g.Nodes))
+	seen := make(map[[2]string]bool)
+	addNeighbor := func(from, to string) {
+		if from == to || seen[[2]string{from, to}] {
+			return
+		}
+		seen[[2]string{from, to}] = true
+		neighbors[from] = append(neighbors[from], to)
+	}
+	for _, l := range g.Links {
+		addNeighbor(l.Source, l.Target)
+		if !g.Directed {
+			addNeighbor(l.Target, l.Source)
+		}
+	}
+
+	betweenness := make(map[string]float64, len(g.Nodes))
+	for _, n := range g.Nodes {
+		betweenness[n.ID] = 0
+	}
+
+	for _, s := range g.Nodes {
+		// Single-source shortest paths by BFS, recording predecessors and
+		// path counts
+		var stack []string
+		preds := make(map[string][]string)
+		sigma := map[string]float64{s.ID: 1}
+		dist := map[string]int{s.ID: 0}
+		queue := []string{s.ID}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range neighbors[v] {
+				if _, ok := dist[w]; !ok {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					preds[w] = append(preds[w], v)
+				}
+			}
+		}
+
+		// Accumulate dependencies in order of decreasing distance
+		delta := make(map[string]float64)
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range preds[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s.ID {
+				betweenness[w] += delta[w]
+			}
+		}
+	}
+
+	if !g.Directed {
+		for id := range betweenness {
+			betweenness[id] /= 2
+		}
+	}
+	return betweenness
+}
+
+// ComputeMeta returns the graph's summary statistics. Density counts
+// each edge once against n(n-1) possible edges in a digraph and n(n-1)/2
+// in an undirected graph, so parallel edges and self-loops can push it
+// past 1.
+func (g *Graph) ComputeMeta() *Meta {
+	m := &Meta{
+		Directed:  g.Directed,
+		Strict:    g.Strict,
+		NodeCount: len(g.Nodes),
+		EdgeCount: len(g.Links),
+	}
+	if n := float64(len(g.Nodes)); n > 1 {
+		possible := n * (n - 1)
+		if !g.Directed {
+			possible /= 2
+		}
+		m.Density = float64(len(g.Links)) / possible
+	}
+	for _, d := range g.DegreeCentrality() {
+		m.MaxDegree = max(m.MaxDegree, d)
+	}
+	return m
+}
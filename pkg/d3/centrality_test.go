@@ -0,0 +1,121 @@
+package d3
+
+import "testing"
+
+func TestDegreeCentrality(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B; A -> C; A -> D; B -> C; E }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	want := map[string]int{"A": 3, "B": 2, "C": 2, "D": 1, "E": 0}
+	got := d3g.DegreeCentrality()
+	for id, w := range want {
+		if got[id] != w {
+			t.Errorf("degree of %s: expected %d, got %d", id, w, got[id])
+		}
+	}
+}
+
+func TestBetweennessCentrality(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]float64
+	}{
+		{
+			"path",
+			`graph { A -- B -- C -- D -- E }`,
+			map[string]float64{"A": 0, "B": 3, "C": 4, "D": 3, "E": 0},
+		},
+		{
+			"star",
+			`graph { hub -- {a b c d} }`,
+			map[string]float64{"hub": 6, "a": 0, "b": 0, "c": 0, "d": 0},
+		},
+		{
+			"directed path",
+			`digraph { A -> B -> C }`,
+			map[string]float64{"A": 0, "B": 1, "C": 0},
+		},
+		{
+			"split paths",
+			`digraph { A -> {B C}; {B C} -> D }`,
+			map[string]float64{"A": 0, "B": 0.5, "C": 0.5, "D": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, tt.input))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			got := d3g.BetweennessCentrality()
+			for id, w := range tt.want {
+				if got[id] != w {
+					t.Errorf("betweenness of %s: expected %g, got %g", id, w, got[id])
+				}
+			}
+		})
+	}
+}
+
+func TestRenderSizeByCentrality(t *testing.T) {
+	d3g, err := Convert(parse(t, `graph { A -- B -- C }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{SizeByCentrality: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `"betweenness":1`) {
+		t.Error("expected betweenness in graph data")
+	}
+}
+
+func TestComputeMeta(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  Meta
+	}{
+		{
+			"digraph",
+			`strict digraph { A -> B -> C; A -> C; D }`,
+			Meta{Directed: true, Strict: true, NodeCount: 4, EdgeCount: 3, Density: 0.25, MaxDegree: 2},
+		},
+		{
+			"graph",
+			`graph { hub -- {a b c} }`,
+			Meta{NodeCount: 4, EdgeCount: 3, Density: 0.5, MaxDegree: 3},
+		},
+		{
+			"single node",
+			`digraph { A }`,
+			Meta{Directed: true, NodeCount: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := ConvertWithOptions(parse(t, tt.input), ConvertOptions{Meta: true})
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			if d3g.Meta == nil || *d3g.Meta != tt.want {
+				t.Errorf("expected meta %+v, got %+v", tt.want, d3g.Meta)
+			}
+		})
+	}
+
+	d3g, err := Convert(parse(t, `digraph { A -> B }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if d3g.Meta != nil {
+		t.Errorf("expected no meta unless requested, got %+v", d3g.Meta)
+	}
+}
@@ -0,0 +1,219 @@
+package d3
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// brewerQualitative holds the largest palette of each qualitative Brewer
+// scheme; smaller sizes of these schemes use a prefix of the same colors.
+var brewerQualitative = map[string][]string{
+	"accent":  {"#7fc97f", "#beaed4", "#fdc086", "#ffff99", "#386cb0", "#f0027f", "#bf5b17", "#666666"},
+	"dark2":   {"#1b9e77", "#d95f02", "#7570b3", "#e7298a", "#66a61e", "#e6ab02", "#a6761d", "#666666"},
+	"paired":  {"#a6cee3", "#1f78b4", "#b2df8a", "#33a02c", "#fb9a99", "#e31a1c", "#fdbf6f", "#ff7f00", "#cab2d6", "#6a3d9a", "#ffff99", "#b15928"},
+	"pastel1": {"#fbb4ae", "#b3cde3", "#ccebc5", "#decbe4", "#fed9a6", "#ffffcc", "#e5d8bd", "#fddaec", "#f2f2f2"},
+	"pastel2": {"#b3e2cd", "#fdcdac", "#cbd5e8", "#f4cae4", "#e6f5c9", "#fff2ae", "#f1e2cc", "#cccccc"},
+	"set1":    {"#e41a1c", "#377eb8", "#4daf4a", "#984ea3", "#ff7f00", "#ffff33", "#a65628", "#f781bf", "#999999"},
+	"set2":    {"#66c2a5", "#fc8d62", "#8da0cb", "#e78ac3", "#a6d854", "#ffd92f", "#e5c494", "#b3b3b3"},
+	"set3":    {"#8dd3c7", "#ffffb3", "#bebada", "#fb8072", "#80b1d3", "#fdb462", "#b3de69", "#fccde5", "#d9d9d9", "#bc80bd", "#ccebc5", "#ffed6f"},
+}
+
+// brewerSequential holds the 13 shades of each sequential Brewer scheme,
+// light to dark. Each size of a scheme picks from them in the same pattern,
+// given by sequentialPicks.
+var brewerSequential = map[string][]string{
+	"blues":   {"#f7fbff", "#eff3ff", "#deebf7", "#c6dbef", "#bdd7e7", "#9ecae1", "#6baed6", "#4292c6", "#3182bd", "#2171b5", "#08519c", "#084594", "#08306b"},
+	"bugn":    {"#f7fcfd", "#edf8fb", "#e5f5f9", "#ccece6", "#b2e2e2", "#99d8c9", "#66c2a4", "#41ae76", "#2ca25f", "#238b45", "#006d2c", "#005824", "#00441b"},
+	"bupu":    {"#f7fcfd", "#edf8fb", "#e0ecf4", "#bfd3e6", "#b3cde3", "#9ebcda", "#8c96c6", "#8c6bb1", "#8856a7", "#88419d", "#810f7c", "#6e016b", "#4d004b"},
+	"gnbu":    {"#f7fcf0", "#f0f9e8", "#e0f3db", "#ccebc5", "#bae4bc", "#a8ddb5", "#7bccc4", "#4eb3d3", "#43a2ca", "#2b8cbe", "#0868ac", "#08589e", "#084081"},
+	"greens":  {"#f7fcf5", "#edf8e9", "#e5f5e0", "#c7e9c0", "#bae4b3", "#a1d99b", "#74c476", "#41ab5d", "#31a354", "#238b45", "#006d2c", "#005a32", "#00441b"},
+	"greys":   {"#ffffff", "#f7f7f7", "#f0f0f0", "#d9d9d9", "#cccccc", "#bdbdbd", "#969696", "#737373", "#636363", "#525252", "#252525", "#252525", "#000000"},
+	"oranges": {"#fff5eb", "#feedde", "#fee6ce", "#fdd0a2", "#fdbe85", "#fdae6b", "#fd8d3c", "#f16913", "#e6550d", "#d94801", "#a63603", "#8c2d04", "#7f2704"},
+	"orrd":    {"#fff7ec", "#fef0d9", "#fee8c8", "#fdd49e", "#fdcc8a", "#fdbb84", "#fc8d59", "#ef6548", "#e34a33", "#d7301f", "#b30000", "#990000", "#7f0000"},
+	"pubu":    {"#fff7fb", "#f1eef6", "#ece7f2", "#d0d1e6", "#bdc9e1", "#a6bddb", "#74a9cf", "#3690c0", "#2b8cbe", "#0570b0", "#045a8d", "#034e7b", "#023858"},
+	"pubugn":  {"#fff7fb", "#f6eff7", "#ece2f0", "#d0d1e6", "#bdc9e1", "#a6bddb", "#67a9cf", "#3690c0", "#1c9099", "#02818a", "#016c59", "#016450", "#014636"},
+	"purd":    {"#f7f4f9", "#f1eef6", "#e7e1ef", "#d4b9da", "#d7b5d8", "#c994c7", "#df65b0", "#e7298a", "#dd1c77", "#ce1256", "#980043", "#91003f", "#67001f"},
+	"purples": {"#fcfbfd", "#f2f0f7", "#efedf5", "#dadaeb", "#cbc9e2", "#bcbddc", "#9e9ac8", "#807dba", "#756bb1", "#6a51a3", "#54278f", "#4a1486", "#3f007d"},
+	"rdpu":    {"#fff7f3", "#feebe2", "#fde0dd", "#fcc5c0", "#fbb4b9", "#fa9fb5", "#f768a1", "#dd3497", "#c51b8a", "#ae017e", "#7a0177", "#7a0177", "#49006a"},
+	"reds":    {"#fff5f0", "#fee5d9", "#fee0d2", "#fcbba1", "#fcae91", "#fc9272", "#fb6a4a", "#ef3b2c", "#de2d26", "#cb181d", "#a50f15", "#99000d", "#67000d"},
+	"ylgn":    {"#ffffe5", "#ffffcc", "#f7fcb9", "#d9f0a3", "#c2e699", "#addd8e", "#78c679", "#41ab5d", "#31a354", "#238443", "#006837", "#005a32", "#004529"},
+	"ylgnbu":  {"#ffffd9", "#ffffcc", "#edf8b1", "#c7e9b4", "#a1dab4", "#7fcdbb", "#41b6c4", "#1d91c0", "#2c7fb8", "#225ea8", "#253494", "#0c2c84", "#081d58"},
+	"ylorbr":  {"#ffffe5", "#ffffd4", "#fff7bc", "#fee391", "#fed98e", "#fec44f", "#fe9929", "#ec7014", "#d95f0e", "#cc4c02", "#993404", "#8c2d04", "#662506"},
+	"ylorrd":  {"#ffffcc", "#ffffb2", "#ffeda0", "#fed976", "#fecc5c", "#feb24c", "#fd8d3c", "#fc4e2a", "#f03b20", "#e31a1c", "#bd0026", "#b10026", "#800026"},
+}
+
+// sequentialPicks lists, for each size of a sequential Brewer scheme, the
+// shades it uses as indexes into brewerSequential ('a' for the first).
+var sequentialPicks = map[int]string{
+	3: "cfi",
+	4: "begj",
+	5: "begik",
+	6: "bdfgik",
+	7: "bdfghjl",
+	8: "acdfghjl",
+	9: "acdfghjkm",
+}
+
+// NormalizeColor converts a Graphviz color to a CSS color: X11 names and
+// "/x11/name" references become hex, "/accent3/2"- and "/blues9/7"-style
+// Brewer references resolve to their palette entry, and "H,S,V" triples are converted from
+// HSV. Hex codes, "transparent", and anything unrecognized pass through.
+func NormalizeColor(c string) string {
+	c = strings.TrimSpace(c)
+	if c == "" || strings.HasPrefix(c, "#") {
+		return c
+	}
+
+	lower := strings.ToLower(c)
+	if lower == "transparent" {
+		return lower
+	}
+
+	if strings.HasPrefix(lower, "/") {
+		scheme, name, ok := strings.Cut(lower[1:], "/")
+		if !ok {
+			return c
+		}
+		if hex, ok := schemeColor(scheme, name); ok {
+			return hex
+		}
+		return c
+	}
+
+	if hex, ok := x11Colors[strings.ReplaceAll(lower, " ", "")]; ok {
+		return hex
+	}
+	if hex, ok := hsvColor(c); ok {
+		return hex
+	}
+	return c
+}
+
+// normalizeColorList normalizes each color of a Graphviz color list such as
+// "yellow:orange" or "red;0.3:blue", keeping separators and weights.
+func normalizeColorList(list string) string {
+	if !strings.Contains(list, ":") {
+		return NormalizeColor(list)
+	}
+	parts := strings.Split(list, ":")
+	for i, p := range parts {
+		color, weight, hasWeight := strings.Cut(p, ";")
+		parts[i] = NormalizeColor(color)
+		if hasWeight {
+			parts[i] += ";" + weight
+		}
+	}
+	return strings.Join(parts, ":")
+}
+
+// schemeColor resolves a color name within a named color scheme.
+func schemeColor(scheme, name string) (string, bool) {
+	switch scheme {
+	case "x11", "svg", "":
+		hex, ok := x11Colors[name]
+		return hex, ok
+	}
+
+	// Brewer schemes are named with their size, e.g. accent3 or set19
+	// (set1, size 9)
+	for base, palette := range brewerQualitative {
+		size, ok := schemeSize(scheme, base, len(palette))
+		if !ok {
+			continue
+		}
+		idx, err := strconv.Atoi(name)
+		if err != nil || idx < 1 || idx > size {
+			return "", false
+		}
+		return palette[idx-1], true
+	}
+	for base, shades := range brewerSequential {
+		size, ok := schemeSize(scheme, base, 9)
+		if !ok {
+			continue
+		}
+		idx, err := strconv.Atoi(name)
+		if err != nil || idx < 1 || idx > size {
+			return "", false
+		}
+		return shades[sequentialPicks[size][idx-1]-'a'], true
+	}
+	return "", false
+}
+
+// schemeSize returns the size of a Brewer scheme name such as "blues9" for
+// the scheme base, if it is between 3 and max.
+func schemeSize(scheme, base string, max int) (int, bool) {
+	digits, ok := strings.CutPrefix(scheme, base)
+	if !ok {
+		return 0, false
+	}
+	size, err := strconv.Atoi(digits)
+	if err != nil || size < 3 || size > max {
+		return 0, false
+	}
+	return size, true
+}
+
+// hsvColor converts a Graphviz "H,S,V" or "H S V" color, with components
+// in [0, 1], to hex.
+func hsvColor(c string) (string, bool) {
+	fields := strings.FieldsFunc(c, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) != 3 {
+		return "", false
+	}
+	var hsv [3]float64
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil || v < 0 || v > 1 {
+			return "", false
+		}
+		hsv[i] = v
+	}
+
+	h, s, v := hsv[0]*6, hsv[1], hsv[2]
+	i := math.Floor(h)
+	f := h - i
+	p, q, t := v*(1-s), v*(1-s*f), v*(1-s*(1-f))
+	var r, g, b float64
+	switch int(i) % 6 {
+	case 0:
+		r, g, b = v, t, p
+	case 1:
+		r, g, b = q, v, p
+	case 2:
+		r, g, b = p, v, t
+	case 3:
+		r, g, b = p, q, v
+	case 4:
+		r, g, b = t, p, v
+	default:
+		r, g, b = v, p, q
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round(r*255)), int(math.Round(g*255)), int(math.Round(b*255))), true
+}
+
+// normalizeColors rewrites every color in g to a CSS color.
+func normalizeColors(g *Graph) {
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		n.Color = normalizeColorList(n.Color)
+		n.FillColor = normalizeColorList(n.FillColor)
+		for j, stop := range n.FillStops {
+			n.FillStops[j] = NormalizeColor(stop)
+		}
+		n.FontColor = NormalizeColor(n.FontColor)
+	}
+	for i := range g.Links {
+		l := &g.Links[i]
+		l.Color = normalizeColorList(l.Color)
+		l.FontColor = NormalizeColor(l.FontColor)
+		l.LabelFontColor = NormalizeColor(l.LabelFontColor)
+	}
+	for i := range g.Subgraphs {
+		g.Subgraphs[i].Color = normalizeColorList(g.Subgraphs[i].Color)
+	}
+	g.BgColor = NormalizeColor(g.BgColor)
+}
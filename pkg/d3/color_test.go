@@ -0,0 +1,94 @@
+package d3
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeColor(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"cornflowerblue", "#6495ed"},
+		{"CornflowerBlue", "#6495ed"},
+		{"transparent", "transparent"},
+		{"Transparent", "transparent"},
+		{"lightgoldenrod", "#eedd82"},
+		{"gray50", "#7f7f7f"},
+		{"/x11/red", "#ff0000"},
+		{"/accent3/2", "#beaed4"},
+		{"/set19/9", "#999999"},
+		{"/accent3/4", "/accent3/4"}, // out of range for the palette size
+		{"/blues9/3", "#c6dbef"},
+		{"/blues3/1", "#deebf7"},
+		{"/ylorrd4/4", "#e31a1c"},
+		{"/pubugn9/9", "#014636"},
+		{"/blues3/4", "/blues3/4"},   // out of range for the palette size
+		{"/blues10/1", "/blues10/1"}, // sequential schemes stop at 9
+		{"/rdylgn5/1", "/rdylgn5/1"}, // unsupported scheme passes through
+		{"0.0 1.0 1.0", "#ff0000"},
+		{"0.5,1,1", "#00ffff"},
+		{"#AbCdEf", "#AbCdEf"},
+		{"notacolor", "notacolor"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := NormalizeColor(tt.in); got != tt.want {
+			t.Errorf("NormalizeColor(%q): expected %q, got %q", tt.in, tt.want, got)
+		}
+	}
+
+	if got := normalizeColorList("yellow;0.3:/accent3/1"); got != "#ffff00;0.3:#7fc97f" {
+		t.Errorf("expected normalized color list, got %q", got)
+	}
+}
+
+func TestMarshalNormalizesColors(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A [color=cornflowerblue, fontcolor="/blues3/3"]; A -> B [color="red:/accent3/1"] }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	data, err := json.Marshal(d3g)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	for _, want := range []string{`"color":"#6495ed"`, `"fontColor":"#3182bd"`, `"color":"#ff0000:#7fc97f"`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in %s", want, data)
+		}
+	}
+	if d3g.Nodes[0].Color != "cornflowerblue" || d3g.Links[0].Color != "red:/accent3/1" {
+		t.Errorf("expected the graph to keep its Graphviz colors, got %q and %q", d3g.Nodes[0].Color, d3g.Links[0].Color)
+	}
+}
+
+func TestRenderNormalizesColors(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { bgcolor=transparent; A [color=cornflowerblue, fillcolor="yellow:orange"]; A -> B [color="/accent3/3"]; subgraph cluster_x { color=gray50; C } }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	for _, want := range []string{
+		`"bgcolor":"transparent"`,
+		`"color":"#6495ed"`,
+		`"fillStops":["#ffff00","#ffa500"]`,
+		`"color":"#fdc086"`,
+		`"color":"#7f7f7f"`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected %s in graph data", want)
+		}
+	}
+	if !contains(htmlStr, `.style("background", graphData.bgcolor || null)`) {
+		t.Error("expected background color to be applied")
+	}
+}
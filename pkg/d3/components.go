@@ -0,0 +1,227 @@
+package d3
+
+import (
+	"fmt"
+	"sort"
+)
+
+// StronglyConnectedComponents returns the graph's strongly connected
+// components, computed with Tarjan's algorithm: maximal sets of nodes that
+// can all reach each other. Every node is in exactly one component, so
+// nodes that aren't part of any cycle come back as single-node components.
+// Components are listed in reverse topological order, each with its nodes
+// in graph order. Edges are followed in both directions for undirected
+// graphs, which makes the components the connected components.
+func (g *Graph) StronglyConnectedComponents() [][]string {
+	position := make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		position[n.ID] = i
+	}
+	neighbors := make(map[string][]string, len(g.Nodes))
+	for _, l := range g.Links {
+		neighbors[l.Source] = append(neighbors[l.Source], l.Target)
+		if !g.Directed {
+			neighbors[l.Target] = append(neighbors[l.Target], l.Source)
+		}
+	}
+
+	var (
+		components [][]string
+		stack      []string
+		onStack    = make(map[string]bool)
+		index      = make(map[string]int)
+		lowlink    = make(map[string]int)
+	)
+	var visit func(v string)
+	visit = func(v string) {
+		index[v] = len(index)
+		lowlink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+
+		for _, w := range neighbors[v] {
+			if _, seen := index[w]; !seen {
+				visit(w)
+				lowlink[v] = min(lowlink[v], lowlink[w])
+			} else if onStack[w] {
+				lowlink[v] = min(lowlink[v], index[w])
+			}
+		}
+
+		// v is the root of a component: pop it and everything above it
+		if lowlink[v] == index[v] {
+			var component []string
+			for {
+				w := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[w] = false
+				component = append(component, w)
+				if w == v {
+					break
+				}
+			}
+			sort.Slice(component, func(i, j int) bool {
+				return position[component[i]] < position[component[j]]
+			})
+			components = append(components, component)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if _, seen := index[n.ID]; !seen {
+			visit(n.ID)
+		}
+	}
+	return components
+}
+
+// ConnectedComponents returns the graph's weakly connected components:
+// maximal sets of nodes joined by edges followed in either direction.
+// Components are listed in the order of their first node, each with its
+// nodes in graph order.
+func (g *Graph) ConnectedComponents() [][]string {
+	neighbors := make(map[string][]string, len(g.Nodes))
+	for _, l := range g.Links {
+		neighbors[l.Source] = append(neighbors[l.Source], l.Target)
+		neighbors[l.Target] = append(neighbors[l.Target], l.Source)
+	}
+
+	component := make(map[string]int, len(g.Nodes))
+	count := 0
+	for _, n := range g.Nodes {
+		if _, seen := component[n.ID]; seen {
+			continue
+		}
+		component[n.ID] = count
+		queue := []string{n.ID}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for _, next := range neighbors[id] {
+				if _, seen := component[next]; !seen {
+					component[next] = count
+					queue = append(queue, next)
+				}
+			}
+		}
+		count++
+	}
+
+	components := make([][]string, count)
+	for _, n := range g.Nodes {
+		c := component[n.ID]
+		components[c] = append(components[c], n.ID)
+	}
+	return components
+}
+
+// MainComponent returns a copy of the graph pruned to its largest
+// connected component, as Reachable would; of equally large components,
+// the one listed first by ConnectedComponents is kept.
+func (g *Graph) MainComponent() *Graph {
+	var largest []string
+	for _, c := range g.ConnectedComponents() {
+		if len(c) > len(largest) {
+			largest = c
+		}
+	}
+	if len(largest) == 0 {
+		pruned := *g
+		return &pruned
+	}
+	// The root is in the graph, so this can't fail
+	pruned, _ := g.Reachable(largest[:1], false)
+	return pruned
+}
+
+// cyclicComponents returns the strongly connected components that contain
+// a cycle: those with more than one node, or a single node with a
+// self-loop.
+func (g *Graph) cyclicComponents() [][]string {
+	selfLoop := make(map[string]bool)
+	for _, l := range g.Links {
+		if l.Source == l.Target {
+			selfLoop[l.Source] = true
+		}
+	}
+
+	var cyclic [][]string
+	for _, c := range g.StronglyConnectedComponents() {
+		if len(c) > 1 || selfLoop[c[0]] {
+			cyclic = append(cyclic, c)
+		}
+	}
+	return cyclic
+}
+
+// Reachable returns a copy of the graph pruned to the nodes reachable from
+// roots, the edges among them, and the clusters that still have nodes.
+// With directed set, edges are followed only from source to target (where
+// the edge is directed); otherwise in both directions. Attributes are kept.
+// It is an error for a root not to be in the graph.
+func (g *Graph) Reachable(roots []string, directed bool) (*Graph, error) {
+	known := make(map[string]bool, len(g.Nodes))
+	for _, n := range g.Nodes {
+		known[n.ID] = true
+	}
+	neighbors := make(map[string][]string)
+	for _, l := range g.Links {
+		neighbors[l.Source] = append(neighbors[l.Source], l.Target)
+		if !directed || !g.LinkDirected(l) {
+			neighbors[l.Target] = append(neighbors[l.Target], l.Source)
+		}
+	}
+
+	reached := make(map[string]bool)
+	var queue []string
+	for _, id := range roots {
+		if !known[id] {
+			return nil, fmt.Errorf("root %q is not in the graph", id)
+		}
+		if !reached[id] {
+			reached[id] = true
+			queue = append(queue, id)
+		}
+	}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, next := range neighbors[id] {
+			if !reached[next] {
+				reached[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	pruned := *g
+	pruned.Nodes = nil
+	for _, n := range g.Nodes {
+		if reached[n.ID] {
+			pruned.Nodes = append(pruned.Nodes, n)
+		}
+	}
+	pruned.Links = nil
+	for _, l := range g.Links {
+		if reached[l.Source] && reached[l.Target] {
+			pruned.Links = append(pruned.Links, l)
+		}
+	}
+	pruned.Subgraphs = nil
+	for _, sg := range g.Subgraphs {
+		var nodes []string
+		for _, id := range sg.Nodes {
+			if reached[id] {
+				nodes = append(nodes, id)
+			}
+		}
+		if len(nodes) > 0 {
+			sg.Nodes = nodes
+			pruned.Subgraphs = append(pruned.Subgraphs, sg)
+		}
+	}
+	if g.Meta != nil {
+		pruned.Meta = pruned.ComputeMeta()
+	}
+	return &pruned, nil
+}
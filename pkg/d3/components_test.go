@@ -0,0 +1,228 @@
+package d3
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// componentSets sorts each component and then the components, since the
+// converter's node order is arbitrary.
+func componentSets(components [][]string) string {
+	var sets []string
+	for _, c := range components {
+		c = append([]string(nil), c...)
+		sort.Strings(c)
+		sets = append(sets, fmt.Sprint(c))
+	}
+	sort.Strings(sets)
+	return fmt.Sprint(sets)
+}
+
+func TestStronglyConnectedComponents(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"cycle and edge", `digraph { A -> B -> C -> A; C -> D }`, "[[A B C] [D]]"},
+		{"two cycles", `digraph { A -> B -> A; B -> C; C -> D -> C }`, "[[A B] [C D]]"},
+		{"acyclic", `digraph { A -> B -> C; A -> C }`, "[[A] [B] [C]]"},
+		{"undirected", `graph { A -- B; C -- D; E }`, "[[A B] [C D] [E]]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, tt.input))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			if got := componentSets(d3g.StronglyConnectedComponents()); got != tt.want {
+				t.Errorf("expected components %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestStronglyConnectedComponentsOrder(t *testing.T) {
+	// Built directly so the node order is known
+	d3g := &Graph{
+		Nodes: []Node{{ID: "X"}, {ID: "C"}, {ID: "A"}, {ID: "B"}},
+		Links: []Link{
+			{Source: "A", Target: "B"}, {Source: "B", Target: "C"},
+			{Source: "C", Target: "A"}, {Source: "C", Target: "X"},
+		},
+		Directed: true,
+	}
+
+	// X is reachable from the cycle, so it comes first
+	want := "[[X] [C A B]]"
+	if got := fmt.Sprint(d3g.StronglyConnectedComponents()); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	const input = `digraph {
+		subgraph cluster_x { X1; X2 }
+		A -> B -> C [color=red]
+		C -> A
+		D -> B
+		B -> X1
+		E -> F
+	}`
+	tests := []struct {
+		name     string
+		roots    []string
+		directed bool
+		nodes    string
+		links    int
+		clusters int
+	}{
+		{"downstream", []string{"B"}, true, "[A B C X1]", 4, 1},
+		{"several roots", []string{"D", "E"}, true, "[A B C D E F X1]", 6, 1},
+		{"leaf", []string{"X1"}, true, "[X1]", 0, 1},
+		{"either direction", []string{"X1"}, false, "[A B C D X1]", 5, 1},
+		{"no roots", nil, true, "[]", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, input))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			pruned, err := d3g.Reachable(tt.roots, tt.directed)
+			if err != nil {
+				t.Fatalf("reachable error: %v", err)
+			}
+
+			var ids []string
+			for _, n := range pruned.Nodes {
+				ids = append(ids, n.ID)
+			}
+			sort.Strings(ids)
+			if got := fmt.Sprint(ids); got != tt.nodes {
+				t.Errorf("expected nodes %s, got %s", tt.nodes, got)
+			}
+			if len(pruned.Links) != tt.links {
+				t.Errorf("expected %d links, got %d: %+v", tt.links, len(pruned.Links), pruned.Links)
+			}
+			if len(pruned.Subgraphs) != tt.clusters {
+				t.Errorf("expected %d clusters, got %+v", tt.clusters, pruned.Subgraphs)
+			}
+			for _, l := range pruned.Links {
+				if l.Source == "B" && l.Target == "C" && l.Color != "red" {
+					t.Errorf("expected edge attributes to be kept, got %+v", l)
+				}
+			}
+		})
+	}
+
+	d3g, err := Convert(parse(t, input))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if _, err := d3g.Reachable([]string{"Q"}, true); err == nil {
+		t.Error("expected an error for an unknown root")
+	}
+	if len(d3g.Nodes) != 8 {
+		t.Errorf("expected the original graph to be left alone, got %d nodes", len(d3g.Nodes))
+	}
+}
+
+func TestConnectedComponents(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		A -> B -> C
+		D -> C
+		E -> D
+		X -> Y
+		Z
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if got, want := componentSets(d3g.ConnectedComponents()), "[[A B C D E] [X Y] [Z]]"; got != want {
+		t.Errorf("expected components %s, got %s", want, got)
+	}
+
+	main := d3g.MainComponent()
+	var ids []string
+	for _, n := range main.Nodes {
+		ids = append(ids, n.ID)
+	}
+	sort.Strings(ids)
+	if got := fmt.Sprint(ids); got != "[A B C D E]" {
+		t.Errorf("expected the 5-node component, got %s", got)
+	}
+	if len(main.Links) != 4 {
+		t.Errorf("expected the component's 4 links, got %+v", main.Links)
+	}
+	if len(d3g.Nodes) != 8 {
+		t.Errorf("expected the original graph to be left alone, got %d nodes", len(d3g.Nodes))
+	}
+
+	if empty := (&Graph{}).MainComponent(); len(empty.Nodes) != 0 {
+		t.Errorf("expected an empty graph to stay empty, got %+v", empty)
+	}
+}
+
+func TestRenderMainComponentOnly(t *testing.T) {
+	d3g, err := Convert(parse(t, `graph { A -- B -- C; X -- Y }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{MainComponentOnly: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	if !contains(htmlStr, `"id":"A"`) || contains(htmlStr, `"id":"X"`) {
+		t.Error("expected only the largest component to be rendered")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `"id":"X"`) {
+		t.Error("expected every component by default")
+	}
+}
+
+func TestRenderHighlightSCCs(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B -> C -> A; C -> D; E -> E }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{HighlightSCCs: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+
+	scc := make(map[string]int)
+	for _, n := range renderedGraph(t, html).Nodes {
+		scc[n.ID] = n.SCC
+	}
+	if scc["A"] == 0 || scc["A"] != scc["B"] || scc["A"] != scc["C"] {
+		t.Errorf("expected A, B and C to share a component, got %v", scc)
+	}
+	if scc["D"] != 0 {
+		t.Errorf("expected singleton D to be left uncolored, got %v", scc)
+	}
+	if scc["E"] == 0 || scc["E"] == scc["A"] {
+		t.Errorf("expected self-looping E to get its own component, got %v", scc)
+	}
+
+	if data := templateData(t, d3g, RenderOptions{HighlightSCCs: true}); data["HighlightSCCs"] != true {
+		t.Errorf("expected SCC highlighting to be enabled, got %v", data["HighlightSCCs"])
+	}
+	if !contains(htmlTemplate, "const highlightSCCs = {{.HighlightSCCs}};") {
+		t.Error("expected the page to read the SCC highlighting option")
+	}
+	if !contains(string(html), ": highlightSCCs ? sccFill(d)") {
+		t.Error("expected node fill to follow the SCC colors")
+	}
+}
@@ -1,14 +1,79 @@
 // Package d3 provides types and functions for generating D3.js visualizations.
 package d3
 
+import (
+	"encoding/json"
+	"slices"
+)
+
 // Graph represents a graph structure for D3 force simulation.
 type Graph struct {
-	Nodes     []Node     `json:"nodes"`
+	Nodes     []Node     `json:"nodes"` // In declaration order
 	Links     []Link     `json:"links"`
 	Directed  bool       `json:"directed"`
 	Strict    bool       `json:"strict,omitempty"`
 	GraphID   string     `json:"graphId,omitempty"`
 	Subgraphs []Subgraph `json:"subgraphs,omitempty"`
+
+	// EdgeStyle is the edge routing derived from the graph's splines
+	// attribute: "straight", "curved", or "ortho".
+	EdgeStyle  string            `json:"edgeStyle,omitempty"`
+	Size       *Size             `json:"size,omitempty"`       // From the size attribute
+	Ratio      string            `json:"ratio,omitempty"`      // From the ratio attribute
+	BgColor    string            `json:"bgcolor,omitempty"`    // Canvas background, from the bgcolor attribute
+	Layers     []string          `json:"layers,omitempty"`     // Layer names, from the layers attribute
+	Compound   bool              `json:"compound,omitempty"`   // From the compound attribute: links are clipped at their LHead and LTail clusters
+	Attributes map[string]string `json:"attributes,omitempty"` // Graph-level attributes
+	Meta       *Meta             `json:"meta,omitempty"`       // Statistics, when converted with ConvertOptions.Meta
+}
+
+// MarshalJSON encodes g with its colors converted to CSS colors, as the
+// rendered page draws them. The fields themselves keep the Graphviz values.
+func (g Graph) MarshalJSON() ([]byte, error) {
+	type plain Graph // without this method
+	c := g.clone()
+	normalizeColors(c)
+	return json.Marshal((*plain)(c))
+}
+
+// clone returns a copy of g whose nodes, links and subgraphs can be changed
+// without changing g.
+func (g *Graph) clone() *Graph {
+	c := *g
+	c.Nodes = slices.Clone(g.Nodes)
+	for i := range c.Nodes {
+		c.Nodes[i].FillStops = slices.Clone(c.Nodes[i].FillStops)
+	}
+	c.Links = slices.Clone(g.Links)
+	c.Subgraphs = slices.Clone(g.Subgraphs)
+	return &c
+}
+
+// Meta holds summary statistics of a graph, for consumers of the JSON
+// output that would otherwise compute them.
+type Meta struct {
+	Directed  bool    `json:"directed"`
+	Strict    bool    `json:"strict"`
+	NodeCount int     `json:"nodeCount"`
+	EdgeCount int     `json:"edgeCount"`
+	Density   float64 `json:"density"`   // Edges over possible edges between distinct nodes; 0 for fewer than 2 nodes
+	MaxDegree int     `json:"maxDegree"` // Most edges incident to a single node
+}
+
+// Size is a drawing size in inches, parsed from a Graphviz size attribute
+// such as "8,6" or "8,6!".
+type Size struct {
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Fill   bool    `json:"fill,omitempty"` // "!" suffix: scale up to fill the size
+}
+
+// Pos is a node position from a Graphviz pos attribute such as "36,-18!",
+// in page coordinates: points are drawn as pixels and y points down.
+type Pos struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Pinned bool    `json:"pinned,omitempty"` // "!" suffix: the layout doesn't move the node
 }
 
 // Node represents a node for D3 visualization.
@@ -17,23 +82,80 @@ type Node struct {
 	Label       string            `json:"label,omitempty"`
 	Color       string            `json:"color,omitempty"`     // Border/stroke color
 	FillColor   string            `json:"fillColor,omitempty"` // Fill color
+	FillStops   []string          `json:"fillStops,omitempty"` // Gradient colors from a fillcolor list like "yellow:orange"
 	Shape       string            `json:"shape,omitempty"`
+	Decorated   bool              `json:"decorated,omitempty"` // Mdiamond/Msquare/Mcircle corner marks on Shape
 	Style       string            `json:"style,omitempty"`
+	Rounded     bool              `json:"rounded,omitempty"` // style includes rounded: box corners are rounded
+	Class       string            `json:"class,omitempty"`   // CSS classes from the class attribute
 	Group       string            `json:"group,omitempty"`
+	Image       string            `json:"image,omitempty"`       // Image URL or data: URI drawn inside the node
+	Peripheries int               `json:"peripheries,omitempty"` // Number of outlines; 0 means the default of one
+	NoOutline   bool              `json:"noOutline,omitempty"`   // peripheries=0: the shape is drawn without an outline
+	NoLabel     bool              `json:"noLabel,omitempty"`     // shape=point: no label is drawn on the node
+	Sides       int               `json:"sides,omitempty"`       // shape=polygon side count; 0 means the default of 4
+	Orientation float64           `json:"orientation,omitempty"` // shape=polygon rotation in degrees
+	Skew        float64           `json:"skew,omitempty"`        // shape=polygon shear; positive moves the top right
+	FontColor   string            `json:"fontColor,omitempty"`   // Label text color
+	FontSize    float64           `json:"fontSize,omitempty"`    // Label size in px; 0 means the default
+	PenWidth    float64           `json:"penWidth,omitempty"`    // Outline width in px; 0 means the default
+	Width       float64           `json:"width,omitempty"`       // Minimum width in inches (72px each); 0 means the default
+	Height      float64           `json:"height,omitempty"`      // Minimum height in inches (72px each); 0 means the default
+	FixedSize   bool              `json:"fixedSize,omitempty"`   // Width and Height are exact: the shape doesn't grow and the label is clipped
+	Betweenness float64           `json:"betweenness,omitempty"` // Set when rendering with SizeByCentrality
+	SCC         int               `json:"scc,omitempty"`         // 1-based index of the node's cyclic component, set when rendering with HighlightSCCs
+	LabelLines  []string          `json:"labelLines,omitempty"`  // Set when rendering a multiline or wrapped label
+	Layers      []string          `json:"layers,omitempty"`      // Layers the node is drawn in, from its layer attribute; empty means all
+	Pos         *Pos              `json:"pos,omitempty"`         // Starting position, from the pos attribute
 	Attributes  map[string]string `json:"attributes,omitempty"`
 	OnPath      bool              `json:"onPath,omitempty"`      // Node is part of highlighted path
 	PathInvalid bool              `json:"pathInvalid,omitempty"` // Red highlight - last valid node before error
+
+	// Set by the converter when Label and Group were derived from the ID
+	// and the enclosing subgraph rather than set, so Attr reports them unset
+	labelFromID       bool
+	groupFromSubgraph bool
 }
 
 // Link represents an edge for D3 visualization.
 type Link struct {
-	Source     string            `json:"source"`
-	Target     string            `json:"target"`
-	Label      string            `json:"label,omitempty"`
-	Color      string            `json:"color,omitempty"`
-	Style      string            `json:"style,omitempty"`
-	Attributes map[string]string `json:"attributes,omitempty"`
-	OnPath     bool              `json:"onPath,omitempty"` // Edge is part of highlighted path
+	Source         string            `json:"source"`
+	Target         string            `json:"target"`
+	Label          string            `json:"label,omitempty"`
+	Color          string            `json:"color,omitempty"`
+	Style          string            `json:"style,omitempty"`
+	Class          string            `json:"class,omitempty"`          // CSS classes from the class attribute
+	Tapered        bool              `json:"tapered,omitempty"`        // style includes tapered: drawn as a wedge narrowing toward the target
+	Operator       string            `json:"operator,omitempty"`       // "->" or "--" when it doesn't match the graph type
+	Bidirectional  bool              `json:"bidirectional,omitempty"`  // A directed edge whose reverse is also in the graph: the pair is drawn as one double arrow
+	MinLen         int               `json:"minlen,omitempty"`         // Minimum rank span; 0 means the default of 1
+	Flat           bool              `json:"flat,omitempty"`           // minlen=0: the target may share the source's rank
+	LHead          string            `json:"lhead,omitempty"`          // Cluster the edge ends at, with compound=true: it stops at the cluster's boundary
+	LTail          string            `json:"ltail,omitempty"`          // Cluster the edge starts from, with compound=true: it starts at the cluster's boundary
+	Order          int               `json:"order,omitempty"`          // Position among the edges declared from the same source, from 0
+	FontColor      string            `json:"fontColor,omitempty"`      // Label text color
+	LabelFontColor string            `json:"labelFontColor,omitempty"` // Label text color from labelfontcolor, used when FontColor is unset
+	FontSize       float64           `json:"fontSize,omitempty"`       // Label size in px; 0 means the default
+	PenWidth       float64           `json:"penWidth,omitempty"`       // Stroke width in px from penwidth; 0 means the default
+	Count          int               `json:"count,omitempty"`          // Parallel edges merged by CollapseParallel; 0 if not merged
+	Width          float64           `json:"width,omitempty"`          // Stroke width in px scaled from weight, set when rendering with WidthByWeight
+	Layers         []string          `json:"layers,omitempty"`         // Layers the edge is drawn in, from its layer attribute; empty means all
+	SourcePort     string            `json:"sourcePort,omitempty"`     // From A:port or tailport
+	SourceCompass  string            `json:"sourceCompass,omitempty"`  // From A:port:n, A:n or tailport
+	TargetPort     string            `json:"targetPort,omitempty"`     // From B:port or headport
+	TargetCompass  string            `json:"targetCompass,omitempty"`  // From B:port:n, B:n or headport
+	Attributes     map[string]string `json:"attributes,omitempty"`
+	OnPath         bool              `json:"onPath,omitempty"`    // Edge is part of highlighted path
+	PathIndex      int               `json:"pathIndex,omitempty"` // 1-based index of the first path through the edge, when rendering with PathFrom and PathTo
+}
+
+// LinkDirected reports whether l is drawn with an arrow: its own operator
+// when it differs from the graph type, otherwise the graph's.
+func (g *Graph) LinkDirected(l Link) bool {
+	if l.Operator != "" {
+		return l.Operator == "->"
+	}
+	return g.Directed
 }
 
 // Subgraph represents subgraph grouping information.
@@ -51,6 +173,9 @@ type PathValidationResult struct {
 	Error         string       `json:"error,omitempty"`
 	InvalidEdge   *InvalidEdge `json:"invalidEdge,omitempty"`
 	LastValidNode string       `json:"lastValidNode,omitempty"`
+	// MissingEdges lists path edges between known nodes that the graph
+	// lacks. They are not highlighted but do not invalidate the path.
+	MissingEdges []string `json:"missingEdges,omitempty"`
 }
 
 // InvalidEdge describes an edge that failed validation.
@@ -0,0 +1,109 @@
+package d3
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Graphviz's default layersep and layerlistsep: the characters separating
+// names in the layers attribute and range ends in a layer attribute, and
+// the characters separating the items of a layer attribute.
+const (
+	defaultLayerSep     = ":\t "
+	defaultLayerListSep = ","
+)
+
+// splitLayers splits the graph's layers attribute into layer names.
+func splitLayers(layers, layerSep string) []string {
+	if layerSep == "" {
+		layerSep = defaultLayerSep
+	}
+	return strings.FieldsFunc(layers, func(r rune) bool {
+		return strings.ContainsRune(layerSep, r)
+	})
+}
+
+// resolveLayers returns the layers selected by a node or edge's layer
+// attribute. Each item of the list is "all", a layer name, a 1-based layer
+// number, or a range of two of those ("l1:l3"). Unknown layers are
+// ignored; nil means the element is drawn in every layer.
+func resolveLayers(spec string, layers []string, layerSep, layerListSep string) []string {
+	if spec == "" || len(layers) == 0 {
+		return nil
+	}
+	if layerListSep == "" {
+		layerListSep = defaultLayerListSep
+	}
+
+	// index returns the position of a range end, or -1 if it's unknown
+	index := func(name string, all int) int {
+		if name == "all" {
+			return all
+		}
+		for i, l := range layers {
+			if l == name {
+				return i
+			}
+		}
+		if n, err := strconv.Atoi(name); err == nil && n >= 1 && n <= len(layers) {
+			return n - 1
+		}
+		return -1
+	}
+
+	selected := make([]bool, len(layers))
+	items := strings.FieldsFunc(spec, func(r rune) bool {
+		return strings.ContainsRune(layerListSep, r)
+	})
+	for _, item := range items {
+		ends := splitLayers(item, layerSep)
+		var lo, hi int
+		switch len(ends) {
+		case 1:
+			if ends[0] == "all" {
+				lo, hi = 0, len(layers)-1
+			} else {
+				lo = index(ends[0], -1)
+				hi = lo
+			}
+		case 2:
+			lo = index(ends[0], 0)
+			hi = index(ends[1], len(layers)-1)
+		default:
+			continue
+		}
+		if lo < 0 || hi < 0 {
+			continue
+		}
+		for i := min(lo, hi); i <= max(lo, hi); i++ {
+			selected[i] = true
+		}
+	}
+
+	var resolved []string
+	for i, ok := range selected {
+		if ok {
+			resolved = append(resolved, layers[i])
+		}
+	}
+	return resolved
+}
+
+// applyLayers fills in the graph's Layers and the Layers of its nodes and
+// links from their layer attributes.
+func (g *Graph) applyLayers(attrs map[string]string) {
+	layers := splitLayers(attrs["layers"], attrs["layersep"])
+	if len(layers) == 0 {
+		return
+	}
+	g.Layers = layers
+	sep, listSep := attrs["layersep"], attrs["layerlistsep"]
+	for i := range g.Nodes {
+		n := &g.Nodes[i]
+		n.Layers = resolveLayers(n.Attributes["layer"], g.Layers, sep, listSep)
+	}
+	for i := range g.Links {
+		l := &g.Links[i]
+		l.Layers = resolveLayers(l.Attributes["layer"], g.Layers, sep, listSep)
+	}
+}
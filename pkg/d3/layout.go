@@ -0,0 +1,203 @@
+package d3
+
+import "sort"
+
+// Ranks assigns every node a layer for hierarchical layouts. Nodes with no
+// incoming edges start at rank 0, and each edge's target is placed at least
+// MinLen ranks (1 when unset, 0 for Flat edges) below its source. Edges that
+// close a cycle are ignored so the assignment always terminates. Which edge
+// that is depends only on the order of Nodes and Links, so the same graph
+// always gets the same ranks.
+//
+// Ranks is for callers laying the graph out themselves: the rendered page
+// uses a force layout and doesn't place nodes by rank.
+func (g *Graph) Ranks() map[string]int {
+	out := make(map[string][]Link)
+	for _, l := range g.Links {
+		out[l.Source] = append(out[l.Source], l)
+	}
+
+	// Depth-first search in node order, keeping only edges that don't point
+	// back at a node still on the stack. The reversed finish order is a
+	// topological order of what remains.
+	const (
+		unvisited = iota
+		active
+		done
+	)
+	state := make(map[string]int)
+	var order []string
+	forward := make(map[string][]Link)
+
+	var visit func(id string)
+	visit = func(id string) {
+		state[id] = active
+		for _, l := range out[id] {
+			switch state[l.Target] {
+			case active:
+				continue // back edge
+			case unvisited:
+				visit(l.Target)
+			}
+			forward[id] = append(forward[id], l)
+		}
+		state[id] = done
+		order = append(order, id)
+	}
+
+	// Start from nodes without incoming edges, then from edge sources in
+	// declaration order so cycles break at the first edge written, and
+	// finally from whatever is left
+	hasIncoming := make(map[string]bool)
+	for _, l := range g.Links {
+		hasIncoming[l.Target] = true
+	}
+	var starts []string
+	for _, n := range g.Nodes {
+		if !hasIncoming[n.ID] {
+			starts = append(starts, n.ID)
+		}
+	}
+	for _, l := range g.Links {
+		starts = append(starts, l.Source)
+	}
+	for _, n := range g.Nodes {
+		starts = append(starts, n.ID)
+	}
+
+	for _, id := range starts {
+		if state[id] == unvisited {
+			visit(id)
+		}
+	}
+
+	ranks := make(map[string]int, len(order))
+	for i := len(order) - 1; i >= 0; i-- {
+		id := order[i]
+		for _, l := range forward[id] {
+			minLen := l.MinLen
+			if minLen == 0 && !l.Flat {
+				minLen = 1
+			}
+			if r := ranks[id] + minLen; r > ranks[l.Target] {
+				ranks[l.Target] = r
+			}
+		}
+	}
+
+	return ranks
+}
+
+// RankOrder lists the nodes of each rank from Ranks, left to right. With
+// the graph attribute ordering=out, each node's children follow it in the
+// order their edges were declared (Link.Order), and nodes that start no
+// ranked chain come first in order of their first mention in an edge.
+// Otherwise each rank keeps graph order.
+//
+// Like Ranks, RankOrder is only for callers laying the graph out
+// themselves; the rendered page ignores ordering=out.
+func (g *Graph) RankOrder() [][]string {
+	if len(g.Nodes) == 0 {
+		return nil
+	}
+	ranks := g.Ranks()
+	maxRank := 0
+	for _, r := range ranks {
+		maxRank = max(maxRank, r)
+	}
+	order := make([][]string, maxRank+1)
+	placed := make(map[string]bool, len(g.Nodes))
+	place := func(id string) {
+		if !placed[id] {
+			placed[id] = true
+			order[ranks[id]] = append(order[ranks[id]], id)
+		}
+	}
+
+	if g.Attributes["ordering"] == "out" {
+		children := make(map[string][]Link)
+		for _, l := range g.Links {
+			children[l.Source] = append(children[l.Source], l)
+		}
+		for _, c := range children {
+			sort.SliceStable(c, func(i, j int) bool { return c[i].Order < c[j].Order })
+		}
+
+		// Roots by first mention, so declaration order decides them too
+		for _, l := range g.Links {
+			for _, id := range []string{l.Source, l.Target} {
+				if ranks[id] == 0 {
+					place(id)
+				}
+			}
+		}
+		for _, n := range g.Nodes {
+			if ranks[n.ID] == 0 {
+				place(n.ID)
+			}
+		}
+
+		// Place each node's children under it, walking the placed nodes
+		// rank by rank, so a child goes under its first parent in placement
+		// order. A rank is complete by the time the walk reaches it, since
+		// its nodes are placed from lower ranks
+		for r := 0; r <= maxRank; r++ {
+			for _, parent := range order[r] {
+				for _, l := range children[parent] {
+					if ranks[l.Target] > r {
+						place(l.Target)
+					}
+				}
+			}
+		}
+	}
+
+	for _, n := range g.Nodes {
+		place(n.ID)
+	}
+	return order
+}
+
+// markBidirectional sets Bidirectional on each directed link whose reverse
+// is also a directed link. Undirected links and self-loops have no reverse.
+func (g *Graph) markBidirectional() {
+	type pair struct{ source, target string }
+	directed := make(map[pair]bool)
+	for _, l := range g.Links {
+		if g.LinkDirected(l) {
+			directed[pair{l.Source, l.Target}] = true
+		}
+	}
+	for i := range g.Links {
+		l := &g.Links[i]
+		l.Bidirectional = l.Source != l.Target && g.LinkDirected(*l) && directed[pair{l.Target, l.Source}]
+	}
+}
+
+// CollapseParallel merges parallel edges into a single link whose Count is
+// the number of edges merged. The first edge of each group keeps its
+// attributes. In undirected graphs A -- B and B -- A are parallel.
+func (g *Graph) CollapseParallel() {
+	type pair struct{ source, target string }
+	index := make(map[pair]int)
+	links := g.Links[:0]
+
+	for _, l := range g.Links {
+		key := pair{l.Source, l.Target}
+		if !g.Directed && key.target < key.source {
+			key = pair{key.target, key.source}
+		}
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(links)
+			links = append(links, l)
+			continue
+		}
+		if links[i].Count == 0 {
+			links[i].Count = 1
+		}
+		links[i].Count++
+		links[i].OnPath = links[i].OnPath || l.OnPath
+	}
+	g.Links = links
+}
@@ -0,0 +1,260 @@
+package d3
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConvertMinLen(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B [minlen=3]; B -> C; C -> D [minlen=x]; D -> E [minlen=0] }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if d3g.Links[0].MinLen != 3 {
+		t.Errorf("expected MinLen 3, got %d", d3g.Links[0].MinLen)
+	}
+	if d3g.Links[1].MinLen != 0 {
+		t.Errorf("expected default MinLen 0, got %d", d3g.Links[1].MinLen)
+	}
+	if d3g.Links[2].MinLen != 0 || d3g.Links[2].Attributes["minlen"] != "x" {
+		t.Errorf("expected invalid minlen to stay in attributes, got %+v", d3g.Links[2])
+	}
+	if l := d3g.Links[3]; l.MinLen != 0 || !l.Flat || l.Attr("minlen") != "0" {
+		t.Errorf("expected minlen=0 to make a flat edge, got %+v", l)
+	}
+}
+
+func TestRanks(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  map[string]int
+	}{
+		{"chain", `digraph { A -> B -> C }`, map[string]int{"A": 0, "B": 1, "C": 2}},
+		{"minlen gap", `digraph { A -> B [minlen=3]; B -> C }`, map[string]int{"A": 0, "B": 3, "C": 4}},
+		{"longest path wins", `digraph { A -> B -> C; A -> C }`, map[string]int{"A": 0, "B": 1, "C": 2}},
+		{"minlen on shortcut", `digraph { A -> B -> C; A -> C [minlen=4] }`, map[string]int{"A": 0, "B": 1, "C": 4}},
+		{"cycle", `digraph { A -> B -> C -> A }`, map[string]int{"A": 0, "B": 1, "C": 2}},
+		{"isolated", `digraph { A; B -> C }`, map[string]int{"A": 0, "B": 0, "C": 1}},
+		{"flat", `digraph { A -> B [minlen=0]; B -> C }`, map[string]int{"A": 0, "B": 0, "C": 1}},
+		{"cycle entered twice", `digraph { S1 -> A; S2 -> B; A -> B; B -> A }`, map[string]int{"S1": 0, "S2": 0, "A": 1, "B": 2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, tt.input))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+
+			ranks := d3g.Ranks()
+			for id, want := range tt.want {
+				if ranks[id] != want {
+					t.Errorf("%s: expected rank %d, got %d", id, want, ranks[id])
+				}
+			}
+		})
+	}
+}
+
+func TestRanksDeterministic(t *testing.T) {
+	// Which of A -> B and B -> A is dropped to break the cycle must not
+	// depend on map iteration order
+	src := `digraph { S1 -> A; S2 -> B; A -> B; B -> A; S3 -> C; C -> D; D -> C }`
+	var first map[string]int
+	var firstOrder [][]string
+	for i := 0; i < 50; i++ {
+		d3g, err := Convert(parse(t, src))
+		if err != nil {
+			t.Fatalf("convert error: %v", err)
+		}
+		ranks, order := d3g.Ranks(), d3g.RankOrder()
+		if first == nil {
+			first, firstOrder = ranks, order
+			continue
+		}
+		if !reflect.DeepEqual(ranks, first) {
+			t.Fatalf("run %d: expected ranks %v, got %v", i, first, ranks)
+		}
+		if !reflect.DeepEqual(order, firstOrder) {
+			t.Fatalf("run %d: expected rank order %v, got %v", i, firstOrder, order)
+		}
+	}
+}
+
+func TestRankOrder(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		ordering=out
+		R -> C; R -> A; R -> B
+		A -> A2; C -> C2
+		B -> B2; R -> B2
+		S -> Z
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	order := d3g.RankOrder()
+	// B2 is placed under R, its first parent, ahead of the rank's others
+	want := []string{"R S", "C A B Z", "B2 C2 A2"}
+	if len(order) != len(want) {
+		t.Fatalf("expected %d ranks, got %v", len(want), order)
+	}
+	for r, w := range want {
+		if got := strings.Join(order[r], " "); got != w {
+			t.Errorf("rank %d: expected %q, got %q", r, w, got)
+		}
+	}
+
+	// Without ordering=out the ranks hold the same nodes in graph order
+	delete(d3g.Attributes, "ordering")
+	for r, rank := range d3g.RankOrder() {
+		if len(rank) != len(strings.Fields(want[r])) {
+			t.Errorf("rank %d: expected %q in some order, got %v", r, want[r], rank)
+		}
+	}
+}
+
+func TestCollapseParallel(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B; A -> B [color=red]; A -> B; B -> A; A -> C }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	d3g.CollapseParallel()
+
+	if len(d3g.Links) != 3 {
+		t.Fatalf("expected 3 links, got %d: %+v", len(d3g.Links), d3g.Links)
+	}
+	ab := d3g.Links[0]
+	if ab.Source != "A" || ab.Target != "B" || ab.Count != 3 {
+		t.Errorf("expected A -> B with Count 3, got %+v", ab)
+	}
+	if ab.Color != "" {
+		t.Errorf("expected first edge's attributes, got color %q", ab.Color)
+	}
+	if ba := d3g.Links[1]; ba.Source != "B" || ba.Count != 0 {
+		t.Errorf("expected B -> A kept separate and uncounted, got %+v", ba)
+	}
+
+	u, err := Convert(parse(t, `graph { A -- B; B -- A }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	u.CollapseParallel()
+	if len(u.Links) != 1 || u.Links[0].Count != 2 {
+		t.Errorf("expected undirected A -- B to collapse to Count 2, got %+v", u.Links)
+	}
+}
+
+func TestConvertConcentrate(t *testing.T) {
+	src := `digraph { concentrate=true; A -> B; A -> B; B -> A; A -> C }`
+	d3g, err := Convert(parse(t, src))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if len(d3g.Links) != 3 {
+		t.Fatalf("expected 3 links, got %d: %+v", len(d3g.Links), d3g.Links)
+	}
+	if ab := d3g.Links[0]; ab.Source != "A" || ab.Target != "B" || ab.Count != 2 {
+		t.Errorf("expected A -> B with Count 2, got %+v", ab)
+	}
+	if ba := d3g.Links[1]; ba.Source != "B" || ba.Target != "A" || ba.Count != 0 {
+		t.Errorf("expected B -> A kept separate, got %+v", ba)
+	}
+
+	kept, err := ConvertWithOptions(parse(t, src), ConvertOptions{KeepParallel: true})
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if len(kept.Links) != 4 {
+		t.Errorf("expected KeepParallel to keep all 4 links, got %d", len(kept.Links))
+	}
+
+	off, err := Convert(parse(t, `digraph { concentrate=false; A -> B; A -> B }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if len(off.Links) != 2 {
+		t.Errorf("expected concentrate=false to keep both links, got %d", len(off.Links))
+	}
+}
+
+func TestConvertBidirectional(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want string // Links flagged bidirectional, in order
+	}{
+		{"pair", `digraph { A -> B; B -> A; A -> C }`, "A>B B>A"},
+		{"repeated pair", `digraph { A -> B; A -> B; B -> A }`, "A>B A>B B>A"},
+		{"self-loops", `digraph { A -> A; A -> A }`, ""},
+		{"mixed operators", `digraph { A -> B; B -- A }`, ""},
+		{"undirected", `graph { A -- B; B -- A }`, ""},
+		{"arrows in an undirected graph", `graph { A -> B; B -> A; B -- C }`, "A>B B>A"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, tt.src))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			var got []string
+			for _, l := range d3g.Links {
+				if l.Bidirectional {
+					got = append(got, l.Source+">"+l.Target)
+				}
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("expected bidirectional links %q, got %q", tt.want, strings.Join(got, " "))
+			}
+		})
+	}
+}
+
+func TestRenderBidirectional(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B; B -> A }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !strings.Contains(htmlStr, `"bidirectional":true`) {
+		t.Error("expected the bidirectional flag in the graph data")
+	}
+	if !strings.Contains(htmlStr, `.unified-link.bidirectional {
+            marker-start: url(#arrowhead-reverse);
+            marker-end: url(#arrowhead);`) {
+		t.Error("expected bidirectional unified links to get arrowheads at both ends")
+	}
+	if !strings.Contains(htmlStr, `if (d.isBidirectional) cls += " bidirectional";`) {
+		t.Error("expected bidirectional groups to get the bidirectional class")
+	}
+	if !strings.Contains(htmlStr, `if (linkDirected(l) && sourceId !== targetId) {`) {
+		t.Error("expected only arrows between distinct nodes to count as directions")
+	}
+	if !strings.Contains(htmlStr, `const isBidirectional = pair.directions.size === 2;`) {
+		t.Error("expected a group with arrows both ways to be bidirectional")
+	}
+}
+
+func TestRenderCollapseParallel(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B; A -> B; A -> B }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	html, err := RenderHTML(d3g, RenderOptions{CollapseParallel: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !strings.Contains(string(html), `"count":3`) {
+		t.Error("expected collapsed link with count 3 in graph data")
+	}
+}
@@ -0,0 +1,37 @@
+package d3
+
+// AdjacencyMatrix is a graph's edges as a matrix of counts. Matrix[i][j]
+// is the number of edges from Nodes[i] to Nodes[j].
+type AdjacencyMatrix struct {
+	Nodes  []string `json:"nodes"`
+	Matrix [][]int  `json:"matrix"`
+}
+
+// AdjacencyMatrix returns the graph's adjacency matrix, with nodes in
+// graph order. Undirected edges are counted in both directions, so the
+// matrix is symmetric; a self-loop adds one to the diagonal.
+func (g *Graph) AdjacencyMatrix() AdjacencyMatrix {
+	m := AdjacencyMatrix{
+		Nodes:  make([]string, len(g.Nodes)),
+		Matrix: make([][]int, len(g.Nodes)),
+	}
+	index := make(map[string]int, len(g.Nodes))
+	for i, n := range g.Nodes {
+		m.Nodes[i] = n.ID
+		m.Matrix[i] = make([]int, len(g.Nodes))
+		index[n.ID] = i
+	}
+
+	for _, l := range g.Links {
+		s, sok := index[l.Source]
+		t, tok := index[l.Target]
+		if !sok || !tok {
+			continue
+		}
+		m.Matrix[s][t]++
+		if !g.Directed && s != t {
+			m.Matrix[t][s]++
+		}
+	}
+	return m
+}
@@ -0,0 +1,149 @@
+package d3
+
+import (
+	"slices"
+	"sort"
+)
+
+// MaxPathSteps bounds the edges AllPaths follows in its search. Dead ends
+// that can still reach the destination, such as a clique hanging off a
+// node already on the path, would otherwise be walked exhaustively.
+const MaxPathSteps = 200000
+
+// AllPaths returns every simple path from src to dst with at most maxLen
+// edges, or of any length when maxLen is zero or negative, each as the
+// node IDs along it. Edges are followed from source to target, and both
+// ways where undirected; parallel edges give a single path. Paths are
+// sorted by length, then by node IDs. It returns nil if either node is not
+// in the graph.
+//
+// A graph can have exponentially many paths between two nodes, so the
+// search stops once it has found maxPaths of them, when maxPaths is
+// positive, or once it has stepped along MaxPathSteps edges, however many
+// paths it has found; which paths are kept then depends on the edge order.
+func (g *Graph) AllPaths(src, dst string, maxLen, maxPaths int) [][]string {
+	if !g.hasNode(src) || !g.hasNode(dst) {
+		return nil
+	}
+
+	neighbors := make(map[string][]string)
+	seen := make(map[[2]string]bool)
+	add := func(from, to string) {
+		if !seen[[2]string{from, to}] {
+			seen[[2]string{from, to}] = true
+			neighbors[from] = append(neighbors[from], to)
+		}
+	}
+	for _, l := range g.Links {
+		add(l.Source, l.Target)
+		if !g.LinkDirected(l) {
+			add(l.Target, l.Source)
+		}
+	}
+
+	// Only nodes that can reach dst are worth visiting, so dead ends don't
+	// count against the search
+	reverse := make(map[string][]string)
+	for from, tos := range neighbors {
+		for _, to := range tos {
+			reverse[to] = append(reverse[to], from)
+		}
+	}
+	reaches := map[string]bool{dst: true}
+	queue := []string{dst}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		for _, prev := range reverse[id] {
+			if !reaches[prev] {
+				reaches[prev] = true
+				queue = append(queue, prev)
+			}
+		}
+	}
+
+	var paths [][]string
+	path := []string{src}
+	onPath := map[string]bool{src: true}
+	steps := 0
+	var visit func(id string)
+	visit = func(id string) {
+		steps++
+		if id == dst {
+			paths = append(paths, slices.Clone(path))
+			return
+		}
+		if maxLen > 0 && len(path) > maxLen {
+			return
+		}
+		for _, next := range neighbors[id] {
+			if maxPaths > 0 && len(paths) >= maxPaths || steps >= MaxPathSteps {
+				return
+			}
+			if onPath[next] || !reaches[next] {
+				continue
+			}
+			onPath[next] = true
+			path = append(path, next)
+			visit(next)
+			path = path[:len(path)-1]
+			onPath[next] = false
+		}
+	}
+	visit(src)
+
+	sort.Slice(paths, func(i, j int) bool {
+		if len(paths[i]) != len(paths[j]) {
+			return len(paths[i]) < len(paths[j])
+		}
+		return slices.Compare(paths[i], paths[j]) < 0
+	})
+	return paths
+}
+
+// hasNode reports whether the graph has a node with the given ID.
+func (g *Graph) hasNode(id string) bool {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// highlightPaths marks the nodes and links along paths as on the path, and
+// sets each link's PathIndex to the 1-based index of the first path that
+// uses it.
+func (g *Graph) highlightPaths(paths [][]string) {
+	onPath := make(map[string]bool)
+	for _, p := range paths {
+		for _, id := range p {
+			onPath[id] = true
+		}
+	}
+	for i := range g.Nodes {
+		if onPath[g.Nodes[i].ID] {
+			g.Nodes[i].OnPath = true
+		}
+	}
+
+	step := make(map[[2]string]int)
+	for i, p := range paths {
+		for j := 1; j < len(p); j++ {
+			if _, ok := step[[2]string{p[j-1], p[j]}]; !ok {
+				step[[2]string{p[j-1], p[j]}] = i + 1
+			}
+		}
+	}
+	for i := range g.Links {
+		l := &g.Links[i]
+		index, ok := step[[2]string{l.Source, l.Target}]
+		if reverse, rok := step[[2]string{l.Target, l.Source}]; rok && !g.LinkDirected(*l) && (!ok || reverse < index) {
+			index, ok = reverse, true
+		}
+		if ok {
+			l.OnPath = true
+			l.PathIndex = index
+		}
+	}
+}
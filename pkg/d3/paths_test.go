@@ -0,0 +1,160 @@
+package d3
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// joinPaths formats paths as "A>B>C" strings separated by spaces.
+func joinPaths(paths [][]string) string {
+	var s []string
+	for _, p := range paths {
+		s = append(s, strings.Join(p, ">"))
+	}
+	return strings.Join(s, " ")
+}
+
+func TestAllPaths(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		A -> B -> D
+		A -> C -> D
+		A -> D
+		B -> C
+		C -> A
+		A -> B
+		D -> E
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		src, dst string
+		maxLen   int
+		want     string
+	}{
+		{"all", "A", "D", 0, "A>D A>B>D A>C>D A>B>C>D"},
+		{"bounded", "A", "D", 2, "A>D A>B>D A>C>D"},
+		{"single edge", "A", "D", 1, "A>D"},
+		{"against direction", "D", "A", 0, ""},
+		{"through a cycle", "C", "B", 0, "C>A>B"},
+		{"same node", "A", "A", 0, "A"},
+		{"unknown node", "A", "X", 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := joinPaths(d3g.AllPaths(tt.src, tt.dst, tt.maxLen, 0))
+			if got != tt.want {
+				t.Errorf("expected paths %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAllPathsUndirected(t *testing.T) {
+	d3g, err := Convert(parse(t, `graph { A -- B; C -- B; A -- C }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if got := joinPaths(d3g.AllPaths("C", "A", 0, 0)); got != "C>A C>B>A" {
+		t.Errorf("expected undirected edges to be followed both ways, got %q", got)
+	}
+}
+
+func TestAllPathsMaxPaths(t *testing.T) {
+	// Each of the 10 layers doubles the number of paths from S to T
+	var src strings.Builder
+	src.WriteString("digraph { ")
+	prev := []string{"S"}
+	for i := 0; i < 10; i++ {
+		layer := []string{fmt.Sprintf("a%d", i), fmt.Sprintf("b%d", i)}
+		for _, from := range prev {
+			for _, to := range layer {
+				fmt.Fprintf(&src, "%s -> %s; ", from, to)
+			}
+		}
+		prev = layer
+	}
+	src.WriteString("a9 -> T; b9 -> T; S -> X; X -> Y }")
+	d3g, err := Convert(parse(t, src.String()))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if got := len(d3g.AllPaths("S", "T", 0, 0)); got != 1024 {
+		t.Errorf("expected 1024 paths without a cap, got %d", got)
+	}
+	if got := len(d3g.AllPaths("S", "T", 0, 5)); got != 5 {
+		t.Errorf("expected the search to stop at 5 paths, got %d", got)
+	}
+}
+
+func TestAllPathsStepBudget(t *testing.T) {
+	// Every clique node leads back to x, which is already on the path, so
+	// neither the reachability pruning nor maxPaths cuts the search short
+	var src strings.Builder
+	src.WriteString("digraph { s -> x -> t; ")
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&src, "x -> c%d; c%d -> x; ", i, i)
+		for j := 0; j < 20; j++ {
+			if i != j {
+				fmt.Fprintf(&src, "c%d -> c%d; ", i, j)
+			}
+		}
+	}
+	src.WriteString("}")
+	d3g, err := Convert(parse(t, src.String()))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	done := make(chan [][]string, 1)
+	go func() { done <- d3g.AllPaths("s", "t", 12, 100) }()
+	select {
+	case paths := <-done:
+		if got := joinPaths(paths); got != "s>x>t" {
+			t.Errorf("expected the one path, got %q", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("expected the search to stop at its step budget")
+	}
+}
+
+func TestRenderPathsBetween(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> B -> D; A -> C -> D; C -> E }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	html, err := RenderHTML(d3g, RenderOptions{PathFrom: "A", PathTo: "D"})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+
+	rendered := renderedGraph(t, html)
+	index := make(map[string]int)
+	for _, l := range rendered.Links {
+		index[l.Source+">"+l.Target] = l.PathIndex
+		if l.OnPath != (l.PathIndex > 0) {
+			t.Errorf("expected %s -> %s to be on the path exactly when it has a path index", l.Source, l.Target)
+		}
+	}
+	if index["A>B"] != 1 || index["B>D"] != 1 || index["A>C"] != 2 || index["C>D"] != 2 || index["C>E"] != 0 {
+		t.Errorf("expected each path's edges to carry its index, got %v", index)
+	}
+	for _, n := range rendered.Nodes {
+		if n.OnPath != (n.ID != "E") {
+			t.Errorf("expected %s on path %v, got %v", n.ID, n.ID != "E", n.OnPath)
+		}
+	}
+	if !contains(string(html), `this.style.setProperty("stroke", pathColor(d.pathIndex), "important");`) {
+		t.Error("expected each path to be drawn in its own color")
+	}
+
+	if _, err := RenderHTML(d3g, RenderOptions{PathFrom: "A", PathTo: "X"}); err == nil {
+		t.Error("expected an error for an unknown path endpoint")
+	}
+}
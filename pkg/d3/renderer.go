@@ -2,8 +2,16 @@ package d3
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/anthonybishopric/dot2d3/pkg/ast"
 )
@@ -11,28 +19,142 @@ import (
 // Converter converts an AST graph to a D3 graph structure.
 type Converter struct {
 	nodes      map[string]*Node
+	nodeOrder  []string // Node IDs in declaration order
 	links      []Link
 	subgraphs  []Subgraph
 	directed   bool
 	strict     bool
 	graphID    string
+	graphAttrs map[string]string
 
 	// Default attributes from attr statements
 	nodeDefaults map[string]string
 	edgeDefaults map[string]string
 
+	// Default attributes of enclosing scopes, restored on leaving a subgraph
+	defaultScopes []defaultScope
+
+	// Edges added so far from each source, for Link.Order
+	outEdges map[string]int
+
+	// Nodes with a group attribute, which takes precedence over their
+	// subgraph for Node.Group
+	explicitGroups map[string]bool
+
 	// Current subgraph context
 	currentSubgraph string
+	subgraphDepth   int
+
+	// Size limits, and the error that stopped conversion on exceeding one
+	// or on ctx being done
+	limits Limits
+	ctx    context.Context
+	err    error
+}
+
+// Limits bounds the size of a converted graph, so that small inputs like
+// {A1 ... A1000} -> {B1 ... B1000} can't allocate without bound.
+type Limits struct {
+	MaxNodes int // zero means DefaultLimits.MaxNodes
+	MaxEdges int // zero means DefaultLimits.MaxEdges
+}
+
+// DefaultLimits are the limits Convert applies: well past what the
+// renderer can usefully draw.
+var DefaultLimits = Limits{MaxNodes: 50000, MaxEdges: 200000}
+
+// ErrLimitExceeded is wrapped by conversion errors for graphs over a limit.
+var ErrLimitExceeded = errors.New("graph size limit exceeded")
+
+// defaultScope holds the node and edge defaults in effect for one
+// brace-delimited scope.
+type defaultScope struct {
+	nodeDefaults map[string]string
+	edgeDefaults map[string]string
+}
+
+// enterScope starts a subgraph scope. Defaults set inside it apply only
+// until the matching leaveScope.
+func (c *Converter) enterScope() {
+	c.defaultScopes = append(c.defaultScopes, defaultScope{
+		nodeDefaults: c.nodeDefaults,
+		edgeDefaults: c.edgeDefaults,
+	})
+	c.nodeDefaults = copyAttrs(c.nodeDefaults)
+	c.edgeDefaults = copyAttrs(c.edgeDefaults)
+	c.subgraphDepth++
+}
+
+// leaveScope restores the defaults saved by the matching enterScope.
+func (c *Converter) leaveScope() {
+	last := c.defaultScopes[len(c.defaultScopes)-1]
+	c.defaultScopes = c.defaultScopes[:len(c.defaultScopes)-1]
+	c.nodeDefaults = last.nodeDefaults
+	c.edgeDefaults = last.edgeDefaults
+	c.subgraphDepth--
 }
 
-// Convert transforms an AST graph into a D3 graph structure.
+func copyAttrs(attrs map[string]string) map[string]string {
+	out := make(map[string]string, len(attrs))
+	for k, v := range attrs {
+		out[k] = v
+	}
+	return out
+}
+
+// Convert transforms an AST graph into a D3 graph structure, within
+// DefaultLimits.
 func Convert(g *ast.Graph) (*Graph, error) {
+	return ConvertWithLimits(g, DefaultLimits)
+}
+
+// ConvertWithLimits is like Convert, but stops with an error wrapping
+// ErrLimitExceeded as soon as the graph exceeds limits.
+func ConvertWithLimits(g *ast.Graph, limits Limits) (*Graph, error) {
+	return ConvertWithOptions(g, ConvertOptions{Limits: limits})
+}
+
+// ConvertOptions configures ConvertWithOptions.
+type ConvertOptions struct {
+	// Limits bounds the graph's size; zero fields mean DefaultLimits.
+	Limits Limits
+
+	// Meta fills in the graph's Meta statistics.
+	Meta bool
+
+	// KeepParallel keeps parallel edges separate even when the graph sets
+	// concentrate=true, which otherwise merges them as CollapseParallel
+	// does.
+	KeepParallel bool
+}
+
+// ConvertWithOptions is like Convert, with the limits and extras in opts.
+func ConvertWithOptions(g *ast.Graph, opts ConvertOptions) (*Graph, error) {
+	return ConvertContext(context.Background(), g, opts)
+}
+
+// ConvertContext is like ConvertWithOptions, but stops with ctx's error
+// once ctx is done.
+func ConvertContext(ctx context.Context, g *ast.Graph, opts ConvertOptions) (*Graph, error) {
+	limits := opts.Limits
+	if limits.MaxNodes <= 0 {
+		limits.MaxNodes = DefaultLimits.MaxNodes
+	}
+	if limits.MaxEdges <= 0 {
+		limits.MaxEdges = DefaultLimits.MaxEdges
+	}
+
 	c := &Converter{
-		nodes:        make(map[string]*Node),
-		directed:     g.Directed,
-		strict:       g.Strict,
-		nodeDefaults: make(map[string]string),
-		edgeDefaults: make(map[string]string),
+		ctx:            ctx,
+		limits:         limits,
+		nodes:          make(map[string]*Node),
+		directed:       g.Directed,
+		strict:         g.Strict,
+		graphAttrs:     make(map[string]string),
+		nodeDefaults:   make(map[string]string),
+		edgeDefaults:   make(map[string]string),
+		outEdges:       make(map[string]int),
+		explicitGroups: make(map[string]bool),
 	}
 
 	if g.ID != nil {
@@ -41,25 +163,273 @@ func Convert(g *ast.Graph) (*Graph, error) {
 
 	// Process all statements
 	c.processStatements(g.Statements, "")
+	if c.err != nil {
+		return nil, c.err
+	}
 
 	// Build the final graph
 	nodes := make([]Node, 0, len(c.nodes))
-	for _, n := range c.nodes {
-		nodes = append(nodes, *n)
+	for _, id := range c.nodeOrder {
+		nodes = append(nodes, *c.nodes[id])
 	}
 
-	return &Graph{
+	d3g := &Graph{
 		Nodes:     nodes,
 		Links:     c.links,
 		Directed:  c.directed,
 		Strict:    c.strict,
 		GraphID:   c.graphID,
 		Subgraphs: c.subgraphs,
-	}, nil
+		EdgeStyle: edgeStyleFromSplines(c.graphAttrs["splines"]),
+		Size:      parseSize(c.graphAttrs["size"]),
+		Ratio:     c.graphAttrs["ratio"],
+		BgColor:   c.graphAttrs["bgcolor"],
+		Compound:  isTrue(c.graphAttrs["compound"]),
+	}
+	if len(c.graphAttrs) > 0 {
+		d3g.Attributes = c.graphAttrs
+	}
+	d3g.applyLayers(c.graphAttrs)
+	if isTrue(c.graphAttrs["concentrate"]) && !opts.KeepParallel {
+		d3g.CollapseParallel()
+	}
+	d3g.markBidirectional()
+	if opts.Meta {
+		d3g.Meta = d3g.ComputeMeta()
+	}
+
+	return d3g, nil
+}
+
+// parseSize parses a Graphviz size value ("8,6", "8,6!", or "7") in inches.
+// It returns nil if the value is empty or malformed.
+func parseSize(value string) *Size {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+
+	size := &Size{}
+	if strings.HasSuffix(value, "!") {
+		size.Fill = true
+		value = strings.TrimSuffix(value, "!")
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) > 2 {
+		return nil
+	}
+	// Dimensions must stay finite once converted to points for the viewBox
+	dimension := func(s string) (float64, bool) {
+		f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		return f, err == nil && f > 0 && !math.IsInf(f*pointsPerInch, 0)
+	}
+	w, ok := dimension(parts[0])
+	if !ok {
+		return nil
+	}
+	h := w // a single value applies to both dimensions
+	if len(parts) == 2 {
+		if h, ok = dimension(parts[1]); !ok {
+			return nil
+		}
+	}
+
+	size.Width = w
+	size.Height = h
+	return size
+}
+
+// pointsPerInch converts Graphviz inch sizes to canvas units.
+const pointsPerInch = 72
+
+// canvasSize returns the fixed canvas dimensions for a render, or 0, 0 to
+// fill the browser window. Explicit option dimensions win over the graph's
+// size attribute; a numeric ratio (height/width) then adjusts the aspect
+// while staying within the size.
+func canvasSize(g *Graph, opts RenderOptions) (int, int) {
+	if opts.Width > 0 && opts.Height > 0 {
+		return opts.Width, opts.Height
+	}
+	if g.Size == nil {
+		return 0, 0
+	}
+
+	w := g.Size.Width * pointsPerInch
+	h := g.Size.Height * pointsPerInch
+	if r, err := strconv.ParseFloat(g.Ratio, 64); err == nil && r > 0 {
+		if w*r <= h {
+			h = w * r
+		} else {
+			w = h / r
+		}
+	}
+	return int(math.Round(w)), int(math.Round(h))
+}
+
+// wrapLabel splits a label into lines at newlines and, when width is
+// positive, wraps each line at word boundaries to at most width characters.
+// Words longer than width are kept whole on their own line.
+func wrapLabel(label string, width int) []string {
+	var lines []string
+	for _, line := range strings.Split(label, "\n") {
+		if width <= 0 {
+			lines = append(lines, line)
+			continue
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			lines = append(lines, "")
+			continue
+		}
+		current := words[0]
+		for _, w := range words[1:] {
+			if utf8.RuneCountInString(current)+1+utf8.RuneCountInString(w) > width {
+				lines = append(lines, current)
+				current = w
+				continue
+			}
+			current += " " + w
+		}
+		lines = append(lines, current)
+	}
+	return lines
+}
+
+// colorRange is the span of a numeric node attribute that the template
+// maps onto a sequential color scale.
+type colorRange struct {
+	Attribute string
+	Min, Max  float64
+}
+
+// attributeRange returns the range of attr's numeric values across the
+// graph's nodes, or nil if no node has a numeric value for it.
+func attributeRange(g *Graph, attr string) *colorRange {
+	var r *colorRange
+	for _, n := range g.Nodes {
+		v, err := strconv.ParseFloat(n.Attributes[attr], 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		if r == nil {
+			r = &colorRange{Attribute: attr, Min: v, Max: v}
+			continue
+		}
+		r.Min = math.Min(r.Min, v)
+		r.Max = math.Max(r.Max, v)
+	}
+	return r
+}
+
+// Stroke widths, in px, of the lightest and heaviest edges drawn with
+// RenderOptions.WidthByWeight.
+const (
+	minWeightWidth = 1
+	maxWeightWidth = 8
+)
+
+// applyWeightWidths sets each link's Width on a linear scale from its
+// numeric weight attribute, between minWeightWidth for the lowest weight in
+// the graph and maxWeightWidth for the highest. Links without a numeric
+// weight, and all links when every weight is the same, keep the default.
+func applyWeightWidths(g *Graph) {
+	weights := make([]float64, len(g.Links))
+	valid := make([]bool, len(g.Links))
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, l := range g.Links {
+		v, err := strconv.ParseFloat(l.Attributes["weight"], 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			continue
+		}
+		weights[i], valid[i] = v, true
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if !(hi > lo) {
+		return
+	}
+	for i := range g.Links {
+		if valid[i] {
+			t := (weights[i] - lo) / (hi - lo)
+			g.Links[i].Width = minWeightWidth + t*(maxWeightWidth-minWeightWidth)
+		}
+	}
+}
+
+// maxEdgeFilterValues is the most distinct values an edge attribute can
+// have and still be offered as toggles by RenderOptions.EdgeFilter.
+const maxEdgeFilterValues = 20
+
+// edgeFilter lists the distinct values of one edge attribute.
+type edgeFilter struct {
+	Attribute string   `json:"attribute"`
+	Values    []string `json:"values"`
+}
+
+// edgeFilters returns the distinct values of each edge attribute, sorted by
+// attribute and then value. Labels and attributes with more than
+// maxEdgeFilterValues values are skipped.
+func edgeFilters(g *Graph) []edgeFilter {
+	values := make(map[string]map[string]bool)
+	add := func(attr, value string) {
+		if value == "" {
+			return
+		}
+		if values[attr] == nil {
+			values[attr] = make(map[string]bool)
+		}
+		values[attr][value] = true
+	}
+	for _, l := range g.Links {
+		add("style", l.Style)
+		add("color", l.Color)
+		add("class", l.Class)
+		for k, v := range l.Attributes {
+			add(k, v)
+		}
+	}
+
+	var filters []edgeFilter
+	for attr, set := range values {
+		if len(set) > maxEdgeFilterValues {
+			continue
+		}
+		f := edgeFilter{Attribute: attr}
+		for v := range set {
+			f.Values = append(f.Values, v)
+		}
+		sort.Strings(f.Values)
+		filters = append(filters, f)
+	}
+	sort.Slice(filters, func(i, j int) bool {
+		return filters[i].Attribute < filters[j].Attribute
+	})
+	return filters
+}
+
+// edgeStyleFromSplines maps a Graphviz splines value onto one of the
+// renderer's edge styles. Unknown or empty values return "".
+func edgeStyleFromSplines(splines string) string {
+	switch strings.ToLower(splines) {
+	case "true", "spline", "curved":
+		return "curved"
+	case "false", "line", "polyline", "none":
+		return "straight"
+	case "ortho":
+		return "ortho"
+	default:
+		return ""
+	}
 }
 
 func (c *Converter) processStatements(stmts []ast.Statement, subgraphID string) {
 	for _, stmt := range stmts {
+		if c.err == nil {
+			c.err = c.ctx.Err()
+		}
+		if c.err != nil {
+			return
+		}
 		c.processStatement(stmt, subgraphID)
 	}
 }
@@ -73,7 +443,11 @@ func (c *Converter) processStatement(stmt ast.Statement, subgraphID string) {
 	case *ast.AttrStmt:
 		c.processAttrStmt(s)
 	case *ast.AttrAssign:
-		// Graph-level attributes, ignore for now
+		// Only top-level assignments are graph attributes; subgraph
+		// assignments are read in processSubgraph.
+		if c.subgraphDepth == 0 {
+			c.graphAttrs[s.Key.Name] = s.Value.Name
+		}
 	case *ast.Subgraph:
 		c.processSubgraph(s)
 	}
@@ -83,11 +457,6 @@ func (c *Converter) processNodeStmt(stmt *ast.NodeStmt, subgraphID string) {
 	id := stmt.NodeID.ID.Name
 	node := c.getOrCreateNode(id)
 
-	// Apply default attributes
-	for k, v := range c.nodeDefaults {
-		c.applyNodeAttr(node, k, v)
-	}
-
 	// Apply statement attributes
 	if stmt.Attrs != nil {
 		for _, attr := range stmt.Attrs.Attrs {
@@ -96,17 +465,20 @@ func (c *Converter) processNodeStmt(stmt *ast.NodeStmt, subgraphID string) {
 	}
 
 	// Set subgraph membership
-	if subgraphID != "" {
+	if subgraphID != "" && !c.explicitGroups[id] {
 		node.Group = subgraphID
+		node.groupFromSubgraph = true
 	}
 }
 
 func (c *Converter) processEdgeStmt(stmt *ast.EdgeStmt, subgraphID string) {
 	// Collect all endpoints
 	endpoints := c.collectEndpoints(stmt.Left, subgraphID)
+	leftPort := endpointPort(stmt.Left)
 
 	for _, right := range stmt.Rights {
 		rightEndpoints := c.collectEndpoints(right.Endpoint, subgraphID)
+		rightPort := endpointPort(right.Endpoint)
 
 		// Create edges from all left endpoints to all right endpoints
 		for _, leftID := range endpoints {
@@ -115,12 +487,26 @@ func (c *Converter) processEdgeStmt(stmt *ast.EdgeStmt, subgraphID string) {
 					Source: leftID,
 					Target: rightID,
 				}
+				if right.Directed != c.directed {
+					// -> in a graph or -- in a digraph: keep the edge's own
+					// direction
+					link.Operator = edgeOperator(right.Directed)
+				}
 
 				// Apply default edge attributes
 				for k, v := range c.edgeDefaults {
 					c.applyLinkAttr(&link, k, v)
 				}
 
+				// A:port overrides a default tailport or headport, but not
+				// one in the edge's own attributes
+				if leftPort != "" {
+					link.SourcePort, link.SourceCompass = splitPort(leftPort)
+				}
+				if rightPort != "" {
+					link.TargetPort, link.TargetCompass = splitPort(rightPort)
+				}
+
 				// Apply statement attributes
 				if stmt.Attrs != nil {
 					for _, attr := range stmt.Attrs.Attrs {
@@ -133,13 +519,69 @@ func (c *Converter) processEdgeStmt(stmt *ast.EdgeStmt, subgraphID string) {
 					continue
 				}
 
+				if len(c.links) >= c.limits.MaxEdges {
+					c.err = fmt.Errorf("%w: more than %d edges", ErrLimitExceeded, c.limits.MaxEdges)
+					return
+				}
+				// Group-to-group edges can multiply into many links
+				// from one statement
+				if len(c.links)%1024 == 0 && c.ctx.Err() != nil {
+					if c.err == nil {
+						c.err = c.ctx.Err()
+					}
+					return
+				}
+				link.Order = c.outEdges[link.Source]
+				c.outEdges[link.Source]++
 				c.links = append(c.links, link)
 			}
 		}
 
 		// The right endpoints become the left endpoints for the next edge
 		endpoints = rightEndpoints
+		leftPort = rightPort
+	}
+}
+
+// edgeOperator returns the DOT edge operator for a directed or undirected
+// edge.
+func edgeOperator(directed bool) string {
+	if directed {
+		return "->"
+	}
+	return "--"
+}
+
+// compassPoints are the Graphviz compass point names.
+var compassPoints = map[string]bool{
+	"n": true, "ne": true, "e": true, "se": true, "s": true,
+	"sw": true, "w": true, "nw": true, "c": true, "_": true,
+}
+
+// endpointPort returns the "port[:compass]" of a node endpoint, or "".
+func endpointPort(ep ast.EdgeEndpoint) string {
+	n, ok := ep.(*ast.NodeID)
+	if !ok || n.Port == nil {
+		return ""
+	}
+	port := ""
+	if n.Port.ID != nil {
+		port = n.Port.ID.Name
+	}
+	if n.Port.Compass != nil {
+		port += ":" + n.Port.Compass.Name
+	}
+	return port
+}
+
+// splitPort splits a port value such as "p1:n" into its port name and
+// compass point. A lone compass point name, as in A:s, is a compass point.
+func splitPort(value string) (port, compass string) {
+	port, compass, found := strings.Cut(value, ":")
+	if !found && compassPoints[port] {
+		return "", port
 	}
+	return port, compass
 }
 
 func (c *Converter) collectEndpoints(ep ast.EdgeEndpoint, subgraphID string) []string {
@@ -169,6 +611,9 @@ func (c *Converter) collectEndpoints(ep ast.EdgeEndpoint, subgraphID string) []s
 }
 
 func (c *Converter) processSubgraphNodes(sg *ast.Subgraph, subgraphID string) []string {
+	c.enterScope()
+	defer c.leaveScope()
+
 	var nodeIDs []string
 
 	for _, stmt := range sg.Statements {
@@ -181,12 +626,12 @@ func (c *Converter) processSubgraphNodes(sg *ast.Subgraph, subgraphID string) []
 			// Process edges within subgraph
 			c.processEdgeStmt(s, subgraphID)
 			// Collect node IDs from edge endpoints
-			ids := c.collectEndpoints(s.Left, subgraphID)
-			nodeIDs = append(nodeIDs, ids...)
+			nodeIDs = append(nodeIDs, collectPathEndpoints(s.Left)...)
 			for _, r := range s.Rights {
-				ids = c.collectEndpoints(r.Endpoint, subgraphID)
-				nodeIDs = append(nodeIDs, ids...)
+				nodeIDs = append(nodeIDs, collectPathEndpoints(r.Endpoint)...)
 			}
+		case *ast.AttrStmt:
+			c.processAttrStmt(s)
 		case *ast.Subgraph:
 			ids := c.processSubgraphNodes(s, subgraphID)
 			nodeIDs = append(nodeIDs, ids...)
@@ -211,11 +656,18 @@ func (c *Converter) processAttrStmt(stmt *ast.AttrStmt) {
 			c.edgeDefaults[attr.Key.Name] = attr.Value.Name
 		}
 	case ast.GraphAttr:
-		// Graph attributes, ignore for now
+		if c.subgraphDepth == 0 {
+			for _, attr := range stmt.Attrs.Attrs {
+				c.graphAttrs[attr.Key.Name] = attr.Value.Name
+			}
+		}
 	}
 }
 
 func (c *Converter) processSubgraph(sg *ast.Subgraph) {
+	c.enterScope()
+	defer c.leaveScope()
+
 	sgID := ""
 	if sg.ID != nil {
 		sgID = sg.ID.Name
@@ -229,11 +681,11 @@ func (c *Converter) processSubgraph(sg *ast.Subgraph) {
 		case *ast.NodeStmt:
 			nodeIDs = append(nodeIDs, s.NodeID.ID.Name)
 		case *ast.EdgeStmt:
-			ids := c.collectEndpoints(s.Left, sgID)
-			nodeIDs = append(nodeIDs, ids...)
+			// processStatement already created the endpoints; collect their
+			// IDs without re-processing nested subgraph edges.
+			nodeIDs = append(nodeIDs, collectPathEndpoints(s.Left)...)
 			for _, r := range s.Rights {
-				ids = c.collectEndpoints(r.Endpoint, sgID)
-				nodeIDs = append(nodeIDs, ids...)
+				nodeIDs = append(nodeIDs, collectPathEndpoints(r.Endpoint)...)
 			}
 		}
 	}
@@ -265,86 +717,326 @@ func (c *Converter) getOrCreateNode(id string) *Node {
 		return n
 	}
 	n := &Node{
-		ID:    id,
-		Label: id, // Default label is the ID
+		ID:          id,
+		Label:       id, // Default label is the ID
+		labelFromID: true,
+	}
+	// Like Graphviz, defaults apply to nodes created after they are set:
+	// a node mentioned before a node [...] statement keeps its attributes
+	for k, v := range c.nodeDefaults {
+		c.applyNodeAttr(n, k, v)
+	}
+	if len(c.nodes) >= c.limits.MaxNodes {
+		// Hand back the node so callers can finish the statement, but
+		// don't keep it
+		if c.err == nil {
+			c.err = fmt.Errorf("%w: more than %d nodes", ErrLimitExceeded, c.limits.MaxNodes)
+		}
+		return n
 	}
 	c.nodes[id] = n
+	c.nodeOrder = append(c.nodeOrder, id)
 	return n
 }
 
 func (c *Converter) ensureNode(id string, subgraphID string) {
 	node := c.getOrCreateNode(id)
 
-	// Apply default attributes to newly created nodes
-	for k, v := range c.nodeDefaults {
-		// Only apply if the attribute isn't already set
-		switch k {
-		case "label":
-			if node.Label == id { // Still has default label
-				c.applyNodeAttr(node, k, v)
-			}
-		case "color":
-			if node.Color == "" {
-				c.applyNodeAttr(node, k, v)
-			}
-		case "fillcolor":
-			if node.FillColor == "" {
-				c.applyNodeAttr(node, k, v)
-			}
-		case "shape":
-			if node.Shape == "" {
-				c.applyNodeAttr(node, k, v)
-			}
-		case "style":
-			if node.Style == "" {
-				c.applyNodeAttr(node, k, v)
-			}
-		default:
-			if node.Attributes == nil || node.Attributes[k] == "" {
-				c.applyNodeAttr(node, k, v)
-			}
-		}
-	}
-
 	if subgraphID != "" && node.Group == "" {
 		node.Group = subgraphID
+		node.groupFromSubgraph = true
 	}
 }
 
 func (c *Converter) applyNodeAttr(node *Node, key, value string) {
 	switch key {
 	case "label":
-		node.Label = value
+		node.Label = c.expandLabel(value, `\N`, node.ID)
+		node.labelFromID = false
 	case "color":
 		node.Color = value // Border/stroke color
 	case "fillcolor":
-		node.FillColor = value // Fill color
+		// A color list ("yellow:orange") fills with a gradient; FillColor
+		// keeps its first color
+		stops := colorStops(value)
+		node.FillColor = value
+		node.FillStops = nil
+		if len(stops) > 1 {
+			node.FillColor = stops[0]
+			node.FillStops = stops
+		}
 	case "shape":
-		node.Shape = value
+		// Decorated shapes (Mdiamond, Msquare, Mcircle) draw as their base
+		// shape plus corner marks
+		if base, ok := decoratedShapes[value]; ok {
+			node.Shape = base
+			node.Decorated = true
+		} else {
+			node.Shape = value
+			node.Decorated = false
+		}
+		// Points are small filled dots without a label
+		node.NoLabel = value == "point"
 	case "style":
 		node.Style = value
+		node.Rounded = hasStyle(value, "rounded")
+	case "class":
+		node.Class = value
+	case "group":
+		// Nodes sharing a group are pulled together like a cluster's
+		node.Group = value
+		node.groupFromSubgraph = false
+		c.explicitGroups[node.ID] = value != ""
+	case "image":
+		node.Image = value
+	case "fontcolor":
+		node.FontColor = value
+	case "fontsize":
+		if size, ok := parsePositive(value, 1); ok {
+			node.FontSize = size
+			return
+		}
+		keepAttr(&node.Attributes, key, value)
+	case "peripheries":
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			node.Peripheries = n
+			node.NoOutline = n == 0
+			if n <= maxPeripheries {
+				return
+			}
+			// Each periphery is drawn, so draw at most maxPeripheries and
+			// show the requested count in the tooltip
+			node.Peripheries = maxPeripheries
+		}
+		keepAttr(&node.Attributes, key, value)
+	case "penwidth":
+		if width, ok := parsePositive(value, 1); ok {
+			node.PenWidth = width
+			return
+		}
+		keepAttr(&node.Attributes, key, value)
+	case "width", "height":
+		if size, ok := parsePositive(value, pointsPerInch); ok {
+			if key == "width" {
+				node.Width = size
+			} else {
+				node.Height = size
+			}
+			return
+		}
+		keepAttr(&node.Attributes, key, value)
+	case "fixedsize":
+		if strings.EqualFold(value, "shape") {
+			// fixedsize=shape isn't supported
+			keepAttr(&node.Attributes, key, value)
+			return
+		}
+		node.FixedSize = isTrue(value)
+	case "sides", "orientation", "skew":
+		if applyPolygonAttr(node, key, value) {
+			return
+		}
+		keepAttr(&node.Attributes, key, value)
+	case "pos":
+		if pos, ok := parsePos(value); ok {
+			node.Pos = pos
+			return
+		}
+		keepAttr(&node.Attributes, key, value)
 	default:
-		if node.Attributes == nil {
-			node.Attributes = make(map[string]string)
+		keepAttr(&node.Attributes, key, value)
+	}
+}
+
+// parsePos parses a node's pos attribute, "x,y" in points with y up and an
+// optional "!" to pin the node there, into page coordinates.
+func parsePos(value string) (*Pos, bool) {
+	value, pinned := strings.CutSuffix(strings.TrimSpace(value), "!")
+	xs, ys, ok := strings.Cut(value, ",")
+	if !ok {
+		return nil, false
+	}
+	x, errX := strconv.ParseFloat(strings.TrimSpace(xs), 64)
+	y, errY := strconv.ParseFloat(strings.TrimSpace(ys), 64)
+	if errX != nil || errY != nil || math.IsNaN(x) || math.IsInf(x, 0) || math.IsNaN(y) || math.IsInf(y, 0) {
+		return nil, false
+	}
+	return &Pos{X: x, Y: -y, Pinned: pinned}, true
+}
+
+// expandLabel replaces Graphviz escapes in a label: \G with the graph name,
+// plus the object escapes given as old, new pairs (\N for nodes; \T and \H
+// for an edge's tail and head).
+func (c *Converter) expandLabel(label string, oldnew ...string) string {
+	if !strings.Contains(label, `\`) {
+		return label
+	}
+	return strings.NewReplacer(append(oldnew, `\G`, c.graphID)...).Replace(label)
+}
+
+// isTrue reports whether a Graphviz boolean attribute value is true: "true",
+// "yes", or a nonzero integer, in any case.
+func isTrue(value string) bool {
+	switch strings.ToLower(value) {
+	case "true", "yes":
+		return true
+	}
+	n, err := strconv.Atoi(value)
+	return err == nil && n != 0
+}
+
+// unitPoints is the size in points of each unit a numeric attribute value
+// may end with, as in "1.5pt" or "2in". The canvas draws a point as a
+// pixel.
+var unitPoints = map[string]float64{
+	"pt": 1,
+	"px": 1,
+	"in": pointsPerInch,
+}
+
+// parsePositive parses a positive size: a fontsize, penwidth, width or
+// height, whose own unit is unit points (1 for sizes in points,
+// pointsPerInch for sizes in inches). A bare number is in the attribute's
+// unit; a number with a unit from unitPoints is converted to it.
+func parsePositive(value string, unit float64) (float64, bool) {
+	value = strings.TrimSpace(value)
+	scale := 1.0
+	for suffix, points := range unitPoints {
+		if num, ok := strings.CutSuffix(value, suffix); ok {
+			value, scale = strings.TrimSpace(num), points/unit
+			break
 		}
-		node.Attributes[key] = value
 	}
+	size, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(size) || size <= 0 {
+		return 0, false
+	}
+	// A huge size in a large unit, such as "1e308in", overflows
+	if size *= scale; math.IsInf(size, 0) {
+		return 0, false
+	}
+	return size, true
+}
+
+// colorStops splits a Graphviz color list ("yellow:orange", optionally with
+// ";fraction" weights) into its colors.
+func colorStops(value string) []string {
+	var stops []string
+	for _, c := range strings.Split(value, ":") {
+		c, _, _ = strings.Cut(c, ";")
+		if c != "" {
+			stops = append(stops, c)
+		}
+	}
+	return stops
+}
+
+// applyPolygonAttr sets one of the shape=polygon parameters from its
+// attribute value, reporting whether the value was valid. Graphviz allows
+// 3 to 120 sides.
+func applyPolygonAttr(node *Node, key, value string) bool {
+	if key == "sides" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 3 || n > 120 {
+			return false
+		}
+		node.Sides = n
+		return true
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+	if key == "orientation" {
+		node.Orientation = f
+	} else {
+		node.Skew = f
+	}
+	return true
+}
+
+// maxPeripheries is the most outlines drawn around a node.
+const maxPeripheries = 10
+
+// decoratedShapes maps Graphviz decorated shapes to the base shape drawn
+// underneath their corner marks.
+var decoratedShapes = map[string]string{
+	"Mdiamond": "diamond",
+	"Msquare":  "box",
+	"Mcircle":  "circle",
 }
 
 func (c *Converter) applyLinkAttr(link *Link, key, value string) {
 	switch key {
 	case "label":
-		link.Label = value
+		link.Label = c.expandLabel(value, `\T`, link.Source, `\H`, link.Target)
 	case "color":
 		link.Color = value
 	case "style":
 		link.Style = value
+		link.Tapered = hasStyle(value, "tapered")
+	case "class":
+		link.Class = value
+	case "fontcolor":
+		link.FontColor = value
+	case "labelfontcolor":
+		// Graphviz uses it for head and tail labels, which aren't drawn;
+		// here it colors the edge label unless fontcolor is set
+		link.LabelFontColor = value
+	case "fontsize":
+		if size, ok := parsePositive(value, 1); ok {
+			link.FontSize = size
+			return
+		}
+		keepAttr(&link.Attributes, key, value)
+	case "penwidth":
+		if width, ok := parsePositive(value, 1); ok {
+			link.PenWidth = width
+			return
+		}
+		keepAttr(&link.Attributes, key, value)
+	case "lhead":
+		link.LHead = value
+	case "ltail":
+		link.LTail = value
+	case "tailport":
+		link.SourcePort, link.SourceCompass = splitPort(value)
+	case "headport":
+		link.TargetPort, link.TargetCompass = splitPort(value)
+	case "minlen":
+		if n, err := strconv.Atoi(value); err == nil && n >= 0 {
+			link.MinLen = n
+			link.Flat = n == 0
+			return
+		}
+		keepAttr(&link.Attributes, key, value)
 	default:
-		if link.Attributes == nil {
-			link.Attributes = make(map[string]string)
+		keepAttr(&link.Attributes, key, value)
+	}
+}
+
+// keepAttr stores an attribute that has no typed field, or whose value
+// couldn't be parsed, in attrs, so it stays visible in the tooltip.
+func keepAttr(attrs *map[string]string, key, value string) {
+	if *attrs == nil {
+		*attrs = make(map[string]string)
+	}
+	(*attrs)[key] = value
+}
+
+// hasStyle reports whether a comma-separated Graphviz style list, such as
+// "tapered,setlinewidth(2)", includes name.
+func hasStyle(style, name string) bool {
+	for _, s := range strings.Split(style, ",") {
+		s = strings.TrimSpace(s)
+		if i := strings.IndexByte(s, '('); i >= 0 {
+			s = s[:i]
+		}
+		if s == name {
+			return true
 		}
-		link.Attributes[key] = value
 	}
+	return false
 }
 
 func (c *Converter) linkExists(source, target string) bool {
@@ -384,6 +1076,9 @@ func ApplyPathHighlighting(g *Graph, pathGraph *ast.Graph) *PathValidationResult
 		return nil
 	}
 
+	// Path edges with both nodes in the graph but no edge between them
+	var missing []string
+
 	// Extract edges from path graph and validate each one
 	for _, stmt := range pathGraph.Statements {
 		edgeStmt, ok := stmt.(*ast.EdgeStmt)
@@ -454,9 +1149,11 @@ func ApplyPathHighlighting(g *Graph, pathGraph *ast.Graph) *PathValidationResult
 					link := findLink(leftID, rightID)
 					if link != nil {
 						link.OnPath = true
+					} else {
+						// Note: We don't error if the edge doesn't exist in the graph,
+						// we just don't highlight it. The nodes are still valid.
+						missing = append(missing, leftID+" -> "+rightID)
 					}
-					// Note: We don't error if the edge doesn't exist in the graph,
-					// we just don't highlight it. The nodes are still valid.
 				}
 			}
 
@@ -465,7 +1162,7 @@ func ApplyPathHighlighting(g *Graph, pathGraph *ast.Graph) *PathValidationResult
 		}
 	}
 
-	return &PathValidationResult{Valid: true}
+	return &PathValidationResult{Valid: true, MissingEdges: missing}
 }
 
 // collectPathEndpoints extracts node IDs from an edge endpoint for path validation.
@@ -482,14 +1179,16 @@ func collectPathEndpoints(ep ast.EdgeEndpoint) []string {
 	case *ast.Subgraph:
 		// Recursively collect from subgraph statements
 		for _, stmt := range e.Statements {
-			if nodeStmt, ok := stmt.(*ast.NodeStmt); ok {
-				ids = append(ids, nodeStmt.NodeID.ID.Name)
-			}
-			if edgeStmt, ok := stmt.(*ast.EdgeStmt); ok {
-				ids = append(ids, collectPathEndpoints(edgeStmt.Left)...)
-				for _, r := range edgeStmt.Rights {
+			switch s := stmt.(type) {
+			case *ast.NodeStmt:
+				ids = append(ids, s.NodeID.ID.Name)
+			case *ast.EdgeStmt:
+				ids = append(ids, collectPathEndpoints(s.Left)...)
+				for _, r := range s.Rights {
 					ids = append(ids, collectPathEndpoints(r.Endpoint)...)
 				}
+			case *ast.Subgraph:
+				ids = append(ids, collectPathEndpoints(s)...)
 			}
 		}
 	}
@@ -503,6 +1202,246 @@ type RenderOptions struct {
 	Width   int
 	Height  int
 	PathAST *ast.Graph // Optional path graph to highlight
+
+	// PathFrom and PathTo, when both set, highlight every simple path
+	// between the two nodes (see Graph.AllPaths), each in its own color,
+	// of at most MaxPathLength edges (zero for any length). At most
+	// MaxPaths paths are highlighted, or DefaultMaxPaths when it is zero.
+	// It is an error for either node not to be in the graph.
+	PathFrom      string
+	PathTo        string
+	MaxPathLength int
+	MaxPaths      int
+
+	// EdgeStyle selects edge routing: "straight", "curved", or "ortho".
+	// When empty, the graph's splines attribute is used.
+	EdgeStyle string
+
+	// TitleFromLabel uses the graph's label attribute as the page title
+	// when Title is empty.
+	TitleFromLabel bool
+
+	// InlineImagesOnly drops node images that are not data: URIs so the
+	// output never references external or local files.
+	InlineImagesOnly bool
+
+	// Expandable makes double-clicking a node emit a "nodeExpand" event and
+	// exposes window.dot2d3.addNodes/addLinks for merging in more graph.
+	Expandable bool
+
+	// FastEdges draws every edge as a straight segment of a single SVG path
+	// to keep the DOM small for very large graphs. Edge labels, arrowheads,
+	// multi-edge curves, and edge click events are not available.
+	FastEdges bool
+
+	// ColorByAttribute fills nodes from a color scale over the numeric
+	// range of this node attribute (e.g. "weight") and adds a legend.
+	// Nodes without a numeric value are drawn gray.
+	ColorByAttribute string
+
+	// Static settles the layout synchronously on load and draws it once:
+	// no animation, no dragging, and no position lock control.
+	Static bool
+
+	// Layout picks how nodes are placed: "force" (or empty) simulates
+	// them; "circular" places them evenly around a circle, each cluster on
+	// its own arc, with edges drawn as chords. Circular layouts are
+	// deterministic and not simulated, whatever SimulationNodeLimit says.
+	Layout string
+
+	// ShowInspector adds a panel that lists every attribute of the last
+	// clicked node or edge and stays open until something else is clicked.
+	ShowInspector bool
+
+	// ShowExport adds a button that downloads the nodes and edges left
+	// visible by the degree filter as DOT, and copies it to the clipboard,
+	// a second that does the same with each node's current position as a
+	// pinned pos="x,y!" attribute in points with y up, as Graphviz expects
+	// (and as the converter reads it back into Node.Pos), and a button that copies the drawing as a PNG image, downloading it
+	// instead where the clipboard can't take images.
+	ShowExport bool
+
+	// ImageFormat is the format of the image exported by ShowExport:
+	// "png" (the default), "jpeg" or "webp". Browsers only put PNGs on the
+	// clipboard, so the other formats are always downloaded.
+	ImageFormat string
+
+	// ImageQuality is the encoding quality of a jpeg or webp export, from
+	// 0 to 1. Zero leaves it to the browser.
+	ImageQuality float64
+
+	// EdgeFilter adds a checkbox for each distinct value of each edge
+	// attribute (style, color, ...); unchecking one filters out the edges
+	// with that value. Attributes with too many distinct values to be
+	// useful as toggles, such as labels, are left out.
+	EdgeFilter bool
+
+	// EmbedJSON adds the graph as indented JSON in a
+	// <script type="application/json" id="graph-data"> block, so archived
+	// pages can be read back by tools without re-parsing the DOT source.
+	EmbedJSON bool
+
+	// Gravity, when positive, adds x/y forces of this strength pulling
+	// nodes toward the center (typical values 0.01-0.2).
+	Gravity float64
+
+	// DisableCenter removes the centering force so sparse graphs can
+	// spread out.
+	DisableCenter bool
+
+	// CollapseParallel merges parallel edges into one link labeled with
+	// the edge count and drawn thicker. See Graph.CollapseParallel.
+	CollapseParallel bool
+
+	// SizeByCentrality scales nodes by betweenness centrality, up to
+	// twice the normal size for the most central node.
+	SizeByCentrality bool
+
+	// WidthByWeight draws each edge with a stroke width scaled linearly
+	// from its weight attribute, from thin for the lightest edge in the
+	// graph to thick for the heaviest. Edges without a numeric weight keep
+	// the default width.
+	WidthByWeight bool
+
+	// DirectionalFilter adds In/Out/Both choices to the degree filter, so
+	// it can follow edges only downstream (Out), only upstream (In), or
+	// either way (Both, the default). Undirected edges are followed
+	// either way in every mode.
+	DirectionalFilter bool
+
+	// ShowTopNodes, when positive, adds a list of that many nodes with the
+	// most edges; clicking one selects it and applies the degree filter.
+	ShowTopNodes int
+
+	// ShowDegreeBadges draws each node's in-degree and out-degree as small
+	// numbered circles at its top corners, counting distinct neighbors.
+	// Undirected graphs get a single degree badge.
+	ShowDegreeBadges bool
+
+	// HighlightSCCs colors each strongly connected component that contains
+	// a cycle distinctly and draws every other node gray, to pick out
+	// cyclic dependencies. ColorByAttribute takes precedence.
+	HighlightSCCs bool
+
+	// SimulationNodeLimit is the node count above which the force
+	// simulation is skipped: nodes are placed on a grid instead and a
+	// notice is shown. Zero means DefaultSimulationNodeLimit.
+	SimulationNodeLimit int
+
+	// ScaleArrows resizes arrowheads inversely with the zoom level so they
+	// keep the same on-screen size when zooming in or out.
+	ScaleArrows bool
+
+	// RemoveOverlap runs an overlap-removal pass once the layout settles,
+	// pushing apart nodes whose bounding boxes overlap (by at least the
+	// graph's esep attribute, 4px by default) that the collision force
+	// left touching.
+	RemoveOverlap bool
+
+	// Transparent draws the page and canvas without a background,
+	// overriding the default white and the graph's bgcolor, for overlaying
+	// the drawing on other content. Copied images are transparent too.
+	Transparent bool
+
+	// StableColors picks each uncolored node's automatic color from a hash
+	// of its group or ID instead of in order of appearance, so a node keeps
+	// its color when the graph's node order changes.
+	StableColors bool
+
+	// LabelWrap wraps node labels at word boundaries to at most this many
+	// characters per line, growing the node to fit. Zero disables wrapping;
+	// labels with explicit newlines are always drawn on several lines.
+	LabelWrap int
+
+	// MaxLabelLength truncates the node labels drawn on the canvas to at
+	// most this many characters, ending in an ellipsis; each line of a
+	// multiline label is truncated separately. Tooltips keep the full
+	// label. Zero disables truncation.
+	MaxLabelLength int
+
+	// MainComponentOnly renders only the graph's largest connected
+	// component, dropping smaller components such as isolated nodes.
+	MainComponentOnly bool
+
+	// AnimateEntry fades and scales the nodes in from their centers when
+	// the page loads, followed by the edges, instead of drawing everything
+	// at once.
+	AnimateEntry bool
+
+	// Template replaces the built-in HTML page. It is parsed as an
+	// html/template and executed with the same data as the default page:
+	// .Title, .GraphJSON (the graph as a JS value), .EdgeStyle, .Width and
+	// .Height, among others. The json function renders any value, e.g.
+	// {{json .Title}}, as a JS literal. Empty uses the default page.
+	Template string
+
+	// ExtraCSS is added at the end of the default page's <style> block,
+	// after the built-in rules, so it can override fonts and colors
+	// without replacing the whole Template. It is not escaped and must
+	// come from a trusted source.
+	ExtraCSS string
+
+	// ExtraJS runs at the end of the default page's script, once the graph
+	// is drawn and the simulation started. It can use graphData (the
+	// nodes and links, with positions), simulation, svg, zoom, node and
+	// link (the D3 selections), and listen for the page's nodeClick,
+	// edgeClick and filterChange events. It runs in its own block, so its
+	// declarations don't clash with the page's. It is not escaped and must
+	// come from a trusted source.
+	ExtraJS string
+
+	// Cluster force tuning; zero values use the defaults below.
+	// ClusterAttraction pulls nodes toward their cluster's center,
+	// ClusterRepulsion pushes clusters apart, and ClusterSeparation is the
+	// distance between cluster centers under which repulsion applies.
+	ClusterAttraction float64
+	ClusterRepulsion  float64
+	ClusterSeparation float64
+}
+
+// DefaultSimulationNodeLimit is the node count above which graphs are laid
+// out on a grid rather than simulated.
+const DefaultSimulationNodeLimit = 5000
+
+// DefaultMaxPaths is the number of paths between RenderOptions.PathFrom and
+// PathTo highlighted when RenderOptions.MaxPaths is zero.
+const DefaultMaxPaths = 100
+
+// Default cluster force parameters.
+const (
+	DefaultClusterAttraction = 0.15
+	DefaultClusterRepulsion  = 0.8
+	DefaultClusterSeparation = 200
+)
+
+// imageFormats maps each RenderOptions.ImageFormat to the MIME type and
+// file extension of the exported image.
+var imageFormats = map[string]struct{ mimeType, ext string }{
+	"":     {"image/png", "png"},
+	"png":  {"image/png", "png"},
+	"jpeg": {"image/jpeg", "jpg"},
+	"webp": {"image/webp", "webp"},
+}
+
+// ValidateImageOptions reports whether opts.ImageFormat and
+// opts.ImageQuality are usable, for callers that want to reject them
+// before converting the graph.
+func ValidateImageOptions(opts RenderOptions) error {
+	if _, ok := imageFormats[opts.ImageFormat]; !ok {
+		return fmt.Errorf("unknown image format %q", opts.ImageFormat)
+	}
+	if math.IsNaN(opts.ImageQuality) || opts.ImageQuality < 0 || opts.ImageQuality > 1 {
+		return fmt.Errorf("image quality %v is not between 0 and 1", opts.ImageQuality)
+	}
+	return nil
+}
+
+// orDefault returns v, or def when v is zero.
+func orDefault(v, def float64) float64 {
+	if v == 0 {
+		return def
+	}
+	return v
 }
 
 // RenderHTML generates a self-contained HTML file with the D3 visualization.
@@ -514,64 +1453,260 @@ func RenderHTML(g *Graph, opts RenderOptions) ([]byte, error) {
 
 // RenderHTMLWithValidation generates HTML and returns path validation result.
 // If path validation fails, HTML is still generated with the error node highlighted red.
+// g itself is left unchanged, so it can be rendered again with other options.
 func RenderHTMLWithValidation(g *Graph, opts RenderOptions) ([]byte, *PathValidationResult, error) {
+	switch opts.Layout {
+	case "", "force", "circular":
+	default:
+		return nil, nil, fmt.Errorf("unknown layout %q", opts.Layout)
+	}
+	if err := ValidateImageOptions(opts); err != nil {
+		return nil, nil, err
+	}
+	image := imageFormats[opts.ImageFormat]
+
+	// Label lines, path highlights and the like are set on a copy
+	g = g.clone()
+
 	if opts.Title == "" {
 		opts.Title = "Graph Visualization"
-		if g.GraphID != "" {
+		if opts.TitleFromLabel && g.Attributes["label"] != "" {
+			opts.Title = g.Attributes["label"]
+		} else if g.GraphID != "" {
 			opts.Title = g.GraphID
 		}
 	}
 
-	// Apply path highlighting if provided
-	var pathResult *PathValidationResult
-	if opts.PathAST != nil {
-		pathResult = ApplyPathHighlighting(g, opts.PathAST)
+	if opts.MainComponentOnly {
+		g = g.MainComponent()
 	}
 
-	graphJSON, err := json.Marshal(g)
-	if err != nil {
-		return nil, nil, err
+	if opts.InlineImagesOnly {
+		for i := range g.Nodes {
+			if !strings.HasPrefix(g.Nodes[i].Image, "data:") {
+				g.Nodes[i].Image = ""
+			}
+		}
 	}
 
-	data := struct {
-		Title     string
-		GraphJSON template.JS
-	}{
-		Title:     opts.Title,
-		GraphJSON: template.JS(graphJSON),
+	// Graphviz color names and schemes aren't all valid CSS
+	normalizeColors(g)
+
+	if opts.CollapseParallel {
+		g.CollapseParallel()
 	}
 
-	tmpl, err := template.New("graph").Parse(htmlTemplate)
-	if err != nil {
-		return nil, nil, err
+	for i := range g.Nodes {
+		label := g.Nodes[i].Label
+		if label == "" {
+			label = g.Nodes[i].ID
+		}
+		if lines := wrapLabel(label, opts.LabelWrap); len(lines) > 1 {
+			g.Nodes[i].LabelLines = lines
+		}
 	}
 
-	var buf bytes.Buffer
-	if err := tmpl.Execute(&buf, data); err != nil {
-		return nil, nil, err
+	if opts.SizeByCentrality {
+		betweenness := g.BetweennessCentrality()
+		for i := range g.Nodes {
+			g.Nodes[i].Betweenness = betweenness[g.Nodes[i].ID]
+		}
 	}
 
-	return buf.Bytes(), pathResult, nil
-}
+	if opts.WidthByWeight {
+		applyWeightWidths(g)
+	}
 
-const htmlTemplate = `<!DOCTYPE html>
-<html lang="en">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>{{.Title}}</title>
-    <script src="https://d3js.org/d3.v7.min.js"></script>
+	if opts.HighlightSCCs {
+		scc := make(map[string]int)
+		for i, c := range g.cyclicComponents() {
+			for _, id := range c {
+				scc[id] = i + 1
+			}
+		}
+		for i := range g.Nodes {
+			g.Nodes[i].SCC = scc[g.Nodes[i].ID]
+		}
+	}
+
+	// Apply path highlighting if provided
+	var pathResult *PathValidationResult
+	if opts.PathAST != nil {
+		pathResult = ApplyPathHighlighting(g, opts.PathAST)
+	}
+	if opts.PathFrom != "" && opts.PathTo != "" {
+		for _, id := range []string{opts.PathFrom, opts.PathTo} {
+			if !g.hasNode(id) {
+				return nil, nil, fmt.Errorf("path endpoint %q is not in the graph", id)
+			}
+		}
+		maxPaths := opts.MaxPaths
+		if maxPaths <= 0 {
+			maxPaths = DefaultMaxPaths
+		}
+		g.highlightPaths(g.AllPaths(opts.PathFrom, opts.PathTo, opts.MaxPathLength, maxPaths))
+	}
+
+	edgeStyle := opts.EdgeStyle
+	if edgeStyle == "" {
+		edgeStyle = g.EdgeStyle
+	}
+	if edgeStyle == "" {
+		edgeStyle = "straight"
+	}
+
+	canvasWidth, canvasHeight := canvasSize(g, opts)
+
+	simulationLimit := opts.SimulationNodeLimit
+	if simulationLimit <= 0 {
+		simulationLimit = DefaultSimulationNodeLimit
+	}
+
+	var colorBy *colorRange
+	if opts.ColorByAttribute != "" {
+		colorBy = attributeRange(g, opts.ColorByAttribute)
+	}
+
+	var filters []edgeFilter
+	if opts.EdgeFilter {
+		filters = edgeFilters(g)
+	}
+
+	graphJSON, err := json.Marshal(g)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var embeddedJSON []byte
+	if opts.EmbedJSON {
+		embeddedJSON, err = json.MarshalIndent(g, "", "  ")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data := struct {
+		Title             string
+		GraphJSON         template.JS
+		EmbeddedJSON      template.JS
+		ExtraCSS          template.CSS
+		ExtraJS           template.JS
+		EdgeStyle         string
+		Expandable        bool
+		FastEdges         bool
+		ColorBy           *colorRange
+		Static            bool
+		Inspector         bool
+		Export            bool
+		ImageType         string
+		ImageExt          string
+		ImageQuality      float64
+		EdgeFilters       []edgeFilter
+		Layers            []string
+		ScaleArrows       bool
+		StableColors      bool
+		Transparent       bool
+		RemoveOverlap     bool
+		HighlightSCCs     bool
+		TopNodes          int
+		DegreeBadges      bool
+		DirectionalFilter bool
+		MaxLabelLength    int
+		AnimateEntry      bool
+		GridLayout        bool
+		CircularLayout    bool
+		NodeCount         int
+		Gravity           float64
+		DisableCenter     bool
+		ClusterAttraction float64
+		ClusterRepulsion  float64
+		ClusterSeparation float64
+		Width             int
+		Height            int
+	}{
+		Title:             opts.Title,
+		GraphJSON:         template.JS(graphJSON),
+		EmbeddedJSON:      template.JS(embeddedJSON),
+		ExtraCSS:          template.CSS(opts.ExtraCSS),
+		ExtraJS:           template.JS(opts.ExtraJS),
+		EdgeStyle:         edgeStyle,
+		Expandable:        opts.Expandable,
+		FastEdges:         opts.FastEdges,
+		ColorBy:           colorBy,
+		Static:            opts.Static,
+		Inspector:         opts.ShowInspector,
+		Export:            opts.ShowExport,
+		ImageType:         image.mimeType,
+		ImageExt:          image.ext,
+		ImageQuality:      opts.ImageQuality,
+		EdgeFilters:       filters,
+		Layers:            g.Layers,
+		ScaleArrows:       opts.ScaleArrows,
+		StableColors:      opts.StableColors,
+		Transparent:       opts.Transparent,
+		RemoveOverlap:     opts.RemoveOverlap,
+		HighlightSCCs:     opts.HighlightSCCs,
+		TopNodes:          opts.ShowTopNodes,
+		DegreeBadges:      opts.ShowDegreeBadges,
+		DirectionalFilter: opts.DirectionalFilter,
+		MaxLabelLength:    opts.MaxLabelLength,
+		AnimateEntry:      opts.AnimateEntry,
+		GridLayout:        opts.Layout != "circular" && len(g.Nodes) > simulationLimit,
+		CircularLayout:    opts.Layout == "circular",
+		NodeCount:         len(g.Nodes),
+		Gravity:           opts.Gravity,
+		DisableCenter:     opts.DisableCenter,
+		ClusterAttraction: orDefault(opts.ClusterAttraction, DefaultClusterAttraction),
+		ClusterRepulsion:  orDefault(opts.ClusterRepulsion, DefaultClusterRepulsion),
+		ClusterSeparation: orDefault(opts.ClusterSeparation, DefaultClusterSeparation),
+		Width:             canvasWidth,
+		Height:            canvasHeight,
+	}
+
+	text := opts.Template
+	if text == "" {
+		text = htmlTemplate
+	}
+
+	tmpl, err := template.New("graph").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, nil, err
+	}
+
+	return buf.Bytes(), pathResult, nil
+}
+
+// templateFuncs are the helpers available to page templates.
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (template.JS, error) {
+		b, err := json.Marshal(v)
+		return template.JS(b), err
+	},
+}
+
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}}</title>
+    <script src="https://d3js.org/d3.v7.min.js"></script>
     <style>
         * { margin: 0; padding: 0; box-sizing: border-box; }
         body {
             font-family: -apple-system, BlinkMacSystemFont, "Segoe UI", Roboto, sans-serif;
             overflow: hidden;
-            background: #f5f5f5;
+            background: {{if .Transparent}}transparent{{else}}#f5f5f5{{end}};
         }
         #graph {
             width: 100vw;
             height: 100vh;
-            background: white;
+            background: {{if .Transparent}}transparent{{else}}white{{end}};
         }
         .node { cursor: pointer; }
         .node:hover { filter: brightness(0.85); }
@@ -589,6 +1724,7 @@ const htmlTemplate = `<!DOCTYPE html>
             cursor: pointer;
         }
         .link.directed { marker-end: url(#arrowhead); }
+        .link.directed.clipped-head { marker-end: url(#arrowhead-clipped); }
         .link.filtered-out { opacity: 0.08; }
         .node-label {
             font-size: 12px;
@@ -598,6 +1734,22 @@ const htmlTemplate = `<!DOCTYPE html>
             fill: #333;
         }
         .node.filtered-out .node-label { opacity: 0.3; }
+        .degree-badge {
+            pointer-events: none;
+        }
+        .degree-badge circle {
+            stroke: white;
+            stroke-width: 1;
+        }
+        .degree-badge.in circle { fill: #1976d2; }
+        .degree-badge.out circle { fill: #e65100; }
+        .degree-badge.degree circle { fill: #555; }
+        .degree-badge text {
+            font-size: 8px;
+            fill: white;
+            text-anchor: middle;
+            dominant-baseline: central;
+        }
         .link-label {
             font-size: 10px;
             fill: #666;
@@ -617,7 +1769,7 @@ const htmlTemplate = `<!DOCTYPE html>
             marker-end: url(#arrowhead-highlighted);
         }
         .link-label.highlighted {
-            fill: #ff6b00;
+            fill: #ff6b00 !important;
             font-weight: 600;
         }
         /* Unified edge for multi-edge node pairs */
@@ -681,7 +1833,7 @@ const htmlTemplate = `<!DOCTYPE html>
             fill: #333;
         }
         .multi-edge-label.highlighted {
-            fill: #ff6b00;
+            fill: #ff6b00 !important;
             font-weight: 600;
         }
         .unified-link.filtered-out { opacity: 0.08; }
@@ -713,6 +1865,17 @@ const htmlTemplate = `<!DOCTYPE html>
         .link.directed.on-path {
             marker-end: url(#arrowhead-path);
         }
+        /* Tapered edges are filled wedges, without outline or arrowhead */
+        .link.tapered {
+            fill-opacity: 0.6;
+            stroke-width: 0 !important;
+            marker-end: none !important;
+        }
+        .link.tapered.highlighted,
+        .link.tapered.on-path {
+            fill: #ff6b00 !important;
+            fill-opacity: 1;
+        }
         /* Path invalid node - red highlight */
         .node.path-invalid ellipse,
         .node.path-invalid rect,
@@ -734,6 +1897,99 @@ const htmlTemplate = `<!DOCTYPE html>
             max-width: 300px;
             z-index: 1000;
         }
+        .inspector {
+            position: absolute;
+            top: 16px;
+            right: 16px;
+            width: 260px;
+            max-height: 50vh;
+            overflow-y: auto;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 12px rgba(0,0,0,0.15);
+            padding: 12px 16px;
+            font-size: 12px;
+            z-index: 100;
+            display: none;
+        }
+        .inspector h3 {
+            font-size: 14px;
+            font-weight: 600;
+            margin-bottom: 8px;
+            padding-right: 16px;
+            color: #333;
+            word-break: break-word;
+        }
+        .inspector-close {
+            position: absolute;
+            top: 8px;
+            right: 10px;
+            border: none;
+            background: none;
+            font-size: 16px;
+            color: #999;
+            cursor: pointer;
+        }
+        .inspector-row {
+            display: flex;
+            gap: 8px;
+            padding: 2px 0;
+            border-top: 1px solid #eee;
+        }
+        .inspector-key {
+            flex: 0 0 35%;
+            color: #666;
+            word-break: break-word;
+        }
+        .inspector-value {
+            flex: 1;
+            color: #333;
+            word-break: break-word;
+        }
+        .layout-notice {
+            position: fixed;
+            bottom: 16px;
+            left: 50%;
+            transform: translateX(-50%);
+            background: #fff8e1;
+            border: 1px solid #ffe082;
+            border-radius: 4px;
+            padding: 8px 14px;
+            font-size: 13px;
+            color: #5d4037;
+            box-shadow: 0 2px 6px rgba(0,0,0,0.1);
+        }
+        .edge-filter-group {
+            margin-bottom: 6px;
+        }
+        .edge-filter-attr {
+            font-size: 11px;
+            color: #888;
+            margin-bottom: 2px;
+        }
+        .color-legend {
+            position: absolute;
+            bottom: 16px;
+            left: 16px;
+            background: white;
+            border-radius: 8px;
+            box-shadow: 0 2px 12px rgba(0,0,0,0.15);
+            padding: 8px 12px;
+            font-size: 12px;
+            color: #333;
+            z-index: 100;
+        }
+        .color-legend-bar {
+            width: 160px;
+            height: 10px;
+            margin: 4px 0;
+            border-radius: 2px;
+        }
+        .color-legend-labels {
+            display: flex;
+            justify-content: space-between;
+            color: #666;
+        }
         .tooltip strong { color: #fff; }
         .tooltip .attr { color: #aaa; margin-top: 4px; }
         .controls {
@@ -842,6 +2098,22 @@ const htmlTemplate = `<!DOCTYPE html>
         .search-results.visible {
             display: block;
         }
+        .top-nodes {
+            margin: 0;
+            padding-left: 20px;
+            font-size: 13px;
+        }
+        .top-node {
+            cursor: pointer;
+            padding: 2px 0;
+        }
+        .top-node:hover {
+            color: #1976d2;
+        }
+        .top-node-degree {
+            float: right;
+            color: #888;
+        }
         .search-result-item {
             padding: 8px 10px;
             cursor: pointer;
@@ -906,6 +2178,11 @@ const htmlTemplate = `<!DOCTYPE html>
             cursor: pointer;
             user-select: none;
         }
+        .direction-toggle {
+            display: flex;
+            gap: 12px;
+            margin-top: 8px;
+        }
         /* Cluster/Subgraph styling */
         .cluster-hull {
             fill-opacity: 0.15;
@@ -921,6 +2198,10 @@ const htmlTemplate = `<!DOCTYPE html>
             fill: #555;
             pointer-events: none;
         }
+        {{if .ExtraCSS}}
+        /* RenderOptions.ExtraCSS */
+        {{.ExtraCSS}}
+        {{end}}
     </style>
 </head>
 <body>
@@ -940,6 +2221,11 @@ const htmlTemplate = `<!DOCTYPE html>
                 <input type="range" id="degree-slider" min="0" max="5" value="1" step="1">
                 <span class="slider-value" id="degree-value">1</span>
             </div>
+            {{if .DirectionalFilter}}<div class="direction-toggle" id="degree-direction">
+                <label class="checkbox-control"><input type="radio" name="degree-direction" value="in"><span>In</span></label>
+                <label class="checkbox-control"><input type="radio" name="degree-direction" value="out"><span>Out</span></label>
+                <label class="checkbox-control"><input type="radio" name="degree-direction" value="both" checked><span>Both</span></label>
+            </div>{{end}}
         </div>
         <div class="control-group">
             <label class="checkbox-control">
@@ -947,26 +2233,100 @@ const htmlTemplate = `<!DOCTYPE html>
                 <span>Lock node positions</span>
             </label>
         </div>
+        {{if gt .TopNodes 0}}<div class="control-group">
+            <label>Most Connected</label>
+            <ol class="top-nodes" id="top-nodes"></ol>
+        </div>{{end}}
+        {{if .EdgeFilters}}<div class="control-group" id="edge-filter">
+            <label>Show Edges</label>
+            {{range .EdgeFilters}}<div class="edge-filter-group">
+                <div class="edge-filter-attr">{{.Attribute}}</div>
+                {{$attr := .Attribute}}{{range .Values}}<label class="checkbox-control">
+                    <input type="checkbox" class="edge-filter-toggle" data-attribute="{{$attr}}" data-value="{{.}}" checked>
+                    <span>{{.}}</span>
+                </label>{{end}}
+            </div>{{end}}
+        </div>{{end}}
+        {{if .Layers}}<div class="control-group" id="layer-filter">
+            <label>Layers</label>
+            {{range .Layers}}<label class="checkbox-control">
+                <input type="checkbox" class="layer-toggle" data-layer="{{.}}" checked>
+                <span>{{.}}</span>
+            </label>{{end}}
+        </div>{{end}}
+        {{if .Export}}<div class="control-group">
+            <button class="clear-btn" id="export-dot">Export visible as DOT</button>
+            <button class="clear-btn" id="export-positions">Export with positions</button>
+            <button class="clear-btn" id="copy-image">{{if eq .ImageType "image/png"}}Copy as image{{else}}Download as {{.ImageExt}}{{end}}</button>
+        </div>{{end}}
         <div class="help-text">
             Select a node and adjust the degree slider to filter the view to nodes within N connections.
             Set to "All" to show the complete graph.
         </div>
     </div>
     <div class="tooltip" id="tooltip"></div>
-    <svg id="graph"></svg>
+    {{if .Inspector}}<div class="inspector" id="inspector">
+        <button class="inspector-close" id="inspector-close" title="Close">&times;</button>
+        <h3 id="inspector-title"></h3>
+        <div id="inspector-body"></div>
+    </div>{{end}}
+    {{if .GridLayout}}<div class="layout-notice" id="layout-notice">
+        This graph has {{.NodeCount}} nodes, too many for the force layout, so they are shown on a grid.
+    </div>{{end}}
+    {{if .ColorBy}}<div class="color-legend" id="color-legend">
+        <div>{{.ColorBy.Attribute}}</div>
+        <div class="color-legend-bar"></div>
+        <div class="color-legend-labels"><span>{{.ColorBy.Min}}</span><span>{{.ColorBy.Max}}</span></div>
+    </div>{{end}}
+    <svg id="graph"{{if .Width}} viewBox="0 0 {{.Width}} {{.Height}}"{{end}}></svg>
 
     <script>
     const graphData = {{.GraphJSON}};
-
-    const width = window.innerWidth;
-    const height = window.innerHeight;
+    const edgeStyle = {{.EdgeStyle}}; // "straight", "curved", or "ortho"
+    const fastEdges = {{.FastEdges}}; // draw all edges as one path, without per-edge features
+    const staticLayout = {{.Static}}; // settle the layout on load, then keep it fixed
+    const scaleArrows = {{.ScaleArrows}}; // keep arrowheads the same size on screen at any zoom
+    const gridLayout = {{.GridLayout}}; // too many nodes to simulate: place them on a grid
+    const circularLayout = {{.CircularLayout}}; // RenderOptions.Layout "circular"
+    const transparent = {{.Transparent}}; // no background, whatever bgcolor says
+
+    // Fixed canvas size (from size/ratio or Width/Height), else the window;
+    // the viewBox scales it to fit while preserving aspect
+    const width = {{.Width}} || window.innerWidth;
+    const height = {{.Height}} || window.innerHeight;
 
     // State for filtering
     let selectedNodeId = null;
     let previousSelectedNodeId = null; // Track previous selection to detect changes
     let degreeFilter = 1; // 0 means "All" (no filter), default to 1
+    let degreeDirection = "both"; // "in", "out" or "both": edges the degree filter follows
     let positionsLocked = false; // When true, simulation is stopped but dragging still works
 
+    // Node labels longer than this many characters are drawn truncated,
+    // with the full text in the tooltip; 0 disables truncation
+    const maxLabelLength = {{.MaxLabelLength}};
+
+    function truncateLabel(text) {
+        const chars = Array.from(text);
+        if (maxLabelLength <= 0 || chars.length <= maxLabelLength) return text;
+        return chars.slice(0, Math.max(0, maxLabelLength - 1)).join("") + "…";
+    }
+
+    // Graphviz inches to px, as for the size attribute
+    const pointsPerInch = 72;
+
+    // Nodes scale from 1x to 2x with betweenness, when SizeByCentrality set it
+    const maxBetweenness = d3.max(graphData.nodes, n => n.betweenness || 0) || 0;
+    function nodeScale(d) {
+        return maxBetweenness > 0 ? 1 + (d.betweenness || 0) / maxBetweenness : 1;
+    }
+
+    // An edge's direction follows the graph type, unless its own operator
+    // differs (-> in a graph, -- in a digraph)
+    function linkDirected(d) {
+        return d.operator ? d.operator === "->" : graphData.directed;
+    }
+
     // Build adjacency list for traversal (treat as undirected for reachability)
     const adjacency = new Map();
     graphData.nodes.forEach(n => adjacency.set(n.id, new Set()));
@@ -977,10 +2337,46 @@ const htmlTemplate = `<!DOCTYPE html>
         adjacency.get(targetId).add(sourceId);
     });
 
+    // Directed adjacency for the In/Out degree filter: successors and
+    // predecessors of each node, with undirected edges in both
+    const forwardAdjacency = new Map();
+    const backwardAdjacency = new Map();
+    graphData.nodes.forEach(n => {
+        forwardAdjacency.set(n.id, new Set());
+        backwardAdjacency.set(n.id, new Set());
+    });
+
+    function addDirectedAdjacency(l, sourceId, targetId) {
+        forwardAdjacency.get(sourceId).add(targetId);
+        backwardAdjacency.get(targetId).add(sourceId);
+        if (!linkDirected(l)) {
+            forwardAdjacency.get(targetId).add(sourceId);
+            backwardAdjacency.get(sourceId).add(targetId);
+        }
+    }
+
+    graphData.links.forEach(l => {
+        const sourceId = typeof l.source === 'object' ? l.source.id : l.source;
+        const targetId = typeof l.target === 'object' ? l.target.id : l.target;
+        addDirectedAdjacency(l, sourceId, targetId);
+    });
+
+    // Links merged by CollapseParallel are labeled with their edge count;
+    // dotLabel keeps the edge's own label for exportDOT
+    graphData.links.forEach(l => {
+        if (l.count > 1) {
+            l.dotLabel = l.label;
+            l.label = (l.label ? l.label + " " : "") + "×" + l.count;
+        }
+    });
+
     // BFS to find nodes within N degrees of a starting node
     function getNodesWithinDegree(startId, maxDegree) {
         if (!startId || maxDegree <= 0) return null; // null means show all
 
+        const neighbors = degreeDirection === "out" ? forwardAdjacency
+            : degreeDirection === "in" ? backwardAdjacency
+            : adjacency;
         const visited = new Set([startId]);
         const queue = [{id: startId, depth: 0}];
 
@@ -988,7 +2384,7 @@ const htmlTemplate = `<!DOCTYPE html>
             const {id, depth} = queue.shift();
             if (depth >= maxDegree) continue;
 
-            for (const neighborId of adjacency.get(id) || []) {
+            for (const neighborId of neighbors.get(id) || []) {
                 if (!visited.has(neighborId)) {
                     visited.add(neighborId);
                     queue.push({id: neighborId, depth: depth + 1});
@@ -999,12 +2395,76 @@ const htmlTemplate = `<!DOCTYPE html>
         return visited;
     }
 
+    // Edge attribute values unchecked in the edge filter, by attribute
+    // (RenderOptions.EdgeFilter)
+    const hiddenEdgeValues = new Map();
+
+    function edgeAttrValue(d, attr) {
+        if (attr === "style") return d.style || "";
+        if (attr === "color") return d.color || "";
+        if (attr === "class") return d.class || "";
+        return (d.attributes && d.attributes[attr]) || "";
+    }
+
+    // Layers unchecked in the layer toggles. An element is hidden when all
+    // of its layers are; one without layers is in every layer.
+    const hiddenLayers = new Set();
+
+    function layerHidden(d) {
+        return !!d && !!d.layers && d.layers.every(l => hiddenLayers.has(l));
+    }
+
+    const nodeByIdForLayers = new Map(graphData.nodes.map(n => [n.id, n]));
+
+    // Edges are also hidden with either endpoint's layers
+    function edgeLayerHidden(d) {
+        if (hiddenLayers.size === 0) return false;
+        const sourceId = typeof d.source === 'object' ? d.source.id : d.source;
+        const targetId = typeof d.target === 'object' ? d.target.id : d.target;
+        return layerHidden(d) ||
+            layerHidden(nodeByIdForLayers.get(sourceId)) ||
+            layerHidden(nodeByIdForLayers.get(targetId));
+    }
+
+    function edgeHidden(d) {
+        if (edgeLayerHidden(d)) return true;
+        for (const [attr, values] of hiddenEdgeValues) {
+            if (values.has(edgeAttrValue(d, attr))) return true;
+        }
+        return false;
+    }
+
+    document.querySelectorAll(".edge-filter-toggle").forEach(box => {
+        box.addEventListener("change", function() {
+            const attr = this.dataset.attribute;
+            if (!hiddenEdgeValues.has(attr)) hiddenEdgeValues.set(attr, new Set());
+            if (this.checked) {
+                hiddenEdgeValues.get(attr).delete(this.dataset.value);
+            } else {
+                hiddenEdgeValues.get(attr).add(this.dataset.value);
+            }
+            updateFilter();
+        });
+    });
+
+    document.querySelectorAll(".layer-toggle").forEach(box => {
+        box.addEventListener("change", function() {
+            if (this.checked) {
+                hiddenLayers.delete(this.dataset.layer);
+            } else {
+                hiddenLayers.add(this.dataset.layer);
+            }
+            updateFilter();
+        });
+    });
+
     // Update filter display and apply filtering
     function updateFilter() {
         const visibleNodes = getNodesWithinDegree(selectedNodeId, degreeFilter);
 
         // Update node visibility
         node.classed("filtered-out", d => {
+            if (layerHidden(d)) return true;
             if (!visibleNodes) return false; // Show all
             return !visibleNodes.has(d.id);
         });
@@ -1015,6 +2475,7 @@ const htmlTemplate = `<!DOCTYPE html>
         // Update single-edge link visibility
         if (typeof link !== 'undefined') {
             link.classed("filtered-out", d => {
+                if (edgeHidden(d)) return true;
                 if (!visibleNodes) return false;
                 const sourceId = typeof d.source === 'object' ? d.source.id : d.source;
                 const targetId = typeof d.target === 'object' ? d.target.id : d.target;
@@ -1025,6 +2486,7 @@ const htmlTemplate = `<!DOCTYPE html>
         // Update unified link visibility (for multi-edge groups)
         if (typeof unifiedLinks !== 'undefined') {
             unifiedLinks.classed("filtered-out", d => {
+                if (d.links.every(edgeHidden)) return true;
                 if (!visibleNodes) return false;
                 return !visibleNodes.has(d.nodeA) || !visibleNodes.has(d.nodeB);
             });
@@ -1033,6 +2495,7 @@ const htmlTemplate = `<!DOCTYPE html>
         // Update single-edge link label visibility
         if (typeof linkLabel !== 'undefined') {
             linkLabel.classed("filtered-out", d => {
+                if (edgeHidden(d)) return true;
                 if (!visibleNodes) return false;
                 const sourceId = typeof d.source === 'object' ? d.source.id : d.source;
                 const targetId = typeof d.target === 'object' ? d.target.id : d.target;
@@ -1042,9 +2505,10 @@ const htmlTemplate = `<!DOCTYPE html>
 
         // Update multi-edge label visibility
         if (typeof multiEdgeLabelContainers !== 'undefined') {
-            multiEdgeLabelContainers.forEach(({ container, group }) => {
+            multiEdgeLabelContainers.forEach(({ container, labels, group }) => {
                 const isFiltered = visibleNodes && (!visibleNodes.has(group.nodeA) || !visibleNodes.has(group.nodeB));
                 container.classed("filtered-out", isFiltered);
+                labels.classed("filtered-out", d => edgeHidden(d.link));
             });
         }
 
@@ -1052,7 +2516,7 @@ const htmlTemplate = `<!DOCTYPE html>
         if (typeof curvedEdges !== 'undefined') {
             curvedEdges.forEach(({ link, path, group }) => {
                 const isFiltered = visibleNodes && (!visibleNodes.has(group.nodeA) || !visibleNodes.has(group.nodeB));
-                path.classed("filtered-out", isFiltered);
+                path.classed("filtered-out", isFiltered || edgeHidden(link));
             });
         }
 
@@ -1111,6 +2575,7 @@ const htmlTemplate = `<!DOCTYPE html>
             detail: {
                 selectedNodeId,
                 degree: degreeFilter,
+                direction: degreeDirection,
                 visibleNodeCount: visibleNodes ? visibleNodes.size : graphData.nodes.length
             },
             bubbles: true
@@ -1128,6 +2593,11 @@ const htmlTemplate = `<!DOCTYPE html>
         updateFilter();
     });
 
+    d3.selectAll('input[name="degree-direction"]').on("change", function() {
+        degreeDirection = this.value;
+        updateFilter();
+    });
+
     // Clear selection button
     document.getElementById("clear-selection").addEventListener("click", function() {
         selectedNodeId = null;
@@ -1398,7 +2868,9 @@ const htmlTemplate = `<!DOCTYPE html>
     });
 
     const svg = d3.select("#graph")
-        .attr("viewBox", [0, 0, width, height]);
+        .attr("viewBox", [0, 0, width, height])
+        .style("background", graphData.bgcolor || null);
+    if (transparent) svg.style("background", "transparent");
 
     // Container for zoom/pan
     const g = svg.append("g");
@@ -1408,11 +2880,12 @@ const htmlTemplate = `<!DOCTYPE html>
         .scaleExtent([0.1, 4])
         .on("zoom", (event) => {
             g.attr("transform", event.transform);
+            if (scaleArrows) rescaleArrows(event.transform.k);
         });
     svg.call(zoom);
 
-    // Arrow markers for directed graphs
-    if (graphData.directed) {
+    // Arrow markers for directed graphs, or directed edges in mixed graphs
+    if (graphData.directed || graphData.links.some(linkDirected)) {
         const defs = svg.append("defs");
 
         // Default arrowhead
@@ -1467,6 +2940,20 @@ const htmlTemplate = `<!DOCTYPE html>
             .attr("d", "M10,-5L0,0L10,5")
             .attr("fill", "#999");
 
+        // Arrowhead for compound edges clipped at a cluster's hull, with
+        // its tip at the end of the path
+        defs.append("marker")
+            .attr("id", "arrowhead-clipped")
+            .attr("viewBox", "0 -5 10 10")
+            .attr("refX", 10)
+            .attr("refY", 0)
+            .attr("markerWidth", 6)
+            .attr("markerHeight", 6)
+            .attr("orient", "auto")
+            .append("path")
+            .attr("d", "M0,-5L10,0L0,5")
+            .attr("fill", "#999");
+
         // Arrowhead for curved edges (refX=0 since we'll adjust the path endpoint)
         defs.append("marker")
             .attr("id", "arrowhead-curved")
@@ -1492,6 +2979,33 @@ const htmlTemplate = `<!DOCTYPE html>
             .append("path")
             .attr("d", "M10,-5L0,0L10,5")
             .attr("fill", "#ff6b00");
+
+        // Remember each marker's size, offset and tip for rescaleArrows;
+        // reverse markers point back along the edge, with the tip at x=0
+        defs.selectAll("marker").each(function() {
+            const tipX = this.firstChild.getAttribute("d").startsWith("M10") ? 0 : 10;
+            this.__base = { size: +this.getAttribute("markerWidth"), refX: +this.getAttribute("refX"), tipX };
+        });
+    }
+
+    // scaledRefX returns the refX that keeps a marker's tip where it was
+    // when the marker is drawn at 1/k of its size. The tip sits
+    // (tipX - refX) viewBox units from the end of the line, and each unit
+    // shrinks by k, so that distance has to grow by k.
+    function scaledRefX(refX, tipX, k) {
+        return tipX + (refX - tipX) * k;
+    }
+
+    // Shrink markers by the zoom scale k so they look the same size on
+    // screen, keeping their tips the same distance from the line's end
+    function rescaleArrows(k) {
+        svg.selectAll("defs marker").each(function() {
+            if (!this.__base) return;
+            d3.select(this)
+                .attr("markerWidth", this.__base.size / k)
+                .attr("markerHeight", this.__base.size / k)
+                .attr("refX", scaledRefX(this.__base.refX, this.__base.tipX, k));
+        });
     }
 
     // Force simulation
@@ -1509,6 +3023,26 @@ const htmlTemplate = `<!DOCTYPE html>
         nodeDegrees.set(targetId, (nodeDegrees.get(targetId) || 0) + 1);
     });
 
+    // Top nodes by degree (RenderOptions.ShowTopNodes); clicking one selects
+    // it like a search result
+    const showTopNodes = {{.TopNodes}};
+
+    function renderTopNodes() {
+        if (showTopNodes <= 0) return;
+        const list = d3.select("#top-nodes");
+        list.selectAll("li")
+            .data(graphData.nodes.slice()
+                .sort((a, b) => nodeDegrees.get(b.id) - nodeDegrees.get(a.id) || a.id.localeCompare(b.id))
+                .slice(0, showTopNodes))
+            .join("li")
+            .attr("class", "top-node")
+            .html("")
+            .on("click", (event, d) => selectNodeAndZoom(d))
+            .call(li => li.append("span").text(d => d.label || d.id))
+            .call(li => li.append("span").attr("class", "top-node-degree").text(d => nodeDegrees.get(d.id)));
+    }
+    renderTopNodes();
+
     // Dynamic link distance function - expands more for higher-degree nodes
     function getLinkDistance(d) {
         if (!selectedNodeId) return defaultLinkDistance;
@@ -1583,28 +3117,62 @@ const htmlTemplate = `<!DOCTYPE html>
         });
     }
 
+    // Nodes with a pos attribute start the force layout there; pinned ones
+    // ("x,y!") stay put until dragged
+    if (!gridLayout && !circularLayout) {
+        graphData.nodes.forEach(n => {
+            if (!n.pos) return;
+            n.x = n.pos.x;
+            n.y = n.pos.y;
+            if (n.pos.pinned) {
+                n.fx = n.x;
+                n.fy = n.y;
+            }
+        });
+    }
+
     const simulation = d3.forceSimulation(graphData.nodes)
         .force("link", d3.forceLink(graphData.links)
             .id(d => d.id)
             .distance(getLinkDistance))
         .force("charge", d3.forceManyBody().strength(-400))
         .force("center", d3.forceCenter(width / 2, height / 2))
-        .force("collision", d3.forceCollide().radius(40))
+        .force("collision", d3.forceCollide().radius(d => Math.max(40, Math.max(d.width || 0, d.height || 0) * pointsPerInch / 2 + 15) * nodeScale(d)))
         .force("neighborDistribution", neighborDistributionForce);
 
-    // Clustering forces - attract nodes within same cluster, repel different clusters
-    const clusterAttractionStrength = 0.15;
-    const clusterRepulsionStrength = 0.8;
-    const clusterRepulsionDistance = 200; // Minimum distance between cluster centers
+    // Layout tuning: x/y gravity toward the center, and optionally no
+    // forceCenter at all
+    const gravity = {{.Gravity}};
+    if (gravity > 0) {
+        simulation
+            .force("x", d3.forceX(width / 2).strength(gravity))
+            .force("y", d3.forceY(height / 2).strength(gravity));
+    }
+    const disableCenter = {{.DisableCenter}};
+    if (disableCenter) {
+        simulation.force("center", null);
+    }
 
-    if (graphData.subgraphs && graphData.subgraphs.length > 0) {
+    // Clustering forces - attract nodes within same cluster, repel different clusters
+    const clusterAttractionStrength = {{.ClusterAttraction}};
+    const clusterRepulsionStrength = {{.ClusterRepulsion}};
+    const clusterRepulsionDistance = {{.ClusterSeparation}}; // Minimum distance between cluster centers
+
+    // Subgraphs, plus nodes sharing a group attribute that isn't a
+    // subgraph's name, are clustered together
+    const subgraphIds = new Set((graphData.subgraphs || []).map(sg => sg.id));
+    const forceClusters = (graphData.subgraphs || []).concat(
+        Array.from(d3.group(graphData.nodes.filter(n => n.group && !subgraphIds.has(n.group)), n => n.group),
+            ([id, nodes]) => ({ id, nodes: nodes.map(n => n.id) })));
+
+    if (forceClusters.length > 0) {
         // Build node lookup by id for quick access
         const nodeById = new Map(graphData.nodes.map(n => [n.id, n]));
 
         simulation.force("cluster", function(alpha) {
-            // First pass: calculate centroid for each subgraph
+            // First pass: calculate centroid for each cluster
             const centroids = [];
-            graphData.subgraphs.forEach((sg, i) => {
+            forceClusters.forEach((sg, i) => {
                 if (!sg.nodes || sg.nodes.length === 0) return;
 
                 let cx = 0, cy = 0, count = 0;
@@ -1707,7 +3275,7 @@ const htmlTemplate = `<!DOCTYPE html>
     const nodeByIdForHull = new Map(graphData.nodes.map(n => [n.id, n]));
 
     // Helper function to compute expanded convex hull with padding
-    function computeHullPath(nodeIds, padding = 30) {
+    function computeHull(nodeIds, padding = 30) {
         const points = [];
         nodeIds.forEach(id => {
             const node = nodeByIdForHull.get(id);
@@ -1725,14 +3293,12 @@ const htmlTemplate = `<!DOCTYPE html>
         });
 
         if (points.length < 3) return null;
-
-        const hull = d3.polygonHull(points);
-        if (!hull) return null;
-
-        // Create smooth path using curve
-        return d3.line().curve(d3.curveCatmullRomClosed.alpha(0.5))(hull);
+        return d3.polygonHull(points);
     }
 
+    // Latest hull polygon of each cluster, for clipping compound edges
+    const clusterPolygons = new Map();
+
     // Create hull group (drawn first so it's behind everything)
     const hullGroup = g.append("g").attr("class", "cluster-hulls");
     const labelGroup = g.append("g").attr("class", "cluster-labels");
@@ -1770,9 +3336,11 @@ const htmlTemplate = `<!DOCTYPE html>
     // Function to update hull paths
     function updateHulls() {
         clusterHulls.forEach(({ sg, path }) => {
-            const pathData = computeHullPath(sg.nodes);
-            if (pathData) {
-                path.attr("d", pathData);
+            const hull = computeHull(sg.nodes);
+            if (hull) {
+                clusterPolygons.set(sg.id, hull);
+                // Create smooth path using curve
+                path.attr("d", d3.line().curve(d3.curveCatmullRomClosed.alpha(0.5))(hull));
             }
         });
 
@@ -1796,19 +3364,24 @@ const htmlTemplate = `<!DOCTYPE html>
     }
 
     // Detect multi-edge pairs and classify them
-    const edgePairs = new Map(); // key: "A|B" (sorted), value: { links: [], directions: Set }
+    const edgePairs = new Map(); // key: JSON of the sorted ends, value: { links: [], directions: Set, nodeA, nodeB }
     graphData.links.forEach((l, i) => {
         const sourceId = typeof l.source === 'object' ? l.source.id : l.source;
         const targetId = typeof l.target === 'object' ? l.target.id : l.target;
-        const sortedKey = [sourceId, targetId].sort().join('|');
-        const directionKey = sourceId + '->' + targetId;
+        const ends = [sourceId, targetId].sort();
+        const sortedKey = JSON.stringify(ends);
 
         if (!edgePairs.has(sortedKey)) {
-            edgePairs.set(sortedKey, { links: [], directions: new Set(), sourceId: sortedKey.split('|')[0], targetId: sortedKey.split('|')[1] });
+            edgePairs.set(sortedKey, { links: [], directions: new Set(), nodeA: ends[0], nodeB: ends[1] });
         }
         const pair = edgePairs.get(sortedKey);
         pair.links.push(i);
-        pair.directions.add(directionKey);
+        // Only arrows between two distinct nodes count toward a
+        // bidirectional pair: undirected edges and self-loops have no
+        // direction to reverse
+        if (linkDirected(l) && sourceId !== targetId) {
+            pair.directions.add(sourceId === pair.nodeA ? "forward" : "backward");
+        }
 
         l._index = i;
         l._pairKey = sortedKey;
@@ -1819,16 +3392,19 @@ const htmlTemplate = `<!DOCTYPE html>
     const multiEdgeGroups = [];
 
     edgePairs.forEach((pair, key) => {
+        if (fastEdges) {
+            // All edges are drawn by the single fast-edge path instead
+            return;
+        }
         if (pair.links.length === 1) {
             singleEdgeLinks.push(graphData.links[pair.links[0]]);
         } else {
-            // Check if bidirectional (has edges in both directions)
-            const [nodeA, nodeB] = key.split('|');
-            const isBidirectional = pair.directions.has(nodeA + '->' + nodeB) && pair.directions.has(nodeB + '->' + nodeA);
+            // Bidirectional: has arrows in both directions
+            const isBidirectional = pair.directions.size === 2;
             multiEdgeGroups.push({
                 key,
-                nodeA,
-                nodeB,
+                nodeA: pair.nodeA,
+                nodeB: pair.nodeB,
                 linkIndices: pair.links,
                 links: pair.links.map(i => graphData.links[i]),
                 isBidirectional
@@ -1839,39 +3415,66 @@ const htmlTemplate = `<!DOCTYPE html>
     // State for highlighted edge
     let highlightedEdgeIndex = null;
 
-    // Draw single-edge links (unchanged behavior)
-    const link = g.append("g")
-        .attr("class", "links")
-        .selectAll("line")
-        .data(singleEdgeLinks)
-        .join("line")
-        .attr("class", d => graphData.directed ? "link directed" : "link")
-        .classed("on-path", d => d.onPath)
-        .classed("dimmed", d => hasPath && !d.onPath)
-        .attr("stroke", d => normalizeColor(d.color) || "#999")
-        .attr("stroke-width", 2)
-        .attr("stroke-dasharray", d => d.style === "dashed" ? "5,5" : null)
-        .on("click", function(event, d) {
-            event.stopPropagation();
-            if (highlightedEdgeIndex === d._index) {
-                highlightedEdgeIndex = null;
-            } else {
-                highlightedEdgeIndex = d._index;
-            }
-            updateEdgeHighlight();
+    // Fast edge mode: every edge is a straight segment of one shared path
+    const fastEdgePath = fastEdges
+        ? g.append("path")
+            .attr("class", "fast-edges")
+            .attr("fill", "none")
+            .attr("stroke", "#999")
+            .attr("stroke-opacity", 0.6)
+            .attr("stroke-width", 1)
+        : null;
+
+    // withClass appends the CSS classes from an element's Graphviz class
+    // attribute to the renderer's own
+    function withClass(base, d) {
+        return d.class ? base + " " + d.class : base;
+    }
 
-            const customEvent = new CustomEvent("edgeClick", {
-                detail: {
-                    source: typeof d.source === 'object' ? d.source.id : d.source,
-                    target: typeof d.target === 'object' ? d.target.id : d.target,
-                    label: d.label,
-                    color: d.color,
-                    highlighted: highlightedEdgeIndex === d._index
-                },
-                bubbles: true
+    // Draw single-edge links as paths so they can follow edgeStyle
+    const linkGroup = g.append("g").attr("class", "links");
+
+    // Style a selection of single-edge links and attach click handling
+    function setupLinks(selection) {
+        return selection
+            .attr("class", d => withClass(linkDirected(d) ? "link directed" : "link", d))
+            .classed("on-path", d => d.onPath)
+            .classed("dimmed", d => hasPath && !d.onPath)
+            .each(function(d) {
+                if (d.pathIndex) this.style.setProperty("stroke", pathColor(d.pathIndex), "important");
+            })
+            .attr("stroke", d => normalizeColor(d.color) || "#999")
+            .attr("stroke-width", d => d.penWidth || d.width || (d.count > 1 ? 2 + Math.min(d.count - 1, 6) : 2))
+            .attr("stroke-dasharray", d => d.style === "dashed" ? "5,5" : null)
+            .classed("tapered", d => d.tapered && edgeStyle === "straight")
+            .style("fill", d => d.tapered && edgeStyle === "straight" ? normalizeColor(d.color) || "#999" : null)
+            .on("click", function(event, d) {
+                event.stopPropagation();
+                if (highlightedEdgeIndex === d._index) {
+                    highlightedEdgeIndex = null;
+                } else {
+                    highlightedEdgeIndex = d._index;
+                }
+                updateEdgeHighlight();
+
+                const customEvent = new CustomEvent("edgeClick", {
+                    detail: {
+                        source: typeof d.source === 'object' ? d.source.id : d.source,
+                        target: typeof d.target === 'object' ? d.target.id : d.target,
+                        label: d.label,
+                        color: d.color,
+                        attributes: d.attributes || {},
+                        highlighted: highlightedEdgeIndex === d._index
+                    },
+                    bubbles: true
+                });
+                document.dispatchEvent(customEvent);
             });
-            document.dispatchEvent(customEvent);
-        });
+    }
+
+    let link = setupLinks(linkGroup.selectAll("path")
+        .data(singleEdgeLinks)
+        .join("path"));
 
     // Draw unified lines for multi-edge groups
     const unifiedLinkGroup = g.append("g").attr("class", "unified-links");
@@ -1882,7 +3485,7 @@ const htmlTemplate = `<!DOCTYPE html>
         .join("line")
         .attr("class", d => {
             let cls = "unified-link";
-            if (graphData.directed) cls += " directed";
+            if (d.links.some(linkDirected)) cls += " directed";
             if (d.isBidirectional) cls += " bidirectional";
             return cls;
         })
@@ -1919,10 +3522,10 @@ const htmlTemplate = `<!DOCTYPE html>
 
             const path = curvedEdgeGroup.append("path")
                 .datum(link)
-                .attr("class", "curved-edge")
+                .attr("class", withClass("curved-edge", link))
                 // Show curved edge if on path
                 .classed("visible", link.onPath)
-                .classed("directed", link.onPath && graphData.directed)
+                .classed("directed", link.onPath && linkDirected(link))
                 .classed("on-path", link.onPath)
                 .attr("stroke", link.onPath ? "#ff6b00" : (normalizeColor(link.color) || "#ff6b00"))
                 .attr("stroke-width", link.onPath ? 4 : 3);
@@ -1941,34 +3544,49 @@ const htmlTemplate = `<!DOCTYPE html>
 
     // Draw labels for single-edge links
     const singleEdgeLabels = singleEdgeLinks.filter(d => d.label);
-    const linkLabel = g.append("g")
-        .attr("class", "link-labels")
-        .selectAll("text")
-        .data(singleEdgeLabels)
-        .join("text")
-        .attr("class", "link-label")
-        .classed("dimmed", d => hasPath && !d.onPath)
-        .text(d => d.label)
-        .on("click", function(event, d) {
-            event.stopPropagation();
-            if (highlightedEdgeIndex === d._index) {
-                highlightedEdgeIndex = null;
-            } else {
-                highlightedEdgeIndex = d._index;
-            }
-            updateEdgeHighlight();
+    const linkLabelGroup = g.append("g").attr("class", "link-labels");
+
+    // Apply fontcolor (or a link's labelfontcolor) and fontsize from a node
+    // or link (found with get) to its label text; unset values keep the
+    // stylesheet defaults
+    function applyLabelFont(selection, get = d => d) {
+        selection
+            .style("fill", d => normalizeColor(get(d).fontColor || get(d).labelFontColor))
+            .style("font-size", d => get(d).fontSize ? get(d).fontSize + "px" : null);
+    }
 
-            const customEvent = new CustomEvent("edgeLabelClick", {
-                detail: {
-                    source: typeof d.source === 'object' ? d.source.id : d.source,
-                    target: typeof d.target === 'object' ? d.target.id : d.target,
-                    label: d.label,
-                    highlighted: highlightedEdgeIndex === d._index
-                },
-                bubbles: true
+    // Style a selection of single-edge labels and attach click handling
+    function setupLinkLabels(selection) {
+        return selection
+            .attr("class", "link-label")
+            .classed("dimmed", d => hasPath && !d.onPath)
+            .call(applyLabelFont)
+            .text(d => d.label)
+            .on("click", function(event, d) {
+                event.stopPropagation();
+                if (highlightedEdgeIndex === d._index) {
+                    highlightedEdgeIndex = null;
+                } else {
+                    highlightedEdgeIndex = d._index;
+                }
+                updateEdgeHighlight();
+
+                const customEvent = new CustomEvent("edgeLabelClick", {
+                    detail: {
+                        source: typeof d.source === 'object' ? d.source.id : d.source,
+                        target: typeof d.target === 'object' ? d.target.id : d.target,
+                        label: d.label,
+                        highlighted: highlightedEdgeIndex === d._index
+                    },
+                    bubbles: true
+                });
+                document.dispatchEvent(customEvent);
             });
-            document.dispatchEvent(customEvent);
-        });
+    }
+
+    let linkLabel = setupLinkLabels(linkLabelGroup.selectAll("text")
+        .data(singleEdgeLabels)
+        .join("text"));
 
     // Draw stacked labels for multi-edge groups
     const multiEdgeLabelGroup = g.append("g").attr("class", "multi-edge-label-groups");
@@ -1988,6 +3606,7 @@ const htmlTemplate = `<!DOCTYPE html>
             .join("text")
             .attr("class", "multi-edge-label")
             .classed("dimmed", d => hasPath && !d.link.onPath)
+            .call(applyLabelFont, d => d.link)
             .text(d => d.link.label)
             .attr("text-anchor", "middle")
             .on("click", function(event, d) {
@@ -2030,269 +3649,542 @@ const htmlTemplate = `<!DOCTYPE html>
             const isSelected = link._index === highlightedEdgeIndex;
             const isOnPath = link.onPath;
             path.classed("visible", isSelected || isOnPath);
-            path.classed("directed", (isSelected || isOnPath) && graphData.directed);
+            path.classed("directed", (isSelected || isOnPath) && linkDirected(link));
             path.classed("highlighted", isSelected && !isOnPath);
         });
     }
 
     // Draw nodes
-    const node = g.append("g")
-        .attr("class", "nodes")
-        .selectAll("g")
-        .data(graphData.nodes)
-        .join("g")
-        .attr("class", "node")
-        .classed("on-path", d => d.onPath)
-        .classed("path-invalid", d => d.pathInvalid)
-        .classed("dimmed", d => hasPath && !d.onPath && !d.pathInvalid)
-        .call(drag(simulation));
+    const nodeGroup = g.append("g").attr("class", "nodes");
 
     // Color scale for nodes without explicit colors
     const colorScale = d3.scaleOrdinal(d3.schemeTableau10);
 
-    // Node shapes - supporting common Graphviz shapes
-    node.each(function(d) {
-        const el = d3.select(this);
-        const shape = (d.shape || "ellipse").toLowerCase();
-        // fillColor takes precedence, then color, then auto-generated
-        const autoColor = colorScale(d.group || d.id);
-        const fillColor = normalizeColor(d.fillColor) || normalizeColor(d.color) || autoColor;
-        // stroke color: explicit color, or darker version of fill
-        const strokeColor = normalizeColor(d.color) || safeColorDarker(fillColor, 0.5, '#666');
-
-        if (shape === "box" || shape === "rect" || shape === "rectangle" || shape === "square") {
-            el.append("rect")
-                .attr("width", 50)
-                .attr("height", 30)
-                .attr("x", -25)
-                .attr("y", -15)
-                .attr("rx", 4)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "circle") {
-            el.append("circle")
-                .attr("r", 20)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "point") {
-            el.append("circle")
-                .attr("r", 5)
-                .attr("fill", strokeColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1);
-        } else if (shape === "diamond") {
-            el.append("polygon")
-                .attr("points", "0,-20 25,0 0,20 -25,0")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "triangle" || shape === "invtriangle") {
-            const points = shape === "invtriangle"
-                ? "-25,-15 25,-15 0,20"  // pointing down
-                : "-25,15 25,15 0,-20";   // pointing up
-            el.append("polygon")
-                .attr("points", points)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "hexagon") {
-            el.append("polygon")
-                .attr("points", "-25,0 -12,-18 12,-18 25,0 12,18 -12,18")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "octagon") {
-            el.append("polygon")
-                .attr("points", "-10,-20 10,-20 22,-10 22,10 10,20 -10,20 -22,10 -22,-10")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "pentagon") {
-            el.append("polygon")
-                .attr("points", "0,-20 22,-6 14,18 -14,18 -22,-6")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "house") {
-            el.append("polygon")
-                .attr("points", "-25,18 -25,-5 0,-20 25,-5 25,18")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "invhouse") {
-            el.append("polygon")
-                .attr("points", "-25,-18 -25,5 0,20 25,5 25,-18")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "parallelogram") {
-            el.append("polygon")
-                .attr("points", "-18,-15 28,-15 18,15 -28,15")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "trapezium") {
-            el.append("polygon")
-                .attr("points", "-18,-15 18,-15 28,15 -28,15")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "cylinder") {
-            // Cylinder: rectangle with elliptical top and bottom
-            const g = el.append("g");
-            // Bottom ellipse (partial, just the visible bottom curve)
-            g.append("ellipse")
-                .attr("cx", 0)
-                .attr("cy", 15)
-                .attr("rx", 25)
-                .attr("ry", 6)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-            // Body rectangle
-            g.append("rect")
-                .attr("x", -25)
-                .attr("y", -15)
-                .attr("width", 50)
-                .attr("height", 30)
-                .attr("fill", fillColor)
-                .attr("stroke", "none");
-            // Side lines
-            g.append("line")
-                .attr("x1", -25).attr("y1", -15)
-                .attr("x2", -25).attr("y2", 15)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-            g.append("line")
-                .attr("x1", 25).attr("y1", -15)
-                .attr("x2", 25).attr("y2", 15)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-            // Top ellipse
-            g.append("ellipse")
-                .attr("cx", 0)
-                .attr("cy", -15)
-                .attr("rx", 25)
-                .attr("ry", 6)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "plaintext" || shape === "plain" || shape === "none") {
-            // No shape, just the label
-        } else if (shape === "star") {
-            // 5-pointed star
-            const outerR = 22, innerR = 10;
-            let points = "";
-            for (let i = 0; i < 5; i++) {
-                const outerAngle = (i * 72 - 90) * Math.PI / 180;
-                const innerAngle = ((i * 72) + 36 - 90) * Math.PI / 180;
-                points += Math.cos(outerAngle) * outerR + "," + Math.sin(outerAngle) * outerR + " ";
-                points += Math.cos(innerAngle) * innerR + "," + Math.sin(innerAngle) * innerR + " ";
-            }
-            el.append("polygon")
-                .attr("points", points.trim())
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "doublecircle") {
-            el.append("circle")
-                .attr("r", 22)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-            el.append("circle")
-                .attr("r", 17)
-                .attr("fill", "none")
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else if (shape === "doubleoctagon") {
-            el.append("polygon")
-                .attr("points", "-10,-22 10,-22 24,-10 24,10 10,22 -10,22 -24,10 -24,-10")
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-            el.append("polygon")
-                .attr("points", "-8,-17 8,-17 19,-8 19,8 8,17 -8,17 -19,8 -19,-8")
-                .attr("fill", "none")
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
-        } else {
-            // Default: ellipse/oval
-            el.append("ellipse")
-                .attr("rx", 25)
-                .attr("ry", 18)
-                .attr("fill", fillColor)
-                .attr("stroke", strokeColor)
-                .attr("stroke-width", 1.5);
+    // With stable colors (RenderOptions.StableColors), a key's palette
+    // entry comes from its FNV-1a hash rather than from the order keys are
+    // first seen in
+    const stableColors = {{.StableColors}};
+
+    function hashString(s) {
+        let h = 0x811c9dc5;
+        for (let i = 0; i < s.length; i++) {
+            h ^= s.charCodeAt(i);
+            h = Math.imul(h, 0x01000193);
         }
-    });
+        return h >>> 0;
+    }
+
+    function autoNodeColor(d) {
+        const key = String(d.group || d.id);
+        if (!stableColors) return colorScale(key);
+        const palette = d3.schemeTableau10;
+        return palette[hashString(key) % palette.length];
+    }
 
-    // Node labels
-    node.append("text")
-        .attr("class", "node-label")
-        .attr("dy", 1)
-        .text(d => d.label || d.id);
+    // Heatmap fill from a numeric node attribute (RenderOptions.ColorByAttribute)
+    const colorBy = {{if .ColorBy}}{ attribute: {{.ColorBy.Attribute}}, min: {{.ColorBy.Min}}, max: {{.ColorBy.Max}} }{{else}}null{{end}};
+    const attributeColor = colorBy && d3.scaleSequential(d3.interpolateViridis).domain([colorBy.min, colorBy.max]);
+
+    // Cyclic components get their own colors, everything else is gray
+    // (RenderOptions.HighlightSCCs)
+    const highlightSCCs = {{.HighlightSCCs}};
+    const sccColor = d3.scaleOrdinal(d3.schemeSet1);
+
+    function sccFill(d) {
+        return d.scc ? sccColor(d.scc) : "#ccc";
+    }
+
+    // Each path between RenderOptions.PathFrom and PathTo gets its own color
+    const pathColor = d3.scaleOrdinal(d3.schemeTableau10);
+
+    function attributeFill(d) {
+        const raw = d.attributes && d.attributes[colorBy.attribute];
+        const v = raw === undefined || raw === "" ? NaN : Number(raw);
+        return isNaN(v) ? "#bbb" : attributeColor(v);
+    }
+
+    if (colorBy) {
+        const stops = d3.range(0, 1.01, 0.1).map(t => attributeColor.interpolator()(t));
+        document.querySelector("#color-legend .color-legend-bar").style.background =
+            "linear-gradient(to right, " + stops.join(", ") + ")";
+    }
 
     // Tooltip
     const tooltip = d3.select("#tooltip");
 
-    node.on("mouseover", function(event, d) {
-        let html = '<strong>' + (d.label || d.id) + '</strong>';
-        if (d.attributes && Object.keys(d.attributes).length > 0) {
-            html += '<div class="attr">';
-            for (const [k, v] of Object.entries(d.attributes)) {
-                html += k + ': ' + v + '<br>';
-            }
-            html += '</div>';
+    // Decoration line segments [x1, y1, x2, y2] for each decorated base shape
+    const decorationMarks = {
+        diamond: [[-5, -16, 5, -16], [-5, 16, 5, 16], [-20, -4, -20, 4], [20, -4, 20, 4]],
+        box: [[-25, -9, -19, -15], [19, -15, 25, -9], [25, 9, 19, 15], [-19, 15, -25, 9]],
+        circle: [[-12, -16, 12, -16], [-12, 16, 12, 16]]
+    };
+
+    // Gradient fills for nodes with a fillcolor list ("yellow:orange");
+    // style=radial gives a radial gradient
+    const nodeGradientDefs = svg.append("defs").attr("class", "node-gradients");
+    let nodeGradientCount = 0;
+    let nodeClipCount = 0;
+
+    function nodeGradient(d) {
+        const id = "node-gradient-" + (nodeGradientCount++);
+        const radial = (d.style || "").includes("radial");
+        const gradient = nodeGradientDefs.append(radial ? "radialGradient" : "linearGradient")
+            .attr("id", id);
+        d.fillStops.forEach((c, i) => {
+            gradient.append("stop")
+                .attr("offset", (i / (d.fillStops.length - 1) * 100) + "%")
+                .attr("stop-color", normalizeColor(c));
+        });
+        return "url(#" + id + ")";
+    }
+
+    // Points of a shape=polygon node: a regular polygon with d.sides sides
+    // (default 4) standing on a flat edge, sheared right at the top by
+    // d.skew, then rotated d.orientation degrees clockwise
+    function polygonPoints(d) {
+        const sides = d.sides || 4;
+        const skew = d.skew || 0;
+        const rotation = (d.orientation || 0) * Math.PI / 180;
+        const points = [];
+        for (let i = 0; i < sides; i++) {
+            const angle = Math.PI / 2 + Math.PI / sides + 2 * Math.PI * i / sides;
+            const y = Math.sin(angle) * 20;
+            const x = Math.cos(angle) * 25 - skew * y;
+            points.push([
+                x * Math.cos(rotation) - y * Math.sin(rotation),
+                x * Math.sin(rotation) + y * Math.cos(rotation)
+            ].map(v => Math.round(v * 100) / 100).join(","));
         }
+        return points.join(" ");
+    }
 
-        tooltip
-            .style("opacity", 1)
-            .style("left", (event.pageX + 12) + "px")
-            .style("top", (event.pageY - 12) + "px")
-            .html(html);
-    })
-    .on("mousemove", function(event) {
-        tooltip
-            .style("left", (event.pageX + 12) + "px")
-            .style("top", (event.pageY - 12) + "px");
-    })
-    .on("mouseout", function() {
-        tooltip.style("opacity", 0);
-    });
+    // Build shapes, labels, and event handlers for a selection of node groups
+    function setupNodes(selection) {
+        selection
+            .attr("class", d => withClass("node", d))
+            .classed("on-path", d => d.onPath)
+            .classed("path-invalid", d => d.pathInvalid)
+            .classed("dimmed", d => hasPath && !d.onPath && !d.pathInvalid);
+
+        // Static layouts stay where they settled
+        if (!staticLayout) selection.call(drag(simulation));
+
+        // Node shapes - supporting common Graphviz shapes
+        selection.each(function(d) {
+            const el = d3.select(this);
+            const shape = (d.shape || "ellipse").toLowerCase();
+            // A color-by attribute or SCC highlighting decides the fill;
+            // otherwise fillColor takes precedence, then color, then
+            // auto-generated
+            const autoColor = autoNodeColor(d);
+            const gradient = !colorBy && !highlightSCCs && d.fillStops && d.fillStops.length > 1;
+            const fillColor = colorBy ? attributeFill(d)
+                : highlightSCCs ? sccFill(d)
+                : gradient ? nodeGradient(d)
+                : normalizeColor(d.fillColor) || normalizeColor(d.color) || autoColor;
+            // stroke color: explicit color, or darker version of fill
+            const strokeColor = normalizeColor(d.color) ||
+                safeColorDarker(gradient ? normalizeColor(d.fillColor) : fillColor, 0.5, '#666');
+
+            if (shape === "box" || shape === "rect" || shape === "rectangle" || shape === "square") {
+                el.append("rect")
+                    .attr("width", 50)
+                    .attr("height", 30)
+                    .attr("x", -25)
+                    .attr("y", -15)
+                    .attr("rx", d.rounded ? 4 : 0) // square corners unless style=rounded
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "circle") {
+                el.append("circle")
+                    .attr("r", 20)
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "point") {
+                el.append("circle")
+                    .attr("r", 5)
+                    .attr("fill", normalizeColor(d.fillColor) || strokeColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1);
+            } else if (shape === "diamond") {
+                el.append("polygon")
+                    .attr("points", "0,-20 25,0 0,20 -25,0")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "triangle" || shape === "invtriangle") {
+                const points = shape === "invtriangle"
+                    ? "-25,-15 25,-15 0,20"  // pointing down
+                    : "-25,15 25,15 0,-20";   // pointing up
+                el.append("polygon")
+                    .attr("points", points)
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "hexagon") {
+                el.append("polygon")
+                    .attr("points", "-25,0 -12,-18 12,-18 25,0 12,18 -12,18")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "polygon") {
+                el.append("polygon")
+                    .attr("points", polygonPoints(d))
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "octagon") {
+                el.append("polygon")
+                    .attr("points", "-10,-20 10,-20 22,-10 22,10 10,20 -10,20 -22,10 -22,-10")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "pentagon") {
+                el.append("polygon")
+                    .attr("points", "0,-20 22,-6 14,18 -14,18 -22,-6")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "house") {
+                el.append("polygon")
+                    .attr("points", "-25,18 -25,-5 0,-20 25,-5 25,18")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "invhouse") {
+                el.append("polygon")
+                    .attr("points", "-25,-18 -25,5 0,20 25,5 25,-18")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "parallelogram") {
+                el.append("polygon")
+                    .attr("points", "-18,-15 28,-15 18,15 -28,15")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "trapezium") {
+                el.append("polygon")
+                    .attr("points", "-18,-15 18,-15 28,15 -28,15")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "cylinder") {
+                // Cylinder: rectangle with elliptical top and bottom
+                const g = el.append("g");
+                // Bottom ellipse (partial, just the visible bottom curve)
+                g.append("ellipse")
+                    .attr("cx", 0)
+                    .attr("cy", 15)
+                    .attr("rx", 25)
+                    .attr("ry", 6)
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+                // Body rectangle
+                g.append("rect")
+                    .attr("x", -25)
+                    .attr("y", -15)
+                    .attr("width", 50)
+                    .attr("height", 30)
+                    .attr("fill", fillColor)
+                    .attr("stroke", "none");
+                // Side lines
+                g.append("line")
+                    .attr("x1", -25).attr("y1", -15)
+                    .attr("x2", -25).attr("y2", 15)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+                g.append("line")
+                    .attr("x1", 25).attr("y1", -15)
+                    .attr("x2", 25).attr("y2", 15)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+                // Top ellipse
+                g.append("ellipse")
+                    .attr("cx", 0)
+                    .attr("cy", -15)
+                    .attr("rx", 25)
+                    .attr("ry", 6)
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "plaintext" || shape === "plain" || shape === "none") {
+                // No shape, just the label
+            } else if (shape === "star") {
+                // 5-pointed star
+                const outerR = 22, innerR = 10;
+                let points = "";
+                for (let i = 0; i < 5; i++) {
+                    const outerAngle = (i * 72 - 90) * Math.PI / 180;
+                    const innerAngle = ((i * 72) + 36 - 90) * Math.PI / 180;
+                    points += Math.cos(outerAngle) * outerR + "," + Math.sin(outerAngle) * outerR + " ";
+                    points += Math.cos(innerAngle) * innerR + "," + Math.sin(innerAngle) * innerR + " ";
+                }
+                el.append("polygon")
+                    .attr("points", points.trim())
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "doublecircle") {
+                el.append("circle")
+                    .attr("r", 22)
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+                el.append("circle")
+                    .attr("r", 17)
+                    .attr("fill", "none")
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else if (shape === "doubleoctagon") {
+                el.append("polygon")
+                    .attr("points", "-10,-22 10,-22 24,-10 24,10 10,22 -10,22 -24,10 -24,-10")
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+                el.append("polygon")
+                    .attr("points", "-8,-17 8,-17 19,-8 19,8 8,17 -8,17 -19,8 -19,-8")
+                    .attr("fill", "none")
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            } else {
+                // Default: ellipse/oval
+                el.append("ellipse")
+                    .attr("rx", 25)
+                    .attr("ry", 18)
+                    .attr("fill", fillColor)
+                    .attr("stroke", strokeColor)
+                    .attr("stroke-width", 1.5);
+            }
 
-    // Node click handler - selects node and emits custom event
-    node.on("click", function(event, d) {
-        event.stopPropagation();
+            // Corner marks for decorated shapes (Mdiamond, Msquare, Mcircle)
+            if (d.decorated && decorationMarks[shape]) {
+                decorationMarks[shape].forEach(([x1, y1, x2, y2]) => {
+                    el.append("line")
+                        .attr("class", "decoration")
+                        .attr("x1", x1).attr("y1", y1)
+                        .attr("x2", x2).attr("y2", y2)
+                        .attr("stroke", strokeColor)
+                        .attr("stroke-width", 1.5);
+                });
+            }
 
-        // Toggle selection
-        if (selectedNodeId === d.id) {
-            selectedNodeId = null;
-        } else {
-            selectedNodeId = d.id;
-        }
-        updateFilter();
+            // penwidth replaces the outline's default stroke width
+            if (d.penWidth) {
+                el.selectAll(":scope > *").attr("stroke-width", d.penWidth);
+            }
 
-        // Emit custom event
-        const customEvent = new CustomEvent("nodeClick", {
-            detail: {
-                id: d.id,
-                label: d.label,
-                color: d.color,
-                shape: d.shape,
-                group: d.group,
-                attributes: d.attributes || {},
-                position: { x: d.x, y: d.y },
-                selected: selectedNodeId === d.id
-            },
-            bubbles: true
+            // peripheries=0 drops the outline, leaving only the fill
+            if (d.noOutline) {
+                el.selectAll(":scope > *").attr("stroke", "none");
+            }
+
+            // Extra peripheries: concentric unfilled copies of the outline
+            const outline = this.firstChild;
+            for (let i = 1; outline && i < (d.peripheries || 1); i++) {
+                const ring = outline.cloneNode(true);
+                const scale = 1 + 0.18 * i;
+                d3.select(ring)
+                    .classed("periphery", true)
+                    .attr("transform", "scale(" + scale + ")")
+                    .attr("fill", "none")
+                    .style("vector-effect", "non-scaling-stroke")
+                    .selectAll("*")
+                    .attr("fill", "none")
+                    .style("vector-effect", "non-scaling-stroke");
+                this.appendChild(ring);
+            }
+
+            // Centrality sizing scales the whole outline, peripheries included
+            const scale = nodeScale(d);
+            if (scale !== 1) {
+                el.selectAll(":scope > *").attr("transform", function() {
+                    return "scale(" + scale + ") " + (this.getAttribute("transform") || "");
+                });
+            }
         });
-        document.dispatchEvent(customEvent);
 
-        console.log("Node clicked:", d);
-    });
+        // Node images, sized to fit inside the shape
+        selection.filter(d => d.image)
+            .append("image")
+            .attr("class", "node-image")
+            .attr("href", d => d.image)
+            .attr("x", -20)
+            .attr("y", -20)
+            .attr("width", 40)
+            .attr("height", 40)
+            .attr("preserveAspectRatio", "xMidYMid meet");
+
+        // Node labels; multiline and wrapped labels get one tspan per
+        // line, centered vertically
+        selection.append("text")
+            .attr("class", "node-label")
+            .attr("dy", d => d.image ? 30 : 1) // below the image, if any
+            .call(applyLabelFont)
+            .each(function(d) {
+                const text = d3.select(this);
+                if (d.noLabel) return;
+                if (!d.labelLines) {
+                    text.text(truncateLabel(d.label || d.id));
+                    return;
+                }
+                d.labelLines.forEach((line, i) => {
+                    text.append("tspan")
+                        .attr("x", 0)
+                        .attr("dy", i === 0 ? -(d.labelLines.length - 1) * 0.55 + "em" : "1.1em")
+                        .text(truncateLabel(line));
+                });
+            });
+
+        // Size shapes to their width and height, in inches, and grow them
+        // to fit multiline labels, unless fixedsize clips the label instead.
+        // Each outline is scaled from its own drawn size, and circles and
+        // points stay round, taking whichever dimension is given.
+        selection.filter(d => d.labelLines || d.width || d.height).each(function(d) {
+            const outline = this.firstChild.getBBox();
+            const shape = (d.shape || "ellipse").toLowerCase();
+            const round = shape === "circle" || shape === "doublecircle" || shape === "point";
+            let w = d.width ? d.width * pointsPerInch : outline.width;
+            let h = d.height ? d.height * pointsPerInch : outline.height;
+            if (round) {
+                w = h = d.width && !d.height ? w : d.height && !d.width ? h : Math.max(w, h);
+            }
+            if (d.fixedSize) {
+                const id = "node-clip-" + (nodeClipCount++);
+                nodeGradientDefs.append("clipPath")
+                    .attr("id", id)
+                    .append("rect")
+                    .attr("x", -w / 2).attr("y", -h / 2)
+                    .attr("width", w).attr("height", h);
+                d3.select(this).select(".node-label").attr("clip-path", "url(#" + id + ")");
+            } else if (!d.noLabel) {
+                const box = this.querySelector(".node-label").getBBox();
+                w = Math.max(w, box.width + 12);
+                h = Math.max(h, box.height + 8);
+                if (round) w = h = Math.max(w, h);
+            }
+            const sx = w / outline.width;
+            const sy = h / outline.height;
+            if (sx === 1 && sy === 1) return;
+            d3.select(this).selectAll(":scope > :not(text):not(image)").attr("transform", function() {
+                return "scale(" + sx + "," + sy + ") " + (this.getAttribute("transform") || "");
+            });
+        });
+
+        selection.on("mouseover", function(event, d) {
+            let html = '<strong>' + (d.label || d.id) + '</strong>';
+            if (d.attributes && Object.keys(d.attributes).length > 0) {
+                html += '<div class="attr">';
+                for (const [k, v] of Object.entries(d.attributes)) {
+                    html += k + ': ' + v + '<br>';
+                }
+                html += '</div>';
+            }
+
+            tooltip
+                .style("opacity", 1)
+                .style("left", (event.pageX + 12) + "px")
+                .style("top", (event.pageY - 12) + "px")
+                .html(html);
+        })
+        .on("mousemove", function(event) {
+            tooltip
+                .style("left", (event.pageX + 12) + "px")
+                .style("top", (event.pageY - 12) + "px");
+        })
+        .on("mouseout", function() {
+            tooltip.style("opacity", 0);
+        });
+
+        // Node click handler - selects node and emits custom event
+        selection.on("click", function(event, d) {
+            event.stopPropagation();
+
+            // Toggle selection
+            if (selectedNodeId === d.id) {
+                selectedNodeId = null;
+            } else {
+                selectedNodeId = d.id;
+            }
+            updateFilter();
+
+            // Emit custom event
+            const customEvent = new CustomEvent("nodeClick", {
+                detail: {
+                    id: d.id,
+                    label: d.label,
+                    color: d.color,
+                    shape: d.shape,
+                    group: d.group,
+                    attributes: d.attributes || {},
+                    position: { x: d.x, y: d.y },
+                    selected: selectedNodeId === d.id
+                },
+                bubbles: true
+            });
+            document.dispatchEvent(customEvent);
+
+            console.log("Node clicked:", d);
+        });
+
+        return selection;
+    }
+
+    let node = setupNodes(nodeGroup.selectAll("g")
+        .data(graphData.nodes)
+        .join("g"));
+
+    // Degree badges (RenderOptions.ShowDegreeBadges): the in-degree at a
+    // node's top left corner and its out-degree at the top right, or a
+    // single degree badge in undirected graphs
+    const showDegreeBadges = {{.DegreeBadges}};
+
+    function renderDegreeBadges() {
+        if (!showDegreeBadges) return;
+        node.selectAll(".degree-badge").remove();
+        node.each(function(d) {
+            const box = this.getBBox();
+            const badges = graphData.directed
+                ? [
+                    { kind: "in", count: backwardAdjacency.get(d.id).size, x: box.x },
+                    { kind: "out", count: forwardAdjacency.get(d.id).size, x: box.x + box.width },
+                ]
+                : [{ kind: "degree", count: adjacency.get(d.id).size, x: box.x + box.width }];
+            badges.forEach(b => {
+                const badge = d3.select(this).append("g")
+                    .attr("class", "degree-badge " + b.kind)
+                    .attr("transform", "translate(" + b.x + "," + box.y + ")");
+                badge.append("title").text(b.kind === "degree" ? "degree" : b.kind + "-degree");
+                badge.append("circle").attr("r", 7);
+                badge.append("text").text(b.count);
+            });
+        });
+    }
+    renderDegreeBadges();
+
+    // Entry animation (RenderOptions.AnimateEntry): nodes fade in while
+    // their shapes and labels scale up from the center, then edges fade in.
+    // Inline styles are removed at the end so filtering classes apply.
+    {{if .AnimateEntry}}{
+        const entryDuration = 600;
+        nodeGroup.style("opacity", 0)
+            .transition().duration(entryDuration)
+            .style("opacity", 1)
+            .on("end", () => nodeGroup.style("opacity", null));
+        node.selectAll(":scope > *")
+            .style("scale", "0")
+            .transition().duration(entryDuration).ease(d3.easeBackOut)
+            .style("scale", "1")
+            .on("end", function() { d3.select(this).style("scale", null); });
+        [linkGroup, unifiedLinkGroup, curvedEdgeGroup, linkLabelGroup, multiEdgeLabelGroup].forEach(group => group
+            .style("opacity", 0)
+            .transition().delay(entryDuration / 2).duration(entryDuration)
+            .style("opacity", 1)
+            .on("end", () => group.style("opacity", null)));
+    }{{end}}
 
     // Click on background to deselect node and clear edge highlight
     svg.on("click", function(event) {
@@ -2385,14 +4277,117 @@ const htmlTemplate = `<!DOCTYPE html>
         return ` + "`" + `M${startX},${startY} Q${ctrlX},${ctrlY} ${endX},${endY}` + "`" + `;
     }
 
+    // Path for a single edge between two points according to edgeStyle
+    function singleEdgePath(s, t) {
+        if (edgeStyle === "curved") {
+            const c = singleEdgeControlPoint(s, t);
+            return ` + "`" + `M${s.x},${s.y} Q${c.x},${c.y} ${t.x},${t.y}` + "`" + `;
+        }
+        if (edgeStyle === "ortho") {
+            return ` + "`" + `M${s.x},${s.y} L${t.x},${s.y} L${t.x},${t.y}` + "`" + `;
+        }
+        return ` + "`" + `M${s.x},${s.y} L${t.x},${t.y}` + "`" + `;
+    }
+
+    // Point where the segment from a to b crosses the polygon's boundary:
+    // the crossing nearest a, or with last the one nearest b. Null if the
+    // segment doesn't cross it.
+    function hullCrossing(a, b, polygon, last) {
+        const rx = b.x - a.x, ry = b.y - a.y;
+        let best = null;
+        for (let i = 0; i < polygon.length; i++) {
+            const [x1, y1] = polygon[i];
+            const [x2, y2] = polygon[(i + 1) % polygon.length];
+            const sx = x2 - x1, sy = y2 - y1;
+            const denom = rx * sy - ry * sx;
+            if (denom === 0) continue;
+            const u = ((x1 - a.x) * sy - (y1 - a.y) * sx) / denom; // along a-b
+            const v = ((x1 - a.x) * ry - (y1 - a.y) * rx) / denom; // along the side
+            if (u < 0 || u > 1 || v < 0 || v > 1) continue;
+            if (best === null || (last ? u > best : u < best)) best = u;
+        }
+        return best === null ? null : { x: a.x + rx * best, y: a.y + ry * best };
+    }
+
+    // Ends of a single edge. With compound=true, an edge with lhead stops
+    // where it enters that cluster's hull, and one with ltail starts where
+    // it leaves its hull, unless the other end is inside the same hull.
+    // d.clippedHead tells the marker to put the arrow's tip at the end.
+    function edgeEnds(d) {
+        let s = d.source, t = d.target;
+        d.clippedHead = false;
+        if (!graphData.compound) return { s, t };
+        const head = d.lhead && clusterPolygons.get(d.lhead);
+        const tail = d.ltail && clusterPolygons.get(d.ltail);
+        if (head && !d3.polygonContains(head, [d.source.x, d.source.y])) {
+            const crossing = hullCrossing(d.source, d.target, head, false);
+            if (crossing) {
+                t = crossing;
+                d.clippedHead = true;
+            }
+        }
+        if (tail && !d3.polygonContains(tail, [d.target.x, d.target.y])) {
+            s = hullCrossing(d.source, d.target, tail, true) || s;
+        }
+        return { s, t };
+    }
+
+    // Base width of a style=tapered edge at its source
+    const taperedEdgeWidth = 8;
+
+    // Outline of a tapered edge: a wedge wide at the source narrowing to a
+    // point at the target. Tapered edges are only drawn straight; with
+    // other edge styles, or between coincident nodes, they stay lines.
+    function taperedEdgePath(s, t) {
+        const dx = t.x - s.x;
+        const dy = t.y - s.y;
+        const length = Math.sqrt(dx * dx + dy * dy);
+        if (edgeStyle !== "straight" || length === 0) return singleEdgePath(s, t);
+        const nx = -dy / length * taperedEdgeWidth / 2;
+        const ny = dx / length * taperedEdgeWidth / 2;
+        return ` + "`" + `M${s.x + nx},${s.y + ny} L${t.x},${t.y} L${s.x - nx},${s.y - ny} Z` + "`" + `;
+    }
+
+    // Control point for curved single edges, offset perpendicular to the chord
+    function singleEdgeControlPoint(s, t) {
+        const dx = t.x - s.x;
+        const dy = t.y - s.y;
+        return { x: (s.x + t.x) / 2 - dy * 0.2, y: (s.y + t.y) / 2 + dx * 0.2 };
+    }
+
+    // Left-to-right flows (rankdir=LR or RL) read better with edge labels
+    // kept horizontal and raised above the edge than centered on it
+    const rankdir = ((graphData.attributes || {}).rankdir || "").toUpperCase();
+    const horizontalFlow = rankdir === "LR" || rankdir === "RL";
+    const flowLabelOffset = horizontalFlow ? -10 : 0;
+
+    // Label anchor for a single edge, placed on the drawn path
+    function singleEdgeLabelPos(s, t) {
+        if (edgeStyle === "curved") {
+            const c = singleEdgeControlPoint(s, t);
+            return { x: (s.x + 2 * c.x + t.x) / 4, y: (s.y + 2 * c.y + t.y) / 4 };
+        }
+        if (edgeStyle === "ortho") {
+            return { x: t.x, y: s.y };
+        }
+        return { x: (s.x + t.x) / 2, y: (s.y + t.y) / 2 };
+    }
+
     // Function to update all edge positions
     function updateEdgePositions() {
+        // Rebuild the shared fast-edge path in one attribute write
+        if (fastEdgePath) {
+            const segments = graphData.links.map(l =>
+                "M" + l.source.x + "," + l.source.y + "L" + l.target.x + "," + l.target.y);
+            fastEdgePath.attr("d", segments.join(""));
+        }
+
         // Update single-edge links
-        link
-            .attr("x1", d => d.source.x)
-            .attr("y1", d => d.source.y)
-            .attr("x2", d => d.target.x)
-            .attr("y2", d => d.target.y);
+        link.attr("d", d => {
+            const { s, t } = edgeEnds(d);
+            return d.tapered ? taperedEdgePath(s, t) : singleEdgePath(s, t);
+        });
+        if (graphData.compound) link.classed("clipped-head", d => d.clippedHead);
 
         // Update unified links for multi-edge groups
         unifiedLinks.each(function(group) {
@@ -2412,11 +4407,11 @@ const htmlTemplate = `<!DOCTYPE html>
             path.attr("d", computeCurvedPath(sourcePos, targetPos, curveDirection, curveOffset));
         });
 
-        // Position single-edge labels at midpoint
+        // Position single-edge labels along their path
         linkLabel.attr("transform", d => {
-            const midX = (d.source.x + d.target.x) / 2;
-            const midY = (d.source.y + d.target.y) / 2;
-            return ` + "`" + `translate(${midX},${midY})` + "`" + `;
+            const { s, t } = edgeEnds(d);
+            const pos = singleEdgeLabelPos(s, t);
+            return ` + "`" + `translate(${pos.x},${pos.y + flowLabelOffset})` + "`" + `;
         });
 
         // Position multi-edge label groups (stacked vertically at midpoint)
@@ -2429,7 +4424,10 @@ const htmlTemplate = `<!DOCTYPE html>
             // Count labels with content
             const labelCount = labels.size();
             const lineHeight = 14;
-            const startY = -(labelCount - 1) * lineHeight / 2;
+            // Stacks sit above the edge in left-to-right flows
+            const startY = horizontalFlow
+                ? flowLabelOffset - (labelCount - 1) * lineHeight
+                : -(labelCount - 1) * lineHeight / 2;
 
             container.attr("transform", ` + "`" + `translate(${midX},${midY})` + "`" + `);
             labels.attr("y", (d, i) => startY + i * lineHeight);
@@ -2447,6 +4445,154 @@ const htmlTemplate = `<!DOCTYPE html>
         node.attr("transform", d => ` + "`" + `translate(${d.x},${d.y})` + "`" + `);
     });
 
+    // Overlap removal (RenderOptions.RemoveOverlap): once the layout has
+    // settled, nodes whose bounding boxes overlap are pushed apart along
+    // the axis that separates them soonest, a few passes until none do.
+    // Each pass finds a node's neighbors in a quadtree, so large graphs
+    // don't compare every pair.
+    const removeOverlap = {{.RemoveOverlap}};
+    const overlapMargin = parseFloat((graphData.attributes || {}).esep) || 4;
+
+    function removeOverlaps() {
+        const boxes = [];
+        node.each(function(d) {
+            const b = this.getBBox();
+            boxes.push({ i: boxes.length, d, dx: b.x + b.width / 2, dy: b.y + b.height / 2, hw: b.width / 2 + overlapMargin / 2, hh: b.height / 2 + overlapMargin / 2 });
+        });
+        const maxHw = d3.max(boxes, b => b.hw) || 0;
+        const maxHh = d3.max(boxes, b => b.hh) || 0;
+        const cx = b => b.d.x + b.dx;
+        const cy = b => b.d.y + b.dy;
+
+        for (let pass = 0; pass < 50; pass++) {
+            let moved = false;
+            const tree = d3.quadtree(boxes, cx, cy);
+            boxes.forEach(a => {
+                const x = cx(a), y = cy(a);
+                const rx = a.hw + maxHw, ry = a.hh + maxHh;
+                tree.visit((quad, x0, y0, x1, y1) => {
+                    if (!quad.length) {
+                        for (let q = quad; q; q = q.next) {
+                            if (q.data.i > a.i && separate(a, q.data)) moved = true;
+                        }
+                    }
+                    return x0 > x + rx || x1 < x - rx || y0 > y + ry || y1 < y - ry;
+                });
+            });
+            if (!moved) break;
+        }
+
+        updateHulls();
+        updateEdgePositions();
+        node.attr("transform", d => ` + "`" + `translate(${d.x},${d.y})` + "`" + `);
+    }
+
+    // separate pushes two overlapping boxes apart, reporting whether they
+    // overlapped
+    function separate(a, b) {
+        const ox = a.hw + b.hw - Math.abs((b.d.x + b.dx) - (a.d.x + a.dx));
+        const oy = a.hh + b.hh - Math.abs((b.d.y + b.dy) - (a.d.y + a.dy));
+        if (ox <= 0 || oy <= 0) return false;
+        if (ox < oy) {
+            const shift = (b.d.x + b.dx >= a.d.x + a.dx ? ox : -ox) / 2;
+            nudge(a.d, -shift, 0);
+            nudge(b.d, shift, 0);
+        } else {
+            const shift = (b.d.y + b.dy >= a.d.y + a.dy ? oy : -oy) / 2;
+            nudge(a.d, 0, -shift);
+            nudge(b.d, 0, shift);
+        }
+        return true;
+    }
+
+    // nudge moves a node, keeping pinned nodes pinned where they land
+    function nudge(d, dx, dy) {
+        d.x += dx;
+        d.y += dy;
+        if (d.fx != null) d.fx = d.x;
+        if (d.fy != null) d.fy = d.y;
+    }
+
+    // Only the first settle: dragging or reheating shouldn't rerun it
+    if (removeOverlap && !gridLayout && !circularLayout) {
+        simulation.on("end.overlap", () => {
+            simulation.on("end.overlap", null);
+            removeOverlaps();
+        });
+    }
+
+    // placeOnGrid lays nodes out in rows, grouped by cluster, and zooms out
+    // so the whole grid fits.
+    function placeOnGrid() {
+        const spacing = 80;
+        const cols = Math.ceil(Math.sqrt(graphData.nodes.length));
+        graphData.nodes.slice()
+            .sort((a, b) => (a.group || "").localeCompare(b.group || "") || a.id.localeCompare(b.id))
+            .forEach((n, i) => {
+                n.x = (i % cols) * spacing + spacing / 2;
+                n.y = Math.floor(i / cols) * spacing + spacing / 2;
+            });
+        const fit = Math.min(width, height) / (cols * spacing);
+        svg.call(zoom.transform, d3.zoomIdentity.scale(Math.max(0.1, Math.min(1, fit))));
+    }
+
+    // placeOnCircle spaces nodes evenly around a circle, sorted by cluster
+    // so each cluster takes an arc, with an empty slot between clusters.
+    // The radius grows with the node count to keep neighbors apart, and the
+    // view zooms out around the center to fit it.
+    function placeOnCircle() {
+        const spacing = 60;
+        const nodes = graphData.nodes.slice()
+            .sort((a, b) => (a.group || "").localeCompare(b.group || "") || a.id.localeCompare(b.id));
+        const slots = [];
+        nodes.forEach((n, i) => {
+            if (i > 0 && (n.group || "") !== (nodes[i - 1].group || "")) slots.push(null);
+            slots.push(n);
+        });
+        if (slots.length > 1 && (nodes[0].group || "") !== (nodes[nodes.length - 1].group || "")) slots.push(null);
+
+        const radius = Math.max(100, slots.length * spacing / (2 * Math.PI));
+        const cx = width / 2;
+        const cy = height / 2;
+        slots.forEach((n, i) => {
+            if (!n) return;
+            const angle = 2 * Math.PI * i / slots.length - Math.PI / 2;
+            n.x = cx + radius * Math.cos(angle);
+            n.y = cy + radius * Math.sin(angle);
+        });
+
+        const fit = Math.max(0.1, Math.min(1, Math.min(width, height) / (2 * radius + 2 * spacing)));
+        svg.call(zoom.transform, d3.zoomIdentity.translate(cx, cy).scale(fit).translate(-cx, -cy));
+    }
+
+    // Static mode: run the simulation to completion before the first frame,
+    // then pin every node and draw once. positionsLocked keeps selection
+    // and filtering from restarting it. Graphs past SimulationNodeLimit are
+    // pinned the same way, but on a grid grouped by cluster instead of
+    // simulated, and zoomed out to fit; circular layouts likewise, around a
+    // circle.
+    if (staticLayout || gridLayout || circularLayout) {
+        simulation.stop();
+        const ticks = gridLayout || circularLayout ? 0 : Math.ceil(Math.log(simulation.alphaMin()) / Math.log(1 - simulation.alphaDecay()));
+        for (let i = 0; i < ticks; i++) {
+            simulation.tick();
+        }
+        if (gridLayout) placeOnGrid();
+        if (circularLayout) placeOnCircle();
+        // Ticking by hand never fires "end"
+        if (removeOverlap && !gridLayout && !circularLayout) removeOverlaps();
+        positionsLocked = true;
+        graphData.nodes.forEach(n => {
+            n.fx = n.x;
+            n.fy = n.y;
+        });
+        document.getElementById("lock-positions").closest(".control-group").style.display = "none";
+
+        updateHulls();
+        updateEdgePositions();
+        node.attr("transform", d => ` + "`" + `translate(${d.x},${d.y})` + "`" + `);
+    }
+
     // Listen for events (example usage)
     document.addEventListener("nodeClick", function(e) {
         console.log("nodeClick event:", e.detail);
@@ -2464,6 +4610,209 @@ const htmlTemplate = `<!DOCTYPE html>
         console.log("filterChange event:", e.detail);
     });
 
+    {{if .Inspector}}
+    // Inspector panel: lists the clicked node's or edge's attributes and
+    // stays open until another element is clicked or it is closed
+    const inspector = d3.select("#inspector");
+
+    function showInspector(title, fields) {
+        const rows = Object.entries(fields)
+            .filter(([, v]) => v !== undefined && v !== null && v !== "");
+        inspector.select("#inspector-title").text(title);
+        inspector.select("#inspector-body").selectAll(".inspector-row")
+            .data(rows)
+            .join("div")
+            .attr("class", "inspector-row")
+            .each(function([k, v]) {
+                const row = d3.select(this);
+                row.selectAll("*").remove();
+                row.append("span").attr("class", "inspector-key").text(k);
+                row.append("span").attr("class", "inspector-value").text(v);
+            });
+        inspector.style("display", "block");
+    }
+
+    document.addEventListener("nodeClick", function(e) {
+        const d = e.detail;
+        showInspector(d.label || d.id, Object.assign(
+            { id: d.id, shape: d.shape, color: d.color, group: d.group },
+            d.attributes));
+    });
+
+    document.addEventListener("edgeClick", function(e) {
+        const d = e.detail;
+        const op = graphData.directed ? " -> " : " -- ";
+        showInspector(d.label || d.source + op + d.target, Object.assign(
+            { source: d.source, target: d.target, color: d.color },
+            d.attributes));
+    });
+
+    document.getElementById("inspector-close").addEventListener("click", function() {
+        inspector.style("display", "none");
+    });
+    {{end}}
+    {{if .Export}}
+    // Export: serializes the nodes and edges left visible by the degree
+    // filter back to DOT text
+    const dotKeywords = new Set(["node", "edge", "graph", "digraph", "subgraph", "strict"]);
+
+    // dotID quotes an ID unless it is a plain identifier or numeral,
+    // escaping what would otherwise end the string or the line
+    function dotID(id) {
+        const s = String(id);
+        const plain = /^[A-Za-z_\u0080-\uffff][A-Za-z0-9_\u0080-\uffff]*$/.test(s) && !dotKeywords.has(s.toLowerCase());
+        const numeral = /^-?(\.[0-9]+|[0-9]+(\.[0-9]*)?)$/.test(s);
+        if (plain || numeral) return s;
+        return '"' + s.replace(/[\\"]/g, "\\$&").replace(/\n/g, "\\n").replace(/\r/g, "\\r") + '"';
+    }
+
+    function dotAttrs(attrs) {
+        const list = Object.entries(attrs)
+            .filter(([, v]) => v !== undefined && v !== null && v !== "")
+            .map(([k, v]) => dotID(k) + "=" + dotID(v));
+        return list.length ? " [" + list.join(", ") + "]" : "";
+    }
+
+    // nodePos formats a node's current simulation coordinates as a pinned
+    // Graphviz pos value, or returns undefined before it has been placed.
+    // A page pixel is drawn as a point, but Graphviz's y axis points up, so
+    // y is flipped; the trailing ! keeps neato and fdp from moving the node.
+    function nodePos(n) {
+        if (typeof n.x !== "number" || typeof n.y !== "number") return undefined;
+        const round = v => Math.round(v * 100) / 100;
+        return round(n.x) + "," + round(-n.y) + "!";
+    }
+
+    // visibleDOT serializes the visible graph; withPositions adds each
+    // node's current position as a pos attribute, so the arranged layout
+    // can be saved with the graph
+    function visibleDOT(withPositions) {
+        const visibleNodes = getNodesWithinDegree(selectedNodeId, degreeFilter);
+        const isVisible = id => !visibleNodes || visibleNodes.has(id);
+        const op = graphData.directed ? " -> " : " -- ";
+
+        const lines = [(graphData.strict ? "strict " : "") +
+            (graphData.directed ? "digraph" : "graph") +
+            (graphData.graphId ? " " + dotID(graphData.graphId) : "") + " {"];
+        graphData.nodes.filter(n => isVisible(n.id)).forEach(n => {
+            lines.push("    " + dotID(n.id) + dotAttrs(Object.assign({}, n.attributes, {
+                label: n.label !== n.id ? n.label : undefined,
+                color: n.color,
+                fillcolor: n.fillColor,
+                shape: n.shape,
+                style: n.style,
+                class: n.class,
+                pos: withPositions ? nodePos(n) : undefined
+            })));
+        });
+        graphData.links.forEach(l => {
+            const sourceId = typeof l.source === 'object' ? l.source.id : l.source;
+            const targetId = typeof l.target === 'object' ? l.target.id : l.target;
+            if (!isVisible(sourceId) || !isVisible(targetId)) return;
+            // A collapsed link is written once per edge it merged
+            const line = "    " + dotID(sourceId) + op + dotID(targetId) + dotAttrs(Object.assign({}, l.attributes, {
+                label: l.count > 1 ? l.dotLabel : l.label,
+                color: l.color,
+                style: l.style,
+                class: l.class
+            }));
+            for (let i = 0; i < (l.count || 1); i++) lines.push(line);
+        });
+        lines.push("}");
+        return lines.join("\n") + "\n";
+    }
+
+    function exportDOT() {
+        return visibleDOT(false);
+    }
+
+    function exportPositionedDOT() {
+        return visibleDOT(true);
+    }
+
+    function downloadBlob(blob, filename) {
+        const url = URL.createObjectURL(blob);
+        const a = document.createElement("a");
+        a.href = url;
+        a.download = filename;
+        a.click();
+        URL.revokeObjectURL(url);
+    }
+
+    function downloadDOT(text) {
+        if (navigator.clipboard) navigator.clipboard.writeText(text).catch(() => {});
+        downloadBlob(new Blob([text], { type: "text/vnd.graphviz" }), (graphData.graphId || "graph") + ".dot");
+    }
+
+    document.getElementById("export-dot").addEventListener("click", function() {
+        downloadDOT(exportDOT());
+    });
+
+    document.getElementById("export-positions").addEventListener("click", function() {
+        downloadDOT(exportPositionedDOT());
+    });
+
+    const imageType = {{.ImageType}};
+    const imageQuality = {{.ImageQuality}} || undefined; // browser default
+    const imageName = (graphData.graphId || "graph") + "." + {{.ImageExt}};
+
+    // graphImage draws the SVG as it is now, with the page's styles inlined,
+    // onto a canvas and resolves to a blob of imageType
+    function graphImage() {
+        const node = svg.node();
+        const rect = node.getBoundingClientRect();
+        const clone = node.cloneNode(true);
+        clone.setAttribute("xmlns", "http://www.w3.org/2000/svg");
+        clone.setAttribute("width", rect.width);
+        clone.setAttribute("height", rect.height);
+        const style = document.createElementNS("http://www.w3.org/2000/svg", "style");
+        style.textContent = document.querySelector("style").textContent;
+        clone.insertBefore(style, clone.firstChild);
+
+        const url = URL.createObjectURL(new Blob([new XMLSerializer().serializeToString(clone)], { type: "image/svg+xml" }));
+        return new Promise((resolve, reject) => {
+            const img = new Image();
+            img.onload = () => {
+                URL.revokeObjectURL(url);
+                const scale = window.devicePixelRatio || 1;
+                const canvas = document.createElement("canvas");
+                canvas.width = rect.width * scale;
+                canvas.height = rect.height * scale;
+                const ctx = canvas.getContext("2d");
+                ctx.scale(scale, scale);
+                // JPEG has no alpha channel, so it always gets a background
+                if (!transparent || imageType === "image/jpeg") {
+                    ctx.fillStyle = normalizeColor(graphData.bgcolor) || "white";
+                    ctx.fillRect(0, 0, rect.width, rect.height);
+                }
+                ctx.drawImage(img, 0, 0, rect.width, rect.height);
+                canvas.toBlob(blob => blob ? resolve(blob) : reject(new Error(imageType + " encoding failed")), imageType, imageQuality);
+            };
+            img.onerror = () => {
+                URL.revokeObjectURL(url);
+                reject(new Error("SVG could not be drawn"));
+            };
+            img.src = url;
+        });
+    }
+
+    // copyImage writes a PNG to the clipboard; it rejects when the Clipboard
+    // API can't take images or permission is denied
+    function copyImage(blob) {
+        if (!navigator.clipboard || !navigator.clipboard.write || typeof ClipboardItem === "undefined") {
+            return Promise.reject(new Error("clipboard images unsupported"));
+        }
+        return navigator.clipboard.write([new ClipboardItem({ "image/png": blob })]);
+    }
+
+    document.getElementById("copy-image").addEventListener("click", function() {
+        graphImage()
+            .then(blob => (imageType === "image/png" ? copyImage(blob) : Promise.reject(new Error("clipboard takes only PNG")))
+                // Fall back to a download
+                .catch(() => downloadBlob(blob, imageName)))
+            .catch(err => console.error("Could not export image:", err));
+    });
+    {{end}}
     // Reset zoom on double-click
     svg.on("dblclick.zoom", null);
     svg.on("dblclick", function() {
@@ -2472,6 +4821,113 @@ const htmlTemplate = `<!DOCTYPE html>
             d3.zoomIdentity.translate(0, 0).scale(1)
         );
     });
+    {{if .Expandable}}
+    // Expandable mode: double-clicking a node emits "nodeExpand" so the host
+    // page can fetch more of the graph and merge it in with
+    // window.dot2d3.addNodes / window.dot2d3.addLinks.
+    let lastExpandedId = null;
+
+    function expandNode(event, d) {
+        event.stopPropagation();
+        lastExpandedId = d.id;
+        const customEvent = new CustomEvent("nodeExpand", {
+            detail: { id: d.id },
+            bubbles: true
+        });
+        document.dispatchEvent(customEvent);
+    }
+
+    node.on("dblclick", expandNode);
+
+    // Merge new nodes into the running simulation without a reset
+    function addNodes(newNodes) {
+        const anchor = nodeById.get(lastExpandedId) || { x: width / 2, y: height / 2 };
+        const added = [];
+        newNodes.forEach(n => {
+            if (nodeById.has(n.id)) return;
+            // Start new nodes around the expanded node
+            n.x = anchor.x + (Math.random() - 0.5) * 60;
+            n.y = anchor.y + (Math.random() - 0.5) * 60;
+            graphData.nodes.push(n);
+            nodeById.set(n.id, n);
+            nodeByIdForHull.set(n.id, n);
+            adjacency.set(n.id, new Set());
+            forwardAdjacency.set(n.id, new Set());
+            backwardAdjacency.set(n.id, new Set());
+            nodeNeighbors.set(n.id, new Set());
+            nodeDegrees.set(n.id, 0);
+            added.push(n);
+        });
+        if (added.length === 0) return;
+
+        const entered = setupNodes(nodeGroup.selectAll(null)
+            .data(added)
+            .enter()
+            .append("g"));
+        entered.on("dblclick", expandNode);
+        node = node.merge(entered);
+        renderDegreeBadges();
+
+        simulation.nodes(graphData.nodes);
+        if (!positionsLocked) simulation.alpha(0.3).restart();
+    }
+
+    // Merge new links between known nodes; duplicates are ignored
+    function addLinks(newLinks) {
+        const existing = new Set(graphData.links.map(l => {
+            const sourceId = typeof l.source === 'object' ? l.source.id : l.source;
+            const targetId = typeof l.target === 'object' ? l.target.id : l.target;
+            return sourceId + '->' + targetId;
+        }));
+        const added = [];
+        newLinks.forEach(l => {
+            const sourceId = typeof l.source === 'object' ? l.source.id : l.source;
+            const targetId = typeof l.target === 'object' ? l.target.id : l.target;
+            if (!nodeById.has(sourceId) || !nodeById.has(targetId)) return;
+            if (existing.has(sourceId + '->' + targetId)) return;
+            existing.add(sourceId + '->' + targetId);
+
+            l._index = graphData.links.length;
+            graphData.links.push(l);
+            singleEdgeLinks.push(l);
+            adjacency.get(sourceId).add(targetId);
+            adjacency.get(targetId).add(sourceId);
+            addDirectedAdjacency(l, sourceId, targetId);
+            nodeNeighbors.get(sourceId).add(targetId);
+            nodeNeighbors.get(targetId).add(sourceId);
+            nodeDegrees.set(sourceId, (nodeDegrees.get(sourceId) || 0) + 1);
+            nodeDegrees.set(targetId, (nodeDegrees.get(targetId) || 0) + 1);
+            added.push(l);
+        });
+        if (added.length === 0) return;
+        renderTopNodes();
+        renderDegreeBadges();
+
+        link = link.merge(setupLinks(linkGroup.selectAll(null)
+            .data(added)
+            .enter()
+            .append("path")));
+        linkLabel = linkLabel.merge(setupLinkLabels(linkLabelGroup.selectAll(null)
+            .data(added.filter(l => l.label))
+            .enter()
+            .append("text")));
+
+        // Resolves the new links' source/target ids to node objects
+        simulation.force("link").links(graphData.links);
+        if (!positionsLocked) simulation.alpha(0.3).restart();
+    }
+
+    window.dot2d3 = { addNodes, addLinks };
+    {{end}}
+    {{if .ExtraJS}}
+    // RenderOptions.ExtraJS
+    {
+{{.ExtraJS}}
+    }
+    {{end}}
     </script>
+    {{if .EmbeddedJSON}}<script type="application/json" id="graph-data">
+{{.EmbeddedJSON}}
+    </script>{{end}}
 </body>
 </html>`
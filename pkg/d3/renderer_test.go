@@ -1,7 +1,15 @@
 package d3
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/anthonybishopric/dot2d3/pkg/ast"
@@ -20,6 +28,39 @@ func parse(t *testing.T, input string) *ast.Graph {
 	return g
 }
 
+// templateData renders g with a template that writes out only the data the
+// page template is given, and decodes it, so tests can check the values
+// the page is built from rather than the text around them.
+func templateData(t *testing.T, g *Graph, opts RenderOptions) map[string]any {
+	t.Helper()
+	opts.Template = "<script>{{json .}}</script>"
+	html, err := RenderHTML(g, opts)
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	html = bytes.TrimSuffix(bytes.TrimPrefix(html, []byte("<script>")), []byte("</script>"))
+	var data map[string]any
+	if err := json.Unmarshal(html, &data); err != nil {
+		t.Fatalf("template data: %v\n%s", err, html)
+	}
+	return data
+}
+
+// renderedGraph decodes the graph data embedded in a rendered page.
+func renderedGraph(t *testing.T, html []byte) *Graph {
+	t.Helper()
+	const prefix = "const graphData = "
+	i := bytes.Index(html, []byte(prefix))
+	if i < 0 {
+		t.Fatal("expected graph data in the page")
+	}
+	var g Graph
+	if err := json.NewDecoder(bytes.NewReader(html[i+len(prefix):])).Decode(&g); err != nil {
+		t.Fatalf("graph data: %v", err)
+	}
+	return &g
+}
+
 func TestConvertSimpleDigraph(t *testing.T) {
 	g := parse(t, `digraph G { A -> B -> C }`)
 
@@ -110,6 +151,192 @@ func TestConvertEdgeAttributes(t *testing.T) {
 	}
 }
 
+func TestConvertEdgeOperator(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		operator string // of the second edge
+		directed []bool
+	}{
+		{"matching digraph", `digraph { A -> B -> C }`, "", []bool{true, true}},
+		{"matching graph", `graph { A -- B -- C }`, "", []bool{false, false}},
+		{"arrow in graph", `graph { A -- B -> C }`, "->", []bool{false, true}},
+		{"dashes in digraph", `digraph { A -> B -- C }`, "--", []bool{true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, tt.input))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			if len(d3g.Links) != 2 {
+				t.Fatalf("expected 2 links, got %d", len(d3g.Links))
+			}
+			if d3g.Links[0].Operator != "" || d3g.Links[1].Operator != tt.operator {
+				t.Errorf("expected operators %q and %q, got %q and %q", "", tt.operator, d3g.Links[0].Operator, d3g.Links[1].Operator)
+			}
+			for i, want := range tt.directed {
+				if got := d3g.LinkDirected(d3g.Links[i]); got != want {
+					t.Errorf("link %d: expected directed=%v, got %v", i, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertRoundedNode(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		A [shape=box]
+		B [shape=box, style="rounded,filled"]
+		C [shape=box, style=roundedish]
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	rounded := make(map[string]bool)
+	for _, n := range d3g.Nodes {
+		rounded[n.ID] = n.Rounded
+	}
+	if rounded["A"] || !rounded["B"] || rounded["C"] {
+		t.Errorf("expected only B to be rounded, got %v", rounded)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `.attr("rx", d.rounded ? 4 : 0)`) {
+		t.Error("expected boxes to have square corners unless rounded")
+	}
+}
+
+func TestConvertTaperedEdge(t *testing.T) {
+	tests := []struct {
+		style   string
+		tapered bool
+	}{
+		{"tapered", true},
+		{"tapered, setlinewidth(2)", true},
+		{"bold,tapered", true},
+		{"dashed", false},
+		{"taperedish", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.style, func(t *testing.T) {
+			d3g, err := Convert(parse(t, `digraph { A -> B [style="`+tt.style+`"] }`))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+			if len(d3g.Links) != 1 {
+				t.Fatalf("expected 1 link, got %d", len(d3g.Links))
+			}
+			if d3g.Links[0].Tapered != tt.tapered {
+				t.Errorf("expected tapered=%v, got %v", tt.tapered, d3g.Links[0].Tapered)
+			}
+			if d3g.Links[0].Style != tt.style {
+				t.Errorf("expected style %q to be kept, got %q", tt.style, d3g.Links[0].Style)
+			}
+		})
+	}
+}
+
+func TestConvertEdgePorts(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		A -> B [tailport=s, headport=n]
+		C:out:e -> D:w
+		E:p1 -> F:p2 [headport="in:s"]
+		edge [tailport=n, headport=s]
+		G:e -> H
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	type ports struct{ sourcePort, sourceCompass, targetPort, targetCompass string }
+	want := []ports{
+		{"", "s", "", "n"},
+		{"out", "e", "", "w"},
+		{"p1", "", "in", "s"}, // the attribute takes precedence
+		{"", "e", "", "s"},    // but not a default
+	}
+	for i, w := range want {
+		l := d3g.Links[i]
+		got := ports{l.SourcePort, l.SourceCompass, l.TargetPort, l.TargetCompass}
+		if got != w {
+			t.Errorf("link %s -> %s: expected %+v, got %+v", l.Source, l.Target, w, got)
+		}
+		if _, ok := l.Attributes["tailport"]; ok {
+			t.Errorf("link %s -> %s: expected tailport not to be kept in attributes", l.Source, l.Target)
+		}
+	}
+}
+
+func TestConvertEdgeOrder(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A -> C; B -> X; A -> B; A -> {D E} }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	want := []struct {
+		source, target string
+		order          int
+	}{
+		{"A", "C", 0}, {"B", "X", 0}, {"A", "B", 1}, {"A", "D", 2}, {"A", "E", 3},
+	}
+	if len(d3g.Links) != len(want) {
+		t.Fatalf("expected %d links, got %d", len(want), len(d3g.Links))
+	}
+	for i, w := range want {
+		l := d3g.Links[i]
+		if l.Source != w.source || l.Target != w.target || l.Order != w.order {
+			t.Errorf("link %d: expected %s -> %s with Order %d, got %s -> %s with Order %d",
+				i, w.source, w.target, w.order, l.Source, l.Target, l.Order)
+		}
+	}
+}
+
+func TestConvertLimits(t *testing.T) {
+	// 30 x 30 group edges from a few hundred bytes of input
+	var left, right []string
+	for i := 0; i < 30; i++ {
+		left = append(left, fmt.Sprintf("a%d", i))
+		right = append(right, fmt.Sprintf("b%d", i))
+	}
+	g := parse(t, "digraph { {"+strings.Join(left, " ")+"} -> {"+strings.Join(right, " ")+"} }")
+
+	if _, err := ConvertWithLimits(g, Limits{MaxEdges: 500}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected edge limit error, got %v", err)
+	}
+	if _, err := ConvertWithLimits(g, Limits{MaxNodes: 40}); !errors.Is(err, ErrLimitExceeded) {
+		t.Errorf("expected node limit error, got %v", err)
+	}
+
+	d3g, err := ConvertWithLimits(g, Limits{MaxNodes: 60, MaxEdges: 900})
+	if err != nil {
+		t.Fatalf("expected graph at the limits to convert, got %v", err)
+	}
+	if len(d3g.Nodes) != 60 || len(d3g.Links) != 900 {
+		t.Errorf("expected 60 nodes and 900 links, got %d and %d", len(d3g.Nodes), len(d3g.Links))
+	}
+}
+
+func TestConvertContext(t *testing.T) {
+	g := parse(t, `digraph { {a b c} -> {d e f} }`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ConvertContext(ctx, g, ConvertOptions{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected conversion to stop with context.Canceled, got %v", err)
+	}
+
+	d3g, err := ConvertContext(context.Background(), g, ConvertOptions{})
+	if err != nil || len(d3g.Links) != 9 {
+		t.Errorf("expected 9 links with a live context, got %v", err)
+	}
+}
+
 func TestConvertDefaultAttributes(t *testing.T) {
 	g := parse(t, `digraph { node [color=red] edge [color=blue] A -> B }`)
 
@@ -134,6 +361,78 @@ func TestConvertDefaultAttributes(t *testing.T) {
 	}
 }
 
+func TestConvertDefaultsApplyToLaterNodes(t *testing.T) {
+	g := parse(t, `digraph {
+		A -> B
+		node [color=red, shape=box]
+		A -> C
+		B [label="Bee"]
+		D
+		subgraph s1 { node [color=blue]; A; E }
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	for _, id := range []string{"A", "B"} {
+		if byID[id].Color != "" || byID[id].Shape != "" {
+			t.Errorf("expected %s, created before the default, to stay uncolored, got color %q shape %q", id, byID[id].Color, byID[id].Shape)
+		}
+	}
+	if byID["B"].Label != "Bee" {
+		t.Errorf("expected B's own attributes to apply, got label %q", byID["B"].Label)
+	}
+	for _, id := range []string{"C", "D"} {
+		if byID[id].Color != "red" || byID[id].Shape != "box" {
+			t.Errorf("expected %s to take the defaults, got color %q shape %q", id, byID[id].Color, byID[id].Shape)
+		}
+	}
+	if byID["E"].Color != "blue" || byID["E"].Shape != "box" {
+		t.Errorf("expected E to take the subgraph's defaults, got color %q shape %q", byID["E"].Color, byID["E"].Shape)
+	}
+}
+
+func TestConvertSubgraphDefaultScope(t *testing.T) {
+	g := parse(t, `digraph {
+		subgraph s1 { node [color=red]; edge [style=dashed]; A -> A2 }
+		subgraph s2 { B }
+		C -> D
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	colors := make(map[string]string)
+	for _, n := range d3g.Nodes {
+		colors[n.ID] = n.Color
+	}
+	if colors["A"] != "red" || colors["A2"] != "red" {
+		t.Errorf("expected nodes inside the subgraph to be red, got A=%q A2=%q", colors["A"], colors["A2"])
+	}
+	for _, id := range []string{"B", "C", "D"} {
+		if colors[id] != "" {
+			t.Errorf("expected default not to leak to %s, got color %q", id, colors[id])
+		}
+	}
+
+	for _, link := range d3g.Links {
+		if link.Source == "C" && link.Style != "" {
+			t.Errorf("expected edge default not to leak to C -> D, got style %q", link.Style)
+		}
+		if link.Source == "A" && link.Style != "dashed" {
+			t.Errorf("expected A -> A2 to be dashed, got %q", link.Style)
+		}
+	}
+}
+
 func TestConvertEdgeShorthand(t *testing.T) {
 	g := parse(t, `digraph { A -> {B C D} }`)
 
@@ -160,112 +459,2331 @@ func TestConvertEdgeShorthand(t *testing.T) {
 	}
 }
 
-func TestConvertStrict(t *testing.T) {
-	g := parse(t, `strict digraph { A -> B; A -> B }`)
+func TestConvertGroupToGroupEdges(t *testing.T) {
+	g := parse(t, `digraph { {A B} -> {C D} }`)
 
 	d3g, err := Convert(g)
 	if err != nil {
 		t.Fatalf("convert error: %v", err)
 	}
 
-	if !d3g.Strict {
-		t.Error("expected strict graph")
+	if len(d3g.Nodes) != 4 {
+		t.Errorf("expected 4 nodes, got %d", len(d3g.Nodes))
 	}
 
-	// Strict should prevent duplicate edges
-	if len(d3g.Links) != 1 {
-		t.Errorf("expected 1 link (strict mode), got %d", len(d3g.Links))
+	// Cartesian product: A->C, A->D, B->C, B->D
+	if len(d3g.Links) != 4 {
+		t.Fatalf("expected 4 links, got %d", len(d3g.Links))
+	}
+	seen := make(map[string]bool)
+	for _, link := range d3g.Links {
+		seen[link.Source+"->"+link.Target] = true
+	}
+	for _, want := range []string{"A->C", "A->D", "B->C", "B->D"} {
+		if !seen[want] {
+			t.Errorf("missing link %s", want)
+		}
 	}
 }
 
-func TestConvertUndirectedGraph(t *testing.T) {
-	g := parse(t, `graph { A -- B }`)
+func TestConvertLeftAnonymousSubgraph(t *testing.T) {
+	g := parse(t, `digraph { subgraph cluster_x { {A -> B} -> C } }`)
 
 	d3g, err := Convert(g)
 	if err != nil {
 		t.Fatalf("convert error: %v", err)
 	}
 
-	if d3g.Directed {
-		t.Error("expected undirected graph")
+	// A->B from the inner subgraph, then A->C and B->C; no duplicates
+	if len(d3g.Links) != 3 {
+		t.Errorf("expected 3 links, got %d: %v", len(d3g.Links), d3g.Links)
 	}
 }
 
-func TestRenderHTML(t *testing.T) {
-	d3g := &Graph{
-		Nodes: []Node{
-			{ID: "A", Label: "Node A"},
-			{ID: "B", Label: "Node B"},
-		},
-		Links: []Link{
-			{Source: "A", Target: "B"},
-		},
-		Directed: true,
+func TestConvertNodeImage(t *testing.T) {
+	g := parse(t, `digraph { node [image="default.png"] A [image="foo.png"]; B }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
 	}
 
-	opts := RenderOptions{
-		Title: "Test Graph",
+	images := make(map[string]string)
+	for _, n := range d3g.Nodes {
+		images[n.ID] = n.Image
+		if _, ok := n.Attributes["image"]; ok {
+			t.Errorf("expected image not to be duplicated in attributes for %s", n.ID)
+		}
+	}
+	if images["A"] != "foo.png" {
+		t.Errorf("expected A image 'foo.png', got %q", images["A"])
 	}
+	if images["B"] != "default.png" {
+		t.Errorf("expected B image 'default.png', got %q", images["B"])
+	}
+}
 
-	html, err := RenderHTML(d3g, opts)
+func TestConvertClass(t *testing.T) {
+	g := parse(t, `digraph { A [class="important"]; node [class=other]; A -> B [class="hot path"] }`)
+
+	d3g, err := Convert(g)
 	if err != nil {
-		t.Fatalf("render error: %v", err)
+		t.Fatalf("convert error: %v", err)
 	}
 
-	htmlStr := string(html)
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	if byID["A"].Class != "important" {
+		t.Errorf("expected A class 'important', got %q", byID["A"].Class)
+	}
+	if _, ok := byID["A"].Attributes["class"]; ok {
+		t.Error("expected class not to be duplicated in attributes")
+	}
+	if byID["B"].Class != "other" {
+		t.Errorf("expected B to take the default class, got %q", byID["B"].Class)
+	}
+	if len(d3g.Links) != 1 || d3g.Links[0].Class != "hot path" {
+		t.Errorf("expected edge class 'hot path', got %+v", d3g.Links)
+	}
+}
 
-	// Check for key elements
-	if !contains(htmlStr, "<title>Test Graph</title>") {
-		t.Error("expected title in HTML")
+func TestConvertLayers(t *testing.T) {
+	g := parse(t, `digraph {
+		layers="l1:l2:l3"
+		A [layer=l1]
+		B [layer="l2:all"]
+		C [layer="1,3"]
+		D [layer=nope]
+		A -> B [layer=l2]
+		B -> C
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
 	}
 
-	if !contains(htmlStr, "d3js.org") {
-		t.Error("expected D3.js reference in HTML")
+	if got := strings.Join(d3g.Layers, ","); got != "l1,l2,l3" {
+		t.Errorf("expected layers l1,l2,l3, got %q", got)
 	}
 
-	if !contains(htmlStr, "Node A") {
-		t.Error("expected node label in HTML")
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	expected := map[string]string{
+		"A": "l1",
+		"B": "l2,l3",
+		"C": "l1,l3",
+		"D": "",
+	}
+	for id, want := range expected {
+		if got := strings.Join(byID[id].Layers, ","); got != want {
+			t.Errorf("expected %s layers %q, got %q", id, want, got)
+		}
+	}
+	if byID["A"].Attributes["layer"] != "l1" {
+		t.Errorf("expected the layer attribute to stay in the tooltip, got %v", byID["A"].Attributes)
 	}
 
-	if !contains(htmlStr, "nodeClick") {
-		t.Error("expected nodeClick event handler in HTML")
+	for _, l := range d3g.Links {
+		want := ""
+		if l.Source == "A" {
+			want = "l2"
+		}
+		if got := strings.Join(l.Layers, ","); got != want {
+			t.Errorf("expected %s -> %s layers %q, got %q", l.Source, l.Target, want, got)
+		}
 	}
+}
 
-	if !contains(htmlStr, "d3.drag") {
-		t.Error("expected drag behavior in HTML")
+func TestConvertLayersWithoutLayersAttribute(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A [layer=l1] }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	if d3g.Layers != nil || d3g.Nodes[0].Layers != nil {
+		t.Errorf("expected no layers without a layers attribute, got %v and %v", d3g.Layers, d3g.Nodes[0].Layers)
+	}
+}
+
+func TestConvertPeripheries(t *testing.T) {
+	g := parse(t, `digraph { A [peripheries=2]; B; C [peripheries=many]; D [peripheries=100000000] }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
 	}
 
-	if !contains(htmlStr, "d3.zoom") {
-		t.Error("expected zoom behavior in HTML")
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	if byID["A"].Peripheries != 2 {
+		t.Errorf("expected A peripheries 2, got %d", byID["A"].Peripheries)
+	}
+	if byID["B"].Peripheries != 0 {
+		t.Errorf("expected B to keep the default, got %d", byID["B"].Peripheries)
+	}
+	if byID["C"].Attributes["peripheries"] != "many" {
+		t.Errorf("expected invalid peripheries to be kept as an attribute, got %v", byID["C"].Attributes)
+	}
+	if d := byID["D"]; d.Peripheries != 10 || d.Attributes["peripheries"] != "100000000" {
+		t.Errorf("expected D to be clamped to 10 peripheries, keeping the value as an attribute, got %d %v", d.Peripheries, d.Attributes)
 	}
 }
 
-func TestJSONOutput(t *testing.T) {
-	d3g := &Graph{
-		Nodes: []Node{
-			{ID: "A", Label: "Node A", Color: "red"},
-		},
-		Links:    []Link{},
-		Directed: true,
+func TestConvertPos(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A [pos="36,-18!"]; B [pos="1.5, 2"]; C [pos="1,2,3"]; D [pos="NaN,1"]; E }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
 	}
 
-	jsonBytes, err := json.Marshal(d3g)
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	if p := byID["A"].Pos; p == nil || *p != (Pos{X: 36, Y: 18, Pinned: true}) {
+		t.Errorf("expected A pinned at page position 36,18, got %+v", p)
+	}
+	if a := byID["A"]; a.Attr("pos") != "36,-18!" {
+		t.Errorf("expected A's pos attribute back in Graphviz coordinates, got %q", a.Attr("pos"))
+	}
+	if p := byID["B"].Pos; p == nil || *p != (Pos{X: 1.5, Y: -2}) {
+		t.Errorf("expected B to start at 1.5,-2 unpinned, got %+v", p)
+	}
+	for _, id := range []string{"C", "D"} {
+		if n := byID[id]; n.Pos != nil || n.Attributes["pos"] == "" {
+			t.Errorf("expected invalid pos on %s to be kept as an attribute, got %+v", id, n)
+		}
+	}
+	if byID["E"].Pos != nil {
+		t.Errorf("expected E without a position, got %+v", byID["E"].Pos)
+	}
+}
+
+func TestConvertCompoundEdges(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		compound=true
+		subgraph cluster_1 { B; C }
+		subgraph cluster_0 { A }
+		A -> B [lhead=cluster_1]
+		C -> A [ltail=cluster_1, lhead=cluster_0]
+		A -> C
+	}`))
 	if err != nil {
-		t.Fatalf("json error: %v", err)
+		t.Fatalf("convert error: %v", err)
+	}
+	if !d3g.Compound {
+		t.Error("expected compound=true to set Compound")
+	}
+	want := []struct{ lhead, ltail string }{{"cluster_1", ""}, {"cluster_0", "cluster_1"}, {"", ""}}
+	for i, l := range d3g.Links {
+		if l.LHead != want[i].lhead || l.LTail != want[i].ltail {
+			t.Errorf("link %d: expected lhead %q ltail %q, got %q %q", i, want[i].lhead, want[i].ltail, l.LHead, l.LTail)
+		}
+		if _, ok := l.Attributes["lhead"]; ok {
+			t.Errorf("link %d: expected lhead not to be kept as an attribute", i)
+		}
 	}
 
-	// Parse it back
-	var parsed Graph
-	if err := json.Unmarshal(jsonBytes, &parsed); err != nil {
-		t.Fatalf("unmarshal error: %v", err)
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	for _, want := range []string{
+		`"compound":true`,
+		`"lhead":"cluster_1"`,
+		`"ltail":"cluster_1"`,
+		`const head = d.lhead && clusterPolygons.get(d.lhead);`,
+		`const tail = d.ltail && clusterPolygons.get(d.ltail);`,
+		`.link.directed.clipped-head { marker-end: url(#arrowhead-clipped); }`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected %s", want)
+		}
 	}
 
-	if len(parsed.Nodes) != 1 {
-		t.Errorf("expected 1 node, got %d", len(parsed.Nodes))
+	d3g, err = Convert(parse(t, `digraph { subgraph cluster_1 { B } A -> B [lhead=cluster_1] }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
 	}
+	if d3g.Compound || d3g.Links[0].LHead != "cluster_1" {
+		t.Errorf("expected lhead to be kept without compound=true, got compound=%v lhead=%q", d3g.Compound, d3g.Links[0].LHead)
+	}
+}
 
-	if parsed.Nodes[0].Label != "Node A" {
-		t.Errorf("expected label 'Node A', got %s", parsed.Nodes[0].Label)
+func TestConvertPointAndNoOutline(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph {
+		start [shape=point]
+		end [shape=point, fillcolor=red, label="End"]
+		A [peripheries=0]
+		B [peripheries=0, shape=box]
+		B [peripheries=1]
+		C [shape=point, shape=box]
+	}`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+
+	for _, id := range []string{"start", "end"} {
+		n := byID[id]
+		if n.Shape != "point" || !n.NoLabel {
+			t.Errorf("expected %s to be a point without a label, got shape %q noLabel=%v", id, n.Shape, n.NoLabel)
+		}
+		if n.Width != 0 || n.Height != 0 || n.NoOutline {
+			t.Errorf("expected %s to keep the default point size and outline, got %+v", id, n)
+		}
+	}
+	if byID["end"].Label != "End" {
+		t.Errorf("expected the label to be kept for the tooltip, got %q", byID["end"].Label)
+	}
+	if byID["C"].NoLabel {
+		t.Error("expected a later shape to restore the label")
+	}
+
+	if !byID["A"].NoOutline || byID["A"].Peripheries != 0 {
+		t.Errorf("expected peripheries=0 to drop A's outline, got %+v", byID["A"])
+	}
+	if _, ok := byID["A"].Attributes["peripheries"]; ok {
+		t.Error("expected peripheries=0 not to be kept as an unparsed attribute")
+	}
+	if byID["B"].NoOutline || byID["B"].Peripheries != 1 {
+		t.Errorf("expected a later peripheries=1 to restore B's outline, got %+v", byID["B"])
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	for _, want := range []string{
+		`"noLabel":true`,
+		`"noOutline":true`,
+		"if (d.noLabel) return;",
+		`.attr("fill", normalizeColor(d.fillColor) || strokeColor)`,
+		`if (d.noOutline) {
+                el.selectAll(":scope > *").attr("stroke", "none");`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected %s", want)
+		}
+	}
+}
+
+func TestConvertPolygon(t *testing.T) {
+	g := parse(t, `digraph {
+		A [shape=polygon, sides=5]
+		B [shape=polygon, sides=7, orientation=30, skew=0.4]
+		C [shape=polygon, sides=2, skew=lots]
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	if a := byID["A"]; a.Shape != "polygon" || a.Sides != 5 || a.Orientation != 0 || a.Skew != 0 {
+		t.Errorf("expected a plain 5-sided polygon for A, got %+v", a)
+	}
+	if b := byID["B"]; b.Sides != 7 || b.Orientation != 30 || b.Skew != 0.4 {
+		t.Errorf("expected B sides 7, orientation 30, skew 0.4, got %+v", b)
+	}
+	c := byID["C"]
+	if c.Sides != 0 || c.Attributes["sides"] != "2" || c.Attributes["skew"] != "lots" {
+		t.Errorf("expected invalid polygon parameters to be kept as attributes, got %+v", c)
+	}
+}
+
+func TestConvertNodeSize(t *testing.T) {
+	g := parse(t, `digraph {
+		A [width=2, height=1, fixedsize=true]
+		B [width=1.5]
+		C [width=wide, fixedsize=shape]
+		D [fixedsize=2]
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	if a := byID["A"]; a.Width != 2 || a.Height != 1 || !a.FixedSize {
+		t.Errorf("expected A to be fixed at 2x1 inches, got %+v", a)
+	}
+	if b := byID["B"]; b.Width != 1.5 || b.Height != 0 || b.FixedSize {
+		t.Errorf("expected B to be at least 1.5 inches wide, got %+v", b)
+	}
+	c := byID["C"]
+	if c.Width != 0 || c.FixedSize || c.Attributes["width"] != "wide" || c.Attributes["fixedsize"] != "shape" {
+		t.Errorf("expected unsupported values to be kept as attributes, got %+v", c)
+	}
+	if d := byID["D"]; !d.FixedSize || d.Attributes["fixedsize"] != "" {
+		t.Errorf("expected fixedsize=2 to be true like any nonzero number, got %+v", d)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	for _, want := range []string{
+		"const outline = this.firstChild.getBBox();",
+		"const sx = w / outline.width;",
+		"if (round) w = h = Math.max(w, h);",
+	} {
+		if !contains(string(html), want) {
+			t.Errorf("expected shapes to be scaled from their own outline, missing %q", want)
+		}
+	}
+}
+
+func TestConvertUnits(t *testing.T) {
+	g := parse(t, `digraph {
+		A [penwidth="1.5pt", fontsize="12pt", width="72px", height="0.5in"]
+		B [penwidth=2, width=1.5]
+		C [penwidth=thick, fontsize="12em"]
+		D [fontsize=nan, width=NaN, penwidth=Inf]
+		E [fontsize="1e308in"]
+		A -> B [penwidth="1.5pt", fontsize="9px"]
+		B -> C [penwidth="pt"]
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	byID := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		byID[n.ID] = n
+	}
+	if a := byID["A"]; a.PenWidth != 1.5 || a.FontSize != 12 || a.Width != 1 || a.Height != 0.5 {
+		t.Errorf("expected units to be stripped and converted for A, got %+v", a)
+	}
+	if b := byID["B"]; b.PenWidth != 2 || b.Width != 1.5 {
+		t.Errorf("expected bare numbers for B, got %+v", b)
+	}
+	c := byID["C"]
+	if c.PenWidth != 0 || c.FontSize != 0 || c.Attributes["penwidth"] != "thick" || c.Attributes["fontsize"] != "12em" {
+		t.Errorf("expected unparseable values to leave defaults and be kept as attributes, got %+v", c)
+	}
+	if d := byID["D"]; d.FontSize != 0 || d.Width != 0 || d.PenWidth != 0 || len(d.Attributes) != 3 {
+		t.Errorf("expected NaN and infinite sizes to be kept as attributes, got %+v", d)
+	}
+	if e := byID["E"]; e.FontSize != 0 || e.Attributes["fontsize"] != "1e308in" {
+		t.Errorf("expected a size overflowing in points to be kept as an attribute, got %+v", e)
+	}
+	if _, err := json.Marshal(d3g); err != nil {
+		t.Errorf("expected the graph to marshal, got %v", err)
+	}
+
+	for _, l := range d3g.Links {
+		switch l.Source {
+		case "A":
+			if l.PenWidth != 1.5 || l.FontSize != 9 {
+				t.Errorf("expected A -> B penwidth 1.5 and fontsize 9, got %+v", l)
+			}
+		case "B":
+			if l.PenWidth != 0 || l.Attributes["penwidth"] != "pt" {
+				t.Errorf("expected a unit without a number to be kept as an attribute, got %+v", l)
+			}
+		}
+	}
+}
+
+func TestConvertQuotedPunctuation(t *testing.T) {
+	g := parse(t, `digraph {
+		A [label="a [b] {c}; d -> e"]
+		A -> B [label="x -- y; [z]"]
+		"{C}" -> "[D]"
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if len(d3g.Nodes) != 4 || len(d3g.Links) != 2 {
+		t.Fatalf("expected 4 nodes and 2 links, got %d and %d", len(d3g.Nodes), len(d3g.Links))
+	}
+	labels := make(map[string]string)
+	for _, n := range d3g.Nodes {
+		labels[n.ID] = n.Label
+	}
+	if labels["A"] != "a [b] {c}; d -> e" {
+		t.Errorf("expected A's label intact, got %q", labels["A"])
+	}
+	if labels["{C}"] != "{C}" || labels["[D]"] != "[D]" {
+		t.Errorf("expected quoted IDs with brackets as nodes, got %v", labels)
+	}
+	if d3g.Links[0].Label != "x -- y; [z]" {
+		t.Errorf("expected the edge label intact, got %q", d3g.Links[0].Label)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	// json.Marshal escapes > for HTML; the JS string is unchanged
+	if !contains(string(html), `"label":"a [b] {c}; d -\u003e e"`) {
+		t.Error("expected A's label literally in the graph data")
+	}
+	if !contains(string(html), `"label":"x -- y; [z]"`) {
+		t.Error("expected the edge label literally in the graph data")
+	}
+}
+
+func TestConvertGroupAttribute(t *testing.T) {
+	g := parse(t, `digraph {
+		A [group=g1]
+		B [group=g1]
+		subgraph cluster_x { C [group=g2]; D }
+		subgraph cluster_y { C }
+		E
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	groups := make(map[string]string)
+	for _, n := range d3g.Nodes {
+		groups[n.ID] = n.Group
+		if _, ok := n.Attributes["group"]; ok {
+			t.Errorf("expected group not to be kept as an attribute of %s", n.ID)
+		}
+	}
+	want := map[string]string{"A": "g1", "B": "g1", "C": "g2", "D": "cluster_x", "E": ""}
+	for id, group := range want {
+		if groups[id] != group {
+			t.Errorf("expected %s in group %q, got %q", id, group, groups[id])
+		}
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), "d3.group(graphData.nodes.filter(n => n.group && !subgraphIds.has(n.group)), n => n.group)") {
+		t.Error("expected nodes sharing a group to be clustered by the force")
+	}
+}
+
+func TestConvertLabelEscapes(t *testing.T) {
+	g := parse(t, `digraph G {
+		node [label="id=\N"]
+		A
+		B [label="\N in \G"]
+		A -> B [label="\T to \H"]
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	labels := make(map[string]string)
+	for _, n := range d3g.Nodes {
+		labels[n.ID] = n.Label
+	}
+	if labels["A"] != "id=A" {
+		t.Errorf("expected label %q, got %q", "id=A", labels["A"])
+	}
+	if labels["B"] != "B in G" {
+		t.Errorf("expected label %q, got %q", "B in G", labels["B"])
+	}
+
+	if len(d3g.Links) != 1 || d3g.Links[0].Label != "A to B" {
+		t.Errorf("expected edge label %q, got %+v", "A to B", d3g.Links)
+	}
+}
+
+func TestConvertFillColorGradient(t *testing.T) {
+	g := parse(t, `digraph { A [style=filled, fillcolor="yellow:orange"]; B [fillcolor="red;0.3:white:blue"]; C [fillcolor=green] }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	nodes := make(map[string]Node)
+	for _, n := range d3g.Nodes {
+		nodes[n.ID] = n
+	}
+
+	if got := strings.Join(nodes["A"].FillStops, ","); got != "yellow,orange" {
+		t.Errorf("expected stops yellow,orange, got %q", got)
+	}
+	if nodes["A"].FillColor != "yellow" {
+		t.Errorf("expected fill color to fall back to the first stop, got %q", nodes["A"].FillColor)
+	}
+	if got := strings.Join(nodes["B"].FillStops, ","); got != "red,white,blue" {
+		t.Errorf("expected weighted stops red,white,blue, got %q", got)
+	}
+	if nodes["C"].FillStops != nil || nodes["C"].FillColor != "green" {
+		t.Errorf("expected single fill color, got %+v", nodes["C"])
+	}
+}
+
+func TestConvertLabelFont(t *testing.T) {
+	g := parse(t, `digraph {
+		node [fontcolor=blue]
+		A [fontsize=16]
+		A -> B [fontcolor=red, fontsize=14]
+		B -> C [fontsize=big]
+		C -> D [labelfontcolor=green]
+		D -> A [labelfontcolor=green, fontcolor=red]
+	}`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if l := d3g.Links[0]; l.FontColor != "red" || l.FontSize != 14 {
+		t.Errorf("expected link font red/14, got %q/%v", l.FontColor, l.FontSize)
+	}
+	if l := d3g.Links[1]; l.FontSize != 0 || l.Attributes["fontsize"] != "big" {
+		t.Errorf("expected invalid fontsize to stay in attributes, got %+v", l)
+	}
+	if l := d3g.Links[2]; l.LabelFontColor != "green" || l.FontColor != "" || l.Attr("labelfontcolor") != "green" {
+		t.Errorf("expected labelfontcolor green, got %+v", l)
+	}
+	if l := d3g.Links[3]; l.LabelFontColor != "green" || l.FontColor != "red" {
+		t.Errorf("expected both font colors kept, got %+v", l)
+	}
+
+	for _, n := range d3g.Nodes {
+		if n.FontColor != "blue" {
+			t.Errorf("%s: expected default fontcolor blue, got %q", n.ID, n.FontColor)
+		}
+		if n.ID == "A" && n.FontSize != 16 {
+			t.Errorf("expected A fontsize 16, got %v", n.FontSize)
+		}
+	}
+}
+
+func TestConvertDecoratedShapes(t *testing.T) {
+	g := parse(t, `digraph { start [shape=Mdiamond]; end [shape=Msquare]; mid [shape=Mcircle]; plain [shape=diamond] }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	want := map[string]struct {
+		shape     string
+		decorated bool
+	}{
+		"start": {"diamond", true},
+		"end":   {"box", true},
+		"mid":   {"circle", true},
+		"plain": {"diamond", false},
+	}
+	for _, n := range d3g.Nodes {
+		w := want[n.ID]
+		if n.Shape != w.shape || n.Decorated != w.decorated {
+			t.Errorf("%s: expected shape %q decorated=%v, got %q decorated=%v", n.ID, w.shape, w.decorated, n.Shape, n.Decorated)
+		}
+	}
+}
+
+func TestConvertStrict(t *testing.T) {
+	g := parse(t, `strict digraph { A -> B; A -> B }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if !d3g.Strict {
+		t.Error("expected strict graph")
+	}
+
+	// Strict should prevent duplicate edges
+	if len(d3g.Links) != 1 {
+		t.Errorf("expected 1 link (strict mode), got %d", len(d3g.Links))
+	}
+}
+
+func TestConvertUndirectedGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"node to group", `graph { A -- {B C} }`, []string{"A-B", "A-C"}},
+		{"group to group", `graph { {A B} -- {C D} }`, []string{"A-C", "A-D", "B-C", "B-D"}},
+		{"strict reversed", `strict graph { A -- B; B -- A }`, []string{"A-B"}},
+		{"strict reversed groups", `strict graph { {A B} -- C; C -- {B A} }`, []string{"A-C", "B-C"}},
+		{"strict directed keeps reverse", `strict digraph { A -> B; B -> A }`, []string{"A-B", "B-A"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d3g, err := Convert(parse(t, tt.input))
+			if err != nil {
+				t.Fatalf("convert error: %v", err)
+			}
+
+			if len(d3g.Links) != len(tt.want) {
+				t.Fatalf("expected %d links, got %d: %+v", len(tt.want), len(d3g.Links), d3g.Links)
+			}
+			for i, l := range d3g.Links {
+				if got := l.Source + "-" + l.Target; got != tt.want[i] {
+					t.Errorf("link %d: expected %s, got %s", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestConvertUndirectedGraph(t *testing.T) {
+	g := parse(t, `graph { A -- B }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if d3g.Directed {
+		t.Error("expected undirected graph")
+	}
+}
+
+func TestConvertSplinesAttribute(t *testing.T) {
+	g := parse(t, `digraph { splines=ortho; A -> B }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if d3g.EdgeStyle != "ortho" {
+		t.Errorf("expected edge style 'ortho', got %q", d3g.EdgeStyle)
+	}
+	if d3g.Attributes["splines"] != "ortho" {
+		t.Errorf("expected splines attribute 'ortho', got %q", d3g.Attributes["splines"])
+	}
+}
+
+func TestConvertSplinesGraphAttrStmt(t *testing.T) {
+	g := parse(t, `graph { graph [splines=true] subgraph { splines=false } A -- B }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	// The subgraph assignment must not override the graph-level value
+	if d3g.EdgeStyle != "curved" {
+		t.Errorf("expected edge style 'curved', got %q", d3g.EdgeStyle)
+	}
+}
+
+func TestRenderTaperedEdge(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B", Style: "tapered", Tapered: true}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `"tapered":true`) {
+		t.Error("expected the tapered flag in the graph data")
+	}
+	if !contains(htmlStr, "M${s.x + nx},${s.y + ny} L${t.x},${t.y} L${s.x - nx},${s.y - ny} Z") {
+		t.Error("expected a closed wedge narrowing to the target")
+	}
+	if !contains(htmlStr, `return d.tapered ? taperedEdgePath(s, t) : singleEdgePath(s, t);`) {
+		t.Error("expected tapered edges to use the wedge path")
+	}
+	if !contains(htmlStr, `if (edgeStyle !== "straight" || length === 0) return singleEdgePath(s, t);`) {
+		t.Error("expected tapered edges to fall back to a line")
+	}
+}
+
+func TestRenderEdgeStylePrecedence(t *testing.T) {
+	d3g := &Graph{
+		Nodes:     []Node{{ID: "A"}, {ID: "B"}},
+		Links:     []Link{{Source: "A", Target: "B"}},
+		Directed:  true,
+		EdgeStyle: "ortho",
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `const edgeStyle = "ortho"`) {
+		t.Error("expected graph edge style to be used when no option is set")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{EdgeStyle: "curved"})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `const edgeStyle = "curved"`) {
+		t.Error("expected explicit EdgeStyle option to take precedence")
+	}
+}
+
+func TestRenderWidthByWeight(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A"}, {ID: "B"}, {ID: "C"}},
+		Links: []Link{
+			{Source: "A", Target: "B", Attributes: map[string]string{"weight": "1"}},
+			{Source: "B", Target: "C", Attributes: map[string]string{"weight": "5"}},
+			{Source: "A", Target: "C", Attributes: map[string]string{"weight": "3"}},
+			{Source: "C", Target: "A"},
+		},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{WidthByWeight: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+
+	want := []float64{minWeightWidth, maxWeightWidth, (minWeightWidth + maxWeightWidth) / 2.0, 0}
+	for i, l := range renderedGraph(t, html).Links {
+		if l.Width != want[i] {
+			t.Errorf("link %s -> %s: expected width %v, got %v", l.Source, l.Target, want[i], l.Width)
+		}
+	}
+
+	htmlStr := string(html)
+	if !contains(htmlStr, `{"source":"B","target":"C","width":8,`) {
+		t.Error("expected the heaviest edge's width in the graph data")
+	}
+	if !contains(htmlStr, `.attr("stroke-width", d => d.penWidth || d.width || (d.count > 1`) {
+		t.Error("expected links to take their stroke width from the weight scale")
+	}
+}
+
+func TestRenderWidthByWeightUniform(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A"}, {ID: "B"}},
+		Links: []Link{
+			{Source: "A", Target: "B", Attributes: map[string]string{"weight": "2"}},
+			{Source: "B", Target: "A", Attributes: map[string]string{"weight": "2"}},
+		},
+	}
+	if _, err := RenderHTML(d3g, RenderOptions{WidthByWeight: true}); err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	for _, l := range d3g.Links {
+		if l.Width != 0 {
+			t.Errorf("expected the default width when all weights are equal, got %v", l.Width)
+		}
+	}
+}
+
+func TestConvertSizeAndRatio(t *testing.T) {
+	g := parse(t, `digraph { size="8,6"; ratio=fill; A -> B }`)
+
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	if d3g.Size == nil {
+		t.Fatal("expected size to be parsed")
+	}
+	if d3g.Size.Width != 8 || d3g.Size.Height != 6 {
+		t.Errorf("expected size 8x6 inches, got %vx%v", d3g.Size.Width, d3g.Size.Height)
+	}
+	if d3g.Size.Fill {
+		t.Error("expected no fill without '!'")
+	}
+	if d3g.Ratio != "fill" {
+		t.Errorf("expected ratio 'fill', got %q", d3g.Ratio)
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  *Size
+	}{
+		{"8,6", &Size{Width: 8, Height: 6}},
+		{"7.5,10!", &Size{Width: 7.5, Height: 10, Fill: true}},
+		{"5", &Size{Width: 5, Height: 5}},
+		{"", nil},
+		{"a,b", nil},
+		{"1,2,3", nil},
+		{"nan", nil},
+		{"inf,3", nil},
+		{"3,-Inf", nil},
+		{"1e308", nil},
+	}
+
+	for _, tt := range tests {
+		got := parseSize(tt.input)
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("parseSize(%q) = %+v, want %+v", tt.input, got, tt.want)
+		}
+	}
+}
+
+func TestRenderViewBoxFromSize(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { size="8,6"; A -> B }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	// 8x6 inches at 72 points per inch
+	if !contains(string(html), `viewBox="0 0 576 432"`) {
+		t.Error("expected viewBox to match the size attribute")
+	}
+
+	// A numeric ratio (height/width) narrows the canvas to stay within size
+	d3g.Ratio = "1"
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `viewBox="0 0 432 432"`) {
+		t.Error("expected viewBox to respect ratio within size")
+	}
+
+	// Explicit dimensions take precedence
+	html, err = RenderHTML(d3g, RenderOptions{Width: 800, Height: 600})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `viewBox="0 0 800 600"`) {
+		t.Error("expected explicit Width/Height to take precedence")
+	}
+}
+
+func TestRenderTransparent(t *testing.T) {
+	d3g := &Graph{Nodes: []Node{{ID: "A"}}, BgColor: "lightyellow"}
+
+	html, err := RenderHTML(d3g, RenderOptions{Transparent: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, "#graph {\n            width: 100vw;\n            height: 100vh;\n            background: transparent;") {
+		t.Error("expected a transparent canvas")
+	}
+	if !contains(htmlStr, "overflow: hidden;\n            background: transparent;") {
+		t.Error("expected a transparent page")
+	}
+	if data := templateData(t, d3g, RenderOptions{Transparent: true}); data["Transparent"] != true {
+		t.Errorf("expected transparency to be enabled, got %v", data["Transparent"])
+	}
+	if !contains(htmlTemplate, "const transparent = {{.Transparent}};") || !contains(htmlStr, `if (transparent) svg.style("background", "transparent");`) {
+		t.Error("expected the transparent background to override bgcolor")
+	}
+
+	html, err = RenderHTML(&Graph{Nodes: []Node{{ID: "A"}}}, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr = string(html)
+	if !contains(htmlStr, "#graph {\n            width: 100vw;\n            height: 100vh;\n            background: white;") {
+		t.Error("expected a white canvas by default")
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["Transparent"] != false {
+		t.Errorf("expected transparency off by default, got %v", data["Transparent"])
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{
+			{ID: "A", Label: "Node A"},
+			{ID: "B", Label: "Node B"},
+		},
+		Links: []Link{
+			{Source: "A", Target: "B"},
+		},
+		Directed: true,
+	}
+
+	opts := RenderOptions{
+		Title: "Test Graph",
+	}
+
+	html, err := RenderHTML(d3g, opts)
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+
+	htmlStr := string(html)
+
+	// Check for key elements
+	if !contains(htmlStr, "<title>Test Graph</title>") {
+		t.Error("expected title in HTML")
+	}
+
+	if !contains(htmlStr, "d3js.org") {
+		t.Error("expected D3.js reference in HTML")
+	}
+
+	if !contains(htmlStr, "Node A") {
+		t.Error("expected node label in HTML")
+	}
+
+	if !contains(htmlStr, "nodeClick") {
+		t.Error("expected nodeClick event handler in HTML")
+	}
+
+	if !contains(htmlStr, "d3.drag") {
+		t.Error("expected drag behavior in HTML")
+	}
+
+	if !contains(htmlStr, "d3.zoom") {
+		t.Error("expected zoom behavior in HTML")
+	}
+}
+
+func TestRenderTitleFromLabel(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph G { label="Pipeline X"; A -> B }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{TitleFromLabel: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), "<title>Pipeline X</title>") {
+		t.Error("expected title from graph label")
+	}
+
+	// Without the option the graph ID is used
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), "<title>G</title>") {
+		t.Error("expected title from graph ID")
+	}
+
+	// An explicit title always wins
+	html, err = RenderHTML(d3g, RenderOptions{Title: "Explicit", TitleFromLabel: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), "<title>Explicit</title>") {
+		t.Error("expected explicit title")
+	}
+}
+
+func TestRenderNodeImage(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{
+			{ID: "A", Image: "data:image/png;base64,iVBORw0KGgo="},
+			{ID: "B", Image: "/etc/secret.png"},
+		},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{InlineImagesOnly: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `.append("image")`) {
+		t.Error("expected an SVG image element to be emitted")
+	}
+	if !contains(htmlStr, "data:image/png;base64,iVBORw0KGgo=") {
+		t.Error("expected data: URI image to be kept")
+	}
+	if contains(htmlStr, "/etc/secret.png") {
+		t.Error("expected non-data: image to be dropped with InlineImagesOnly")
+	}
+}
+
+func TestRenderExpandable(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "seed"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{Expandable: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	for _, want := range []string{`"nodeExpand"`, "function addNodes(", "function addLinks(", "window.dot2d3"} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected %s in expandable HTML", want)
+		}
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), "nodeExpand") {
+		t.Error("expected no expand handling when Expandable is off")
+	}
+}
+
+func TestRenderPeripheries(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "accept", Shape: "circle", Peripheries: 2}},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `"peripheries":2`) {
+		t.Error("expected peripheries in graph data")
+	}
+	if !contains(htmlStr, `i < (d.peripheries || 1)`) || !contains(htmlStr, `classed("periphery", true)`) {
+		t.Error("expected extra outlines to be drawn for peripheries")
+	}
+}
+
+func TestRenderPolygon(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A", Shape: "polygon", Sides: 5}},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if n := renderedGraph(t, html).Nodes[0]; n.Shape != "polygon" || n.Sides != 5 {
+		t.Errorf("expected the polygon parameters in the graph data, got %+v", n)
+	}
+	if !contains(htmlStr, `.attr("points", polygonPoints(d))`) {
+		t.Error("expected polygon nodes to be drawn from polygonPoints")
+	}
+
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	start := strings.Index(htmlStr, "function polygonPoints(")
+	if start < 0 {
+		t.Fatal("expected a polygonPoints function")
+	}
+	end := strings.Index(htmlStr[start:], "\n    }\n") + len("\n    }\n")
+	fn := htmlStr[start : start+end]
+
+	// One vertex per side, 4 by default
+	for _, tt := range []struct {
+		node string
+		want int
+	}{
+		{`{sides: 5}`, 5},
+		{`{sides: 7, orientation: 30, skew: 0.5}`, 7},
+		{`{}`, 4},
+	} {
+		out, err := exec.Command(node, "-e", fn+"\nconsole.log(polygonPoints("+tt.node+"));").Output()
+		if err != nil {
+			t.Fatalf("node error: %v", err)
+		}
+		points := strings.Fields(string(out))
+		if len(points) != tt.want {
+			t.Errorf("polygonPoints(%s): expected %d vertices, got %q", tt.node, tt.want, out)
+		}
+		for _, p := range points {
+			if xy := strings.Split(p, ","); len(xy) != 2 {
+				t.Errorf("polygonPoints(%s): expected x,y vertices, got %q", tt.node, p)
+			}
+		}
+	}
+}
+
+func TestRenderFastEdges(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}, {ID: "C"}},
+		Links:    []Link{{Source: "A", Target: "B"}, {Source: "B", Target: "C"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{FastEdges: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if data := templateData(t, d3g, RenderOptions{FastEdges: true}); data["FastEdges"] != true {
+		t.Errorf("expected fast edge mode to be enabled, got %v", data["FastEdges"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["FastEdges"] != false {
+		t.Errorf("expected fast edge mode to be off by default, got %v", data["FastEdges"])
+	}
+	if !contains(htmlTemplate, "const fastEdges = {{.FastEdges}};") {
+		t.Error("expected the page to read the fast edge option")
+	}
+	if !contains(htmlStr, `.attr("class", "fast-edges")`) || !contains(htmlStr, `fastEdgePath.attr("d", segments.join(""))`) {
+		t.Error("expected single-path edge renderer")
+	}
+}
+
+func TestRenderEdgeFilter(t *testing.T) {
+	g := parse(t, `digraph {
+		A -> B [style=dashed, color=red, weight=2]
+		B -> C [style=dotted, label="calls"]
+		C -> A [color=blue]
+	}`)
+	d3g, err := Convert(g)
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	want := []edgeFilter{
+		{Attribute: "color", Values: []string{"blue", "red"}},
+		{Attribute: "style", Values: []string{"dashed", "dotted"}},
+		{Attribute: "weight", Values: []string{"2"}},
+	}
+	if got := edgeFilters(d3g); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("edgeFilters = %v, want %v", got, want)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{EdgeFilter: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	// Colors are normalized before the toggles are listed, so they match
+	// the edges' color values in the page
+	for _, toggle := range []string{
+		`data-attribute="color" data-value="#0000ff"`,
+		`data-attribute="color" data-value="#ff0000"`,
+		`data-attribute="style" data-value="dashed"`,
+		`data-attribute="style" data-value="dotted"`,
+		`data-attribute="weight" data-value="2"`,
+	} {
+		if !contains(htmlStr, toggle) {
+			t.Errorf("expected edge filter toggle %s", toggle)
+		}
+	}
+	if contains(htmlStr, `data-value="calls"`) {
+		t.Error("expected edge labels to be left out of the edge filter")
+	}
+	if !contains(htmlStr, `if (edgeHidden(d)) return true;`) {
+		t.Error("expected hidden edge values to filter out edges")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `id="edge-filter"`) {
+		t.Error("expected no edge filter unless enabled")
+	}
+}
+
+func TestRenderDirectionalFilter(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}, {ID: "C"}},
+		Links:    []Link{{Source: "A", Target: "B"}, {Source: "B", Target: "C"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{DirectionalFilter: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	for _, want := range []string{
+		`<input type="radio" name="degree-direction" value="in">`,
+		`<input type="radio" name="degree-direction" value="out">`,
+		`<input type="radio" name="degree-direction" value="both" checked>`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected direction control %s", want)
+		}
+	}
+	for _, want := range []string{
+		"const forwardAdjacency = new Map();",
+		"const backwardAdjacency = new Map();",
+		"forwardAdjacency.get(sourceId).add(targetId);",
+		"backwardAdjacency.get(targetId).add(sourceId);",
+		"if (!linkDirected(l)) {",
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected directional adjacency %q", want)
+		}
+	}
+	if !contains(htmlStr, `const neighbors = degreeDirection === "out" ? forwardAdjacency
+            : degreeDirection === "in" ? backwardAdjacency
+            : adjacency;`) {
+		t.Error("expected the degree filter to follow the chosen direction")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `id="degree-direction"`) {
+		t.Error("expected no direction control by default")
+	}
+}
+
+func TestRenderStatic(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B"}},
+		Directed: true,
+	}
+
+	if data := templateData(t, d3g, RenderOptions{Static: true}); data["Static"] != true {
+		t.Errorf("expected static layout to be enabled, got %v", data["Static"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["Static"] != false {
+		t.Errorf("expected static layout to be off by default, got %v", data["Static"])
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{Static: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(htmlTemplate, "const staticLayout = {{.Static}};") {
+		t.Error("expected the page to read the static option")
+	}
+	if !contains(string(html), "if (!staticLayout) selection.call(drag(simulation));") {
+		t.Error("expected dragging to be disabled for static layouts")
+	}
+}
+
+func TestRenderSimulationNodeLimit(t *testing.T) {
+	d3g := &Graph{Directed: true}
+	for i := 0; i < 5; i++ {
+		d3g.Nodes = append(d3g.Nodes, Node{ID: fmt.Sprintf("n%d", i)})
+	}
+
+	if data := templateData(t, d3g, RenderOptions{SimulationNodeLimit: 4}); data["GridLayout"] != true {
+		t.Errorf("expected grid layout past the simulation limit, got %v", data["GridLayout"])
+	}
+	if data := templateData(t, d3g, RenderOptions{SimulationNodeLimit: 5}); data["GridLayout"] != false {
+		t.Errorf("expected the force layout at the simulation limit, got %v", data["GridLayout"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["GridLayout"] != false {
+		t.Errorf("expected the force layout below DefaultSimulationNodeLimit (%d), got %v", DefaultSimulationNodeLimit, data["GridLayout"])
+	}
+	if !contains(htmlTemplate, "const gridLayout = {{.GridLayout}};") {
+		t.Error("expected the page to read the grid layout option")
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{SimulationNodeLimit: 4})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	if !contains(htmlStr, `id="layout-notice"`) || !contains(htmlStr, "This graph has 5 nodes") {
+		t.Error("expected a notice explaining the grid layout")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{SimulationNodeLimit: 5})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `id="layout-notice"`) {
+		t.Error("expected no layout notice at the simulation limit")
+	}
+}
+
+func TestRenderCircularLayout(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A", Group: "cluster_x"}, {ID: "B", Group: "cluster_x"}, {ID: "C"}},
+		Links: []Link{{Source: "A", Target: "C"}},
+	}
+
+	data := templateData(t, d3g, RenderOptions{Layout: "circular", SimulationNodeLimit: 1})
+	if data["CircularLayout"] != true || data["GridLayout"] != false {
+		t.Errorf("expected the circular layout to replace both simulation and grid, got circular=%v grid=%v", data["CircularLayout"], data["GridLayout"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["CircularLayout"] != false {
+		t.Errorf("expected the force layout by default, got %v", data["CircularLayout"])
+	}
+	if !contains(htmlTemplate, "const circularLayout = {{.CircularLayout}};") {
+		t.Error("expected the page to read the circular layout option")
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{Layout: "circular", SimulationNodeLimit: 1})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	if !contains(htmlStr, "if (circularLayout) placeOnCircle();") {
+		t.Error("expected nodes to be placed on the circle on load")
+	}
+	if !contains(htmlStr, "const radius = Math.max(100, slots.length * spacing / (2 * Math.PI));") {
+		t.Error("expected the radius to grow with the number of slots")
+	}
+	if !contains(htmlStr, "n.x = cx + radius * Math.cos(angle);") || !contains(htmlStr, "n.y = cy + radius * Math.sin(angle);") {
+		t.Error("expected nodes positioned on the circle")
+	}
+	if !contains(htmlStr, `if (i > 0 && (n.group || "") !== (nodes[i - 1].group || "")) slots.push(null);`) {
+		t.Error("expected a gap between cluster arcs")
+	}
+
+	if _, err := RenderHTML(d3g, RenderOptions{Layout: "spiral"}); err == nil {
+		t.Error("expected an error for an unknown layout")
+	}
+}
+
+func TestRenderAnimateEntry(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{AnimateEntry: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	for _, want := range []string{
+		`nodeGroup.style("opacity", 0)
+            .transition().duration(entryDuration)`,
+		`.style("scale", "0")
+            .transition().duration(entryDuration).ease(d3.easeBackOut)
+            .style("scale", "1")`,
+		`.transition().delay(entryDuration / 2).duration(entryDuration)`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected entry transition code %q", want)
+		}
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), "entryDuration") {
+		t.Error("expected no entry animation by default")
+	}
+}
+
+func TestRenderInspector(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A", Attributes: map[string]string{"owner": "ops"}}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{ShowInspector: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `<div class="inspector" id="inspector">`) {
+		t.Error("expected inspector panel")
+	}
+	if !contains(htmlStr, `document.addEventListener("nodeClick", function(e) {
+        const d = e.detail;
+        showInspector(`) || !contains(htmlStr, `document.addEventListener("edgeClick", function(e) {
+        const d = e.detail;`) {
+		t.Error("expected inspector to update on node and edge clicks")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `id="inspector"`) || contains(string(html), "function showInspector") {
+		t.Error("expected no inspector by default")
+	}
+}
+
+func TestRenderShowTopNodes(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "hub"}, {ID: "A"}, {ID: "B"}},
+		Links: []Link{{Source: "hub", Target: "A"}, {Source: "hub", Target: "B"}},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{ShowTopNodes: 5})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `<ol class="top-nodes" id="top-nodes"></ol>`) {
+		t.Error("expected a top nodes list")
+	}
+	if data := templateData(t, d3g, RenderOptions{ShowTopNodes: 5}); data["TopNodes"] != 5.0 {
+		t.Errorf("expected the top nodes count to be passed to the page, got %v", data["TopNodes"])
+	}
+	if !contains(htmlTemplate, "const showTopNodes = {{.TopNodes}};") {
+		t.Error("expected the page to read the top nodes count")
+	}
+	if !contains(htmlStr, ".sort((a, b) => nodeDegrees.get(b.id) - nodeDegrees.get(a.id)") {
+		t.Error("expected top nodes to be ranked by degree")
+	}
+	if !contains(htmlStr, `.on("click", (event, d) => selectNodeAndZoom(d))`) {
+		t.Error("expected clicking a top node to select it")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `id="top-nodes"`) {
+		t.Error("expected no top nodes list unless enabled")
+	}
+}
+
+func TestRenderDegreeBadges(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{ShowDegreeBadges: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	if data := templateData(t, d3g, RenderOptions{ShowDegreeBadges: true}); data["DegreeBadges"] != true {
+		t.Errorf("expected degree badges to be enabled, got %v", data["DegreeBadges"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["DegreeBadges"] != false {
+		t.Errorf("expected degree badges to be off by default, got %v", data["DegreeBadges"])
+	}
+	if !contains(htmlTemplate, "const showDegreeBadges = {{.DegreeBadges}};") {
+		t.Error("expected the page to read the degree badge option")
+	}
+	for _, want := range []string{
+		`{ kind: "in", count: backwardAdjacency.get(d.id).size, x: box.x },`,
+		`{ kind: "out", count: forwardAdjacency.get(d.id).size, x: box.x + box.width },`,
+		`: [{ kind: "degree", count: adjacency.get(d.id).size, x: box.x + box.width }];`,
+		`.attr("class", "degree-badge " + b.kind)`,
+		`badge.append("circle").attr("r", 7);`,
+		`badge.append("text").text(b.count);`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected degree badge code %s", want)
+		}
+	}
+}
+
+func TestRenderGravity(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A"}, {ID: "B"}},
+		Links: []Link{{Source: "A", Target: "B"}},
+	}
+
+	opts := RenderOptions{Gravity: 0.05, DisableCenter: true}
+	if data := templateData(t, d3g, opts); data["Gravity"] != 0.05 || data["DisableCenter"] != true {
+		t.Errorf("expected gravity 0.05 without centering, got %v and %v", data["Gravity"], data["DisableCenter"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["Gravity"] != 0.0 || data["DisableCenter"] != false {
+		t.Errorf("expected default layout forces to be unchanged, got %v and %v", data["Gravity"], data["DisableCenter"])
+	}
+
+	html, err := RenderHTML(d3g, opts)
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	if !contains(htmlTemplate, "const gravity = {{.Gravity}};") || !contains(htmlStr, `.force("x", d3.forceX(width / 2).strength(gravity))`) {
+		t.Error("expected gravity force")
+	}
+	if !contains(htmlTemplate, "const disableCenter = {{.DisableCenter}};") || !contains(htmlStr, `simulation.force("center", null);`) {
+		t.Error("expected centering force to be removed")
+	}
+}
+
+func TestRenderClusterForces(t *testing.T) {
+	d3g := &Graph{
+		Nodes:     []Node{{ID: "A", Group: "cluster_a"}, {ID: "B", Group: "cluster_b"}},
+		Subgraphs: []Subgraph{{ID: "cluster_a", Nodes: []string{"A"}}, {ID: "cluster_b", Nodes: []string{"B"}}},
+	}
+
+	tests := []struct {
+		opts                            RenderOptions
+		attraction, repulsion, distance float64
+	}{
+		{RenderOptions{ClusterAttraction: 0.3, ClusterRepulsion: 1.5, ClusterSeparation: 350}, 0.3, 1.5, 350},
+		{RenderOptions{}, DefaultClusterAttraction, DefaultClusterRepulsion, DefaultClusterSeparation},
+	}
+	for _, tt := range tests {
+		data := templateData(t, d3g, tt.opts)
+		if data["ClusterAttraction"] != tt.attraction || data["ClusterRepulsion"] != tt.repulsion || data["ClusterSeparation"] != tt.distance {
+			t.Errorf("expected cluster forces %v, %v, %v, got %v, %v, %v", tt.attraction, tt.repulsion, tt.distance,
+				data["ClusterAttraction"], data["ClusterRepulsion"], data["ClusterSeparation"])
+		}
+	}
+
+	for _, want := range []string{
+		"const clusterAttractionStrength = {{.ClusterAttraction}};",
+		"const clusterRepulsionStrength = {{.ClusterRepulsion}};",
+		"const clusterRepulsionDistance = {{.ClusterSeparation}};",
+	} {
+		if !contains(htmlTemplate, want) {
+			t.Errorf("expected %q in the page template", want)
+		}
+	}
+}
+
+func TestRenderDecoratedShape(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "start", Shape: "diamond", Decorated: true}},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `"decorated":true`) {
+		t.Error("expected decorated flag in graph data")
+	}
+	if !contains(htmlStr, `.attr("class", "decoration")`) || !contains(htmlStr, "diamond: [[-5, -16, 5, -16]") {
+		t.Error("expected decoration lines to be drawn")
+	}
+}
+
+func TestRenderClass(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A", Class: "important"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B", Class: "hot path"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `"class":"important"`) || !contains(htmlStr, `"class":"hot path"`) {
+		t.Error("expected classes in the graph data")
+	}
+	if !contains(htmlStr, `.attr("class", d => withClass("node", d))`) {
+		t.Error("expected node classes on the node group")
+	}
+	if !contains(htmlStr, `.attr("class", d => withClass(linkDirected(d) ? "link directed" : "link", d))`) {
+		t.Error("expected edge classes on the edge path")
+	}
+	if !contains(htmlStr, `return d.class ? base + " " + d.class : base;`) {
+		t.Error("expected classes to be appended to the renderer's own")
+	}
+}
+
+func TestRenderLayers(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { layers="back:front"; A [layer=back]; A -> B [layer=front] }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	for _, layer := range []string{"back", "front"} {
+		if !contains(htmlStr, `<input type="checkbox" class="layer-toggle" data-layer="`+layer+`" checked>`) {
+			t.Errorf("expected a toggle for layer %q", layer)
+		}
+	}
+	if !contains(htmlStr, `"layers":["back"]`) {
+		t.Error("expected node layers in the graph data")
+	}
+	if !contains(htmlStr, `if (layerHidden(d)) return true;`) {
+		t.Error("expected nodes to be filtered by layer")
+	}
+
+	html, err = RenderHTML(&Graph{Nodes: []Node{{ID: "A"}}}, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `class="layer-toggle"`) {
+		t.Error("expected no layer toggles for a graph without layers")
+	}
+}
+
+func TestWrapLabel(t *testing.T) {
+	tests := []struct {
+		label string
+		width int
+		want  []string
+	}{
+		{"short", 10, []string{"short"}},
+		{"the quick brown fox jumps", 10, []string{"the quick", "brown fox", "jumps"}},
+		{"a supercalifragilistic word", 8, []string{"a", "supercalifragilistic", "word"}},
+		{"first line\nsecond", 0, []string{"first line", "second"}},
+		{"no wrapping at all here", 0, []string{"no wrapping at all here"}},
+	}
+
+	for _, tt := range tests {
+		got := wrapLabel(tt.label, tt.width)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("wrapLabel(%q, %d): expected %q, got %q", tt.label, tt.width, tt.want, got)
+		}
+	}
+}
+
+func TestRenderLabelWrap(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A", Label: "a rather long label for one node"}, {ID: "B"}},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{LabelWrap: 12})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `"labelLines":["a rather","long label","for one node"]`) {
+		t.Error("expected wrapped label lines in graph data")
+	}
+	if !contains(htmlStr, `text.append("tspan")`) {
+		t.Error("expected label lines to be drawn as tspans")
+	}
+	if lines := renderedGraph(t, html).Nodes[1].LabelLines; lines != nil {
+		t.Errorf("expected short label not to be split, got %q", lines)
+	}
+	if d3g.Nodes[0].LabelLines != nil {
+		t.Errorf("expected the caller's graph to be left alone, got %q", d3g.Nodes[0].LabelLines)
+	}
+
+	// Rendering the same graph again doesn't reuse the earlier lines
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if lines := renderedGraph(t, html).Nodes[0].LabelLines; lines != nil {
+		t.Errorf("expected no wrapping by default, got %q", lines)
+	}
+}
+
+func TestRenderMaxLabelLength(t *testing.T) {
+	label := "A very long label that would blow out the node"
+	d3g := &Graph{Nodes: []Node{{ID: "A", Label: label}}}
+
+	html, err := RenderHTML(d3g, RenderOptions{MaxLabelLength: 12})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if data := templateData(t, d3g, RenderOptions{MaxLabelLength: 12}); data["MaxLabelLength"] != 12.0 {
+		t.Errorf("expected the label length limit in the page, got %v", data["MaxLabelLength"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["MaxLabelLength"] != 0.0 {
+		t.Errorf("expected no truncation by default, got %v", data["MaxLabelLength"])
+	}
+	if !contains(htmlTemplate, "const maxLabelLength = {{.MaxLabelLength}};") {
+		t.Error("expected the page to read the label length limit")
+	}
+	if !contains(htmlStr, `return chars.slice(0, Math.max(0, maxLabelLength - 1)).join("") + "…";`) {
+		t.Error("expected long labels to be cut with an ellipsis")
+	}
+	if !contains(htmlStr, "text.text(truncateLabel(d.label || d.id));") || !contains(htmlStr, ".text(truncateLabel(line));") {
+		t.Error("expected drawn labels to be truncated")
+	}
+	if !contains(htmlStr, "let html = '<strong>' + (d.label || d.id) + '</strong>';") {
+		t.Error("expected the tooltip to keep the full label")
+	}
+	if !contains(htmlStr, `"label":"`+label+`"`) {
+		t.Error("expected the full label in the graph data")
+	}
+}
+
+func TestRenderRankdirLabels(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { rankdir=LR; A -> B [label="next"] }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `"rankdir":"LR"`) {
+		t.Error("expected rankdir in graph data")
+	}
+	if !contains(htmlStr, `const horizontalFlow = rankdir === "LR" || rankdir === "RL";`) {
+		t.Error("expected left-to-right flow detection")
+	}
+	if !contains(htmlStr, "translate(${pos.x},${pos.y + flowLabelOffset})") {
+		t.Error("expected edge labels to be offset above the edge")
+	}
+}
+
+func TestRenderRemoveOverlap(t *testing.T) {
+	d3g := &Graph{
+		Nodes:      []Node{{ID: "A"}, {ID: "B"}},
+		Attributes: map[string]string{"esep": "+6"},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{RemoveOverlap: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if data := templateData(t, d3g, RenderOptions{RemoveOverlap: true}); data["RemoveOverlap"] != true {
+		t.Errorf("expected overlap removal to be enabled, got %v", data["RemoveOverlap"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["RemoveOverlap"] != false {
+		t.Errorf("expected overlap removal off by default, got %v", data["RemoveOverlap"])
+	}
+	if !contains(htmlTemplate, "const removeOverlap = {{.RemoveOverlap}};") {
+		t.Error("expected the page to read the overlap removal option")
+	}
+	if !contains(htmlStr, "function removeOverlaps()") || !contains(htmlStr, "const b = this.getBBox();") {
+		t.Error("expected an overlap removal pass over node bounding boxes")
+	}
+	if !contains(htmlStr, `simulation.on("end.overlap", null);`) {
+		t.Error("expected overlap removal only the first time the simulation settles")
+	}
+	if !contains(htmlStr, "const tree = d3.quadtree(boxes, cx, cy);") {
+		t.Error("expected neighbors to be found in a quadtree")
+	}
+	if !contains(htmlStr, "if (removeOverlap && !gridLayout && !circularLayout) removeOverlaps();") {
+		t.Error("expected overlap removal in static layouts")
+	}
+	if !contains(htmlStr, "parseFloat((graphData.attributes || {}).esep) || 4") {
+		t.Error("expected esep to set the margin")
+	}
+}
+
+func TestRenderStableColors(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A"}, {ID: "B", Group: "cluster_x"}},
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{StableColors: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if data := templateData(t, d3g, RenderOptions{StableColors: true}); data["StableColors"] != true {
+		t.Errorf("expected stable colors to be enabled, got %v", data["StableColors"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["StableColors"] != false {
+		t.Errorf("expected order-based colors by default, got %v", data["StableColors"])
+	}
+	if !contains(htmlTemplate, "const stableColors = {{.StableColors}};") {
+		t.Error("expected the page to read the stable colors option")
+	}
+	if !contains(htmlStr, "h = Math.imul(h, 0x01000193);") {
+		t.Error("expected an FNV-1a hash of the color key")
+	}
+	if !contains(htmlStr, "return palette[hashString(key) % palette.length];") {
+		t.Error("expected the hash to pick the palette entry")
+	}
+	if !contains(htmlStr, "const autoColor = autoNodeColor(d);") {
+		t.Error("expected nodes to take their automatic color from autoNodeColor")
+	}
+}
+
+func TestRenderScaleArrows(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B"}},
+		Directed: true,
+	}
+
+	if data := templateData(t, d3g, RenderOptions{ScaleArrows: true}); data["ScaleArrows"] != true {
+		t.Errorf("expected arrow scaling to be enabled, got %v", data["ScaleArrows"])
+	}
+	if data := templateData(t, d3g, RenderOptions{}); data["ScaleArrows"] != false {
+		t.Errorf("expected arrow scaling off by default, got %v", data["ScaleArrows"])
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{ScaleArrows: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	if !contains(htmlTemplate, "const scaleArrows = {{.ScaleArrows}};") {
+		t.Error("expected the page to read the arrow scaling option")
+	}
+	if !contains(htmlStr, "if (scaleArrows) rescaleArrows(event.transform.k);") {
+		t.Error("expected markers to be rescaled on zoom")
+	}
+	if !contains(htmlStr, `.attr("markerWidth", this.__base.size / k)`) || !contains(htmlStr, `.attr("refX", scaledRefX(this.__base.refX, this.__base.tipX, k));`) {
+		t.Error("expected marker size and offset to follow the zoom scale")
+	}
+}
+
+func TestScaledRefX(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	html, err := RenderHTML(&Graph{}, RenderOptions{ScaleArrows: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	start := strings.Index(string(html), "function scaledRefX(")
+	if start < 0 {
+		t.Fatal("expected a scaledRefX function")
+	}
+	end := strings.Index(string(html)[start:], "\n    }\n") + len("\n    }\n")
+	fn := string(html)[start : start+end]
+
+	// The tip is (tipX - refX) * markerWidth/10 from the end of the line
+	// before zooming, and must stay there at markerWidth/k
+	tests := []struct {
+		refX, tipX, k, want float64
+	}{
+		{25, 10, 1, 25},
+		{25, 10, 2, 40}, // arrowhead: 15 units short of the end, now 30
+		{25, 10, 0.5, 17.5},
+		{10, 10, 3, 10},  // arrowhead-clipped and -curved: tip on the end
+		{-15, 0, 2, -30}, // arrowhead-reverse: tip at x=0
+	}
+	for _, tt := range tests {
+		script := fmt.Sprintf("%s\nconsole.log(scaledRefX(%g, %g, %g));", fn, tt.refX, tt.tipX, tt.k)
+		out, err := exec.Command(node, "-e", script).Output()
+		if err != nil {
+			t.Fatalf("node error: %v", err)
+		}
+		got, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+		if err != nil || got != tt.want {
+			t.Errorf("scaledRefX(%g, %g, %g) = %s, want %g", tt.refX, tt.tipX, tt.k, out, tt.want)
+		}
+	}
+}
+
+func TestRenderShowExport(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{ShowExport: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `id="export-dot"`) || !contains(htmlStr, "function exportDOT()") {
+		t.Error("expected export button and exportDOT helper")
+	}
+	if !contains(htmlStr, "const visibleNodes = getNodesWithinDegree(selectedNodeId, degreeFilter);\n        const isVisible") {
+		t.Error("expected exportDOT to read the current filter state")
+	}
+
+	if !contains(htmlStr, `id="export-positions"`) || !contains(htmlStr, "downloadDOT(exportPositionedDOT())") {
+		t.Error("expected export-with-positions button")
+	}
+	if !contains(htmlStr, `if (typeof n.x !== "number" || typeof n.y !== "number") return undefined;`) ||
+		!contains(htmlStr, `return round(n.x) + "," + round(-n.y) + "!";`) {
+		t.Error("expected nodePos to format the live node coordinates")
+	}
+	if !contains(htmlStr, `pos: withPositions ? nodePos(n) : undefined`) {
+		t.Error("expected visibleDOT to add pos attributes when asked")
+	}
+
+	if !contains(htmlStr, `id="copy-image"`) || !contains(htmlStr, "function graphImage()") {
+		t.Error("expected copy-as-image button and image helper")
+	}
+	if !contains(htmlStr, `navigator.clipboard.write([new ClipboardItem({ "image/png": blob })])`) {
+		t.Error("expected PNG to be written to the clipboard")
+	}
+	if !contains(htmlStr, `.catch(() => downloadBlob(blob, imageName))`) ||
+		!contains(htmlStr, `const imageName = (graphData.graphId || "graph") + "." + "png";`) {
+		t.Error("expected download fallback when the clipboard is unavailable")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), "exportDOT") || contains(string(html), "graphPNG") {
+		t.Error("expected no export helpers by default")
+	}
+}
+
+func TestExportDOTRoundTrip(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+	d3g := &Graph{
+		Nodes: []Node{
+			{ID: `say "hi"`, Label: `say "hi"`},
+			{ID: `C:\dir\`, Label: "drive\nC"},
+			{ID: "two\nlines", Label: "two\nlines"},
+			{ID: "node", Label: "node"},
+		},
+		Links: []Link{
+			{Source: `say "hi"`, Target: `C:\dir\`, Label: "calls", Count: 3},
+			{Source: `C:\dir\`, Target: "two\nlines", Label: `a\b`},
+			{Source: "two\nlines", Target: "node"},
+		},
+		Directed: true,
+	}
+	html, err := RenderHTML(d3g, RenderOptions{ShowExport: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	snippet := func(from, to string) string {
+		start := strings.Index(string(html), from)
+		if start < 0 {
+			t.Fatalf("expected %q in the page", from)
+		}
+		end := strings.Index(string(html)[start:], to)
+		if end < 0 {
+			t.Fatalf("expected %q after %q", to, from)
+		}
+		return string(html)[start : start+end+len(to)]
+	}
+	data, err := json.Marshal(d3g)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	// Run the page's own collapsed-link labeling and DOT export on the data
+	script := fmt.Sprintf(`const graphData = %s;
+const selectedNodeId = null, degreeFilter = 0;
+function getNodesWithinDegree() { return null; }
+%s
+%s
+process.stdout.write(visibleDOT(false));`,
+		data,
+		snippet("graphData.links.forEach(l => {\n        if (l.count > 1) {", "\n    });\n"),
+		snippet("    const dotKeywords", `return lines.join("\n") + "\n";`+"\n    }\n"))
+	out, err := exec.Command(node, "-e", script).Output()
+	if err != nil {
+		t.Fatalf("node error: %v", err)
+	}
+
+	back, err := Convert(parse(t, string(out)))
+	if err != nil {
+		t.Fatalf("convert error: %v\n%s", err, out)
+	}
+	labels := make(map[string]string)
+	for _, n := range back.Nodes {
+		labels[n.ID] = n.Label
+	}
+	for _, n := range d3g.Nodes {
+		if got, ok := labels[n.ID]; !ok || got != n.Label {
+			t.Errorf("expected node %q labeled %q, got %q (present: %v)\n%s", n.ID, n.Label, got, ok, out)
+		}
+	}
+	var got []string
+	for _, l := range back.Links {
+		got = append(got, fmt.Sprintf("%q>%q:%q", l.Source, l.Target, l.Label))
+	}
+	// The collapsed link comes back as the three edges it merged, without
+	// the ×3 added to its label
+	first := fmt.Sprintf("%q>%q:%q", `say "hi"`, `C:\dir\`, "calls")
+	want := []string{first, first, first,
+		fmt.Sprintf("%q>%q:%q", `C:\dir\`, "two\nlines", `a\b`),
+		fmt.Sprintf("%q>%q:%q", "two\nlines", "node", ""),
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("expected links\n%v\ngot\n%v\nfrom\n%s", want, got, out)
+	}
+}
+
+func TestRenderImageFormat(t *testing.T) {
+	d3g := &Graph{Nodes: []Node{{ID: "A"}}, Directed: true}
+
+	html, err := RenderHTML(d3g, RenderOptions{ShowExport: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+	for _, want := range []string{
+		`const imageType = "image/png";`,
+		`>Copy as image</button>`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected %s by default", want)
+		}
+	}
+
+	if data := templateData(t, d3g, RenderOptions{ShowExport: true}); data["ImageQuality"] != 0.0 {
+		t.Errorf("expected the browser's default quality, got %v", data["ImageQuality"])
+	}
+	if data := templateData(t, d3g, RenderOptions{ShowExport: true, ImageFormat: "webp", ImageQuality: 0.75}); data["ImageQuality"] != 0.75 {
+		t.Errorf("expected quality 0.75, got %v", data["ImageQuality"])
+	}
+	if !contains(htmlTemplate, "const imageQuality = {{.ImageQuality}} || undefined;") {
+		t.Error("expected the page to read the image quality")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{ShowExport: true, ImageFormat: "webp", ImageQuality: 0.75})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr = string(html)
+	for _, want := range []string{
+		`const imageType = "image/webp";`,
+		`"." + "webp";`,
+		`>Download as webp</button>`,
+		`canvas.toBlob(blob => blob ? resolve(blob) : reject(new Error(imageType + " encoding failed")), imageType, imageQuality);`,
+	} {
+		if !contains(htmlStr, want) {
+			t.Errorf("expected %s", want)
+		}
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{ShowExport: true, ImageFormat: "jpeg"})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), `"." + "jpg";`) {
+		t.Error("expected jpeg exports to be named .jpg")
+	}
+
+	for _, opts := range []RenderOptions{
+		{ImageFormat: "gif"},
+		{ImageFormat: "jpeg", ImageQuality: 1.5},
+		{ImageQuality: -0.1},
+		{ImageFormat: "webp", ImageQuality: math.NaN()},
+		{ImageFormat: "jpeg", ImageQuality: math.Inf(1)},
+	} {
+		if _, err := RenderHTML(d3g, opts); err == nil {
+			t.Errorf("expected an error for %q at quality %v", opts.ImageFormat, opts.ImageQuality)
+		}
+	}
+}
+
+func TestRenderExtraCSS(t *testing.T) {
+	d3g := &Graph{Nodes: []Node{{ID: "A"}}}
+	css := `.node-label { font-family: "Fira Sans", sans-serif; } body > svg { background: #fafafa; }`
+
+	html, err := RenderHTML(d3g, RenderOptions{ExtraCSS: css})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	i := strings.Index(htmlStr, css)
+	if i < 0 {
+		t.Fatalf("expected the extra CSS verbatim in the output")
+	}
+	if end := strings.Index(htmlStr, "</style>"); i > end {
+		t.Error("expected the extra CSS inside the <style> block")
+	}
+	if last := strings.LastIndex(htmlStr[:i], ".cluster-label {"); last < 0 {
+		t.Error("expected the extra CSS after the default rules")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), "RenderOptions.ExtraCSS") {
+		t.Error("expected no extra CSS section by default")
+	}
+}
+
+func TestRenderExtraJS(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A"}, {ID: "B"}},
+		Links: []Link{{Source: "A", Target: "B"}},
+	}
+	js := `const clicks = []; document.addEventListener("nodeClick", e => clicks.push(e.detail.id && graphData.nodes.length));`
+
+	html, err := RenderHTML(d3g, RenderOptions{ExtraJS: js})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	i := strings.Index(htmlStr, js)
+	if i < 0 {
+		t.Fatalf("expected the extra JS verbatim in the output")
+	}
+	for _, init := range []string{"const simulation = d3.forceSimulation", "let node = setupNodes(", "if (staticLayout || gridLayout || circularLayout)"} {
+		if j := strings.Index(htmlStr, init); j < 0 || j > i {
+			t.Errorf("expected the extra JS after %q", init)
+		}
+	}
+	if end := strings.Index(htmlStr[i:], "</script>"); end < 0 || strings.Contains(htmlStr[i:i+end], "function ") {
+		t.Error("expected the extra JS at the end of the page script")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), "RenderOptions.ExtraJS") {
+		t.Error("expected no extra JS section by default")
+	}
+}
+
+func TestRenderCustomTemplate(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{{ID: "A"}, {ID: "B"}},
+		Links: []Link{{Source: "A", Target: "B"}},
+	}
+
+	tmpl := `<html><head><title>{{.Title}}</title></head><body><header>ACME</header>` +
+		`<script>const data = {{.GraphJSON}}; const title = {{json .Title}};</script></body></html>`
+	html, err := RenderHTML(d3g, RenderOptions{Title: "Deps", Template: tmpl})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !strings.HasPrefix(htmlStr, "<html><head><title>Deps</title>") || !contains(htmlStr, "<header>ACME</header>") {
+		t.Errorf("expected custom template output, got %s", htmlStr)
+	}
+	if !contains(htmlStr, `const data = {"nodes":[{"id":"A"`) {
+		t.Errorf("expected graph JSON injected, got %s", htmlStr)
+	}
+	if !contains(htmlStr, `const title = "Deps";`) {
+		t.Errorf("expected json helper output, got %s", htmlStr)
+	}
+	if contains(htmlStr, "d3.forceSimulation") {
+		t.Error("expected default template not to be used")
+	}
+
+	if _, err := RenderHTML(d3g, RenderOptions{Template: "{{.Missing"}); err == nil {
+		t.Error("expected error for malformed template")
+	}
+}
+
+func TestColorByAttribute(t *testing.T) {
+	d3g, err := Convert(parse(t, `digraph { A [weight=2.5]; B [weight=-1]; C [weight=heavy]; D }`))
+	if err != nil {
+		t.Fatalf("convert error: %v", err)
+	}
+
+	r := attributeRange(d3g, "weight")
+	if r == nil || r.Attribute != "weight" || r.Min != -1 || r.Max != 2.5 {
+		t.Fatalf("expected weight range [-1, 2.5], got %+v", r)
+	}
+	if attributeRange(d3g, "score") != nil {
+		t.Error("expected no range for an absent attribute")
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{ColorByAttribute: "weight"})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	if !contains(htmlStr, `const colorBy = { attribute: "weight", min:  -1 , max:  2.5  };`) {
+		t.Error("expected color-by attribute and range in template data")
+	}
+	if !contains(htmlStr, `id="color-legend"`) {
+		t.Error("expected color legend")
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if !contains(string(html), "const colorBy = null;") || contains(string(html), `id="color-legend"`) {
+		t.Error("expected no color-by scale or legend by default")
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	d3g := &Graph{
+		Nodes: []Node{
+			{ID: "A", Label: "Node A", Color: "red"},
+		},
+		Links:    []Link{},
+		Directed: true,
+	}
+
+	jsonBytes, err := json.Marshal(d3g)
+	if err != nil {
+		t.Fatalf("json error: %v", err)
+	}
+
+	// Parse it back
+	var parsed Graph
+	if err := json.Unmarshal(jsonBytes, &parsed); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if len(parsed.Nodes) != 1 {
+		t.Errorf("expected 1 node, got %d", len(parsed.Nodes))
+	}
+
+	if parsed.Nodes[0].Label != "Node A" {
+		t.Errorf("expected label 'Node A', got %s", parsed.Nodes[0].Label)
+	}
+}
+
+func TestRenderEmbedJSON(t *testing.T) {
+	d3g := &Graph{
+		Nodes:    []Node{{ID: "A", Label: "</script><b>A</b>"}, {ID: "B"}},
+		Links:    []Link{{Source: "A", Target: "B", Label: "a & b"}},
+		Directed: true,
+	}
+
+	html, err := RenderHTML(d3g, RenderOptions{EmbedJSON: true})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	htmlStr := string(html)
+
+	const open = `<script type="application/json" id="graph-data">`
+	start := strings.Index(htmlStr, open)
+	if start < 0 {
+		t.Fatal("expected an embedded graph-data block")
+	}
+	body := htmlStr[start+len(open):]
+	end := strings.Index(body, "</script>")
+	if end < 0 {
+		t.Fatal("expected the graph-data block to be closed")
+	}
+
+	var got Graph
+	if err := json.Unmarshal([]byte(body[:end]), &got); err != nil {
+		t.Fatalf("embedded JSON does not parse: %v", err)
+	}
+	if len(got.Nodes) != 2 || got.Nodes[0].Label != "</script><b>A</b>" {
+		t.Errorf("expected the embedded nodes to round-trip, got %+v", got.Nodes)
+	}
+	if len(got.Links) != 1 || got.Links[0].Label != "a & b" || !got.Directed {
+		t.Errorf("expected the embedded links to round-trip, got %+v", got.Links)
+	}
+
+	html, err = RenderHTML(d3g, RenderOptions{})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+	if contains(string(html), `id="graph-data"`) {
+		t.Error("expected no embedded JSON unless enabled")
 	}
 }
 
@@ -0,0 +1,421 @@
+package dot
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/token"
+)
+
+// Canonicalize returns a normalized DOT rendering of graph, for comparing
+// and deduplicating graphs: graphs that describe the same nodes, edges,
+// attributes and subgraphs canonicalize to identical bytes however their
+// statements are ordered, split, or quoted.
+//
+// Attribute defaults are resolved onto the nodes and edges they apply to,
+// node:port endpoints become tailport and headport, and attributes set to
+// "" are dropped. Nodes, edges, subgraphs and attributes are sorted, IDs
+// are quoted only where needed, and the ends of undirected edges are put
+// in order. Anonymous subgraphs without attributes only scope defaults, so
+// they are left out; strict graphs merge repeated edges.
+func Canonicalize(graph *ast.Graph) ([]byte, error) {
+	if graph == nil {
+		return nil, errors.New("canonicalize: nil graph")
+	}
+
+	c := &canonicalizer{
+		strict: graph.Strict,
+		nodes:  make(map[string]map[string]string),
+		edges:  make(map[string]int),
+	}
+	c.root = &canonicalSubgraph{attrs: make(map[string]string), nodes: make(map[string]bool)}
+	c.statements(graph.Statements, canonicalScope{
+		nodeDefaults: make(map[string]string),
+		edgeDefaults: make(map[string]string),
+	}, []*canonicalSubgraph{c.root})
+
+	var sb strings.Builder
+	if graph.Strict {
+		sb.WriteString("strict ")
+	}
+	if graph.Directed {
+		sb.WriteString("digraph")
+	} else {
+		sb.WriteString("graph")
+	}
+	if graph.ID != nil {
+		sb.WriteString(" " + canonicalID(graph.ID.Name, graph.ID.HTML))
+	}
+	sb.WriteString(" {\n")
+
+	writeAttrLines(&sb, c.root.attrs, 1)
+	for _, sg := range c.root.sortedChildren() {
+		sb.WriteString(sg.text(1))
+	}
+
+	ids := make([]string, 0, len(c.nodes))
+	for id := range c.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sb.WriteString("    " + canonicalID(id, false) + canonicalAttrs(c.nodes[id]) + "\n")
+	}
+
+	sort.Slice(c.edgeList, func(i, j int) bool {
+		a, b := c.edgeList[i], c.edgeList[j]
+		if a.source != b.source {
+			return a.source < b.source
+		}
+		if a.target != b.target {
+			return a.target < b.target
+		}
+		return a.text() < b.text()
+	})
+	for _, e := range c.edgeList {
+		sb.WriteString("    " + e.text() + "\n")
+	}
+
+	sb.WriteString("}\n")
+	return []byte(sb.String()), nil
+}
+
+// canonicalizer resolves a graph's statements into its nodes, edges and
+// subgraphs with their effective attributes.
+type canonicalizer struct {
+	strict   bool
+	root     *canonicalSubgraph
+	nodes    map[string]map[string]string // node ID to attributes
+	edgeList []*canonicalEdge
+	edges    map[string]int // strict graphs: index in edgeList by endpoints
+}
+
+// canonicalScope holds the node and edge defaults of one brace-delimited
+// scope.
+type canonicalScope struct {
+	nodeDefaults map[string]string
+	edgeDefaults map[string]string
+}
+
+func (s canonicalScope) enter() canonicalScope {
+	return canonicalScope{
+		nodeDefaults: maps.Clone(s.nodeDefaults),
+		edgeDefaults: maps.Clone(s.edgeDefaults),
+	}
+}
+
+// canonicalSubgraph is a subgraph with the nodes declared or used in it,
+// directly or in its own subgraphs.
+type canonicalSubgraph struct {
+	id       string // canonical ID; "" when anonymous
+	attrs    map[string]string
+	nodes    map[string]bool
+	children []*canonicalSubgraph
+	named    map[string]*canonicalSubgraph // children by ID, so reopening one adds to it
+}
+
+type canonicalEdge struct {
+	source, target string
+	op             string
+	attrs          map[string]string
+}
+
+func (e *canonicalEdge) text() string {
+	return canonicalID(e.source, false) + " " + e.op + " " + canonicalID(e.target, false) + canonicalAttrs(e.attrs)
+}
+
+// statements processes stmts in scope; path is the chain of subgraphs
+// from the root to the one containing stmts.
+func (c *canonicalizer) statements(stmts []ast.Statement, scope canonicalScope, path []*canonicalSubgraph) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.AttrStmt:
+			switch s.Kind {
+			case ast.GraphAttr:
+				applyCanonicalAttrs(path[len(path)-1].attrs, s.Attrs)
+			case ast.NodeAttr:
+				applyCanonicalAttrs(scope.nodeDefaults, s.Attrs)
+			case ast.EdgeAttr:
+				applyCanonicalAttrs(scope.edgeDefaults, s.Attrs)
+			}
+		case *ast.AttrAssign:
+			setCanonicalAttr(path[len(path)-1].attrs, s.Key, s.Value)
+		case *ast.NodeStmt:
+			id := s.NodeID.ID.Name
+			c.node(id, scope, path)
+			applyCanonicalAttrs(c.nodes[id], s.Attrs)
+		case *ast.EdgeStmt:
+			c.edgeStmt(s, scope, path)
+		case *ast.Subgraph:
+			c.subgraph(s, scope, path)
+		}
+	}
+}
+
+// node creates the node with the defaults in scope if it is new, and adds
+// it to every subgraph on path.
+func (c *canonicalizer) node(id string, scope canonicalScope, path []*canonicalSubgraph) {
+	if _, ok := c.nodes[id]; !ok {
+		c.nodes[id] = maps.Clone(scope.nodeDefaults)
+	}
+	for _, sg := range path {
+		sg.nodes[id] = true
+	}
+}
+
+// subgraph processes a subgraph statement or endpoint and returns its
+// node IDs, sorted.
+func (c *canonicalizer) subgraph(s *ast.Subgraph, scope canonicalScope, path []*canonicalSubgraph) []string {
+	parent := path[len(path)-1]
+	var sg *canonicalSubgraph
+	if s.ID != nil {
+		id := canonicalID(s.ID.Name, s.ID.HTML)
+		sg = parent.named[id]
+		if sg == nil {
+			sg = &canonicalSubgraph{id: id, attrs: make(map[string]string), nodes: make(map[string]bool)}
+			if parent.named == nil {
+				parent.named = make(map[string]*canonicalSubgraph)
+			}
+			parent.named[id] = sg
+			parent.children = append(parent.children, sg)
+		}
+	} else {
+		sg = &canonicalSubgraph{attrs: make(map[string]string), nodes: make(map[string]bool)}
+		parent.children = append(parent.children, sg)
+	}
+
+	c.statements(s.Statements, scope.enter(), append(path[:len(path):len(path)], sg))
+
+	ids := make([]string, 0, len(sg.nodes))
+	for id := range sg.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (c *canonicalizer) edgeStmt(s *ast.EdgeStmt, scope canonicalScope, path []*canonicalSubgraph) {
+	left, leftPort := c.endpoint(s.Left, scope, path)
+	for _, right := range s.Rights {
+		rightIDs, rightPort := c.endpoint(right.Endpoint, scope, path)
+		op := "--"
+		if right.Directed {
+			op = "->"
+		}
+		for _, source := range left {
+			for _, target := range rightIDs {
+				attrs := maps.Clone(scope.edgeDefaults)
+				if leftPort != "" {
+					attrs["tailport"] = leftPort
+				}
+				if rightPort != "" {
+					attrs["headport"] = rightPort
+				}
+				applyCanonicalAttrs(attrs, s.Attrs)
+				c.edge(&canonicalEdge{source: source, target: target, op: op, attrs: attrs})
+			}
+		}
+		left, leftPort = rightIDs, rightPort
+	}
+}
+
+// endpoint returns the node IDs an edge endpoint stands for and, for a
+// single node, its canonical port.
+func (c *canonicalizer) endpoint(ep ast.EdgeEndpoint, scope canonicalScope, path []*canonicalSubgraph) ([]string, string) {
+	switch e := ep.(type) {
+	case *ast.NodeID:
+		c.node(e.ID.Name, scope, path)
+		return []string{e.ID.Name}, canonicalPort(e.Port)
+	case *ast.NodeGroup:
+		var ids []string
+		for _, n := range e.Nodes {
+			c.node(n.ID.Name, scope, path)
+			ids = append(ids, n.ID.Name)
+		}
+		return ids, ""
+	case *ast.Subgraph:
+		return c.subgraph(e, scope, path), ""
+	}
+	return nil, ""
+}
+
+// edge adds e, putting an undirected edge's ends in order and, in strict
+// graphs, merging it into an earlier edge between the same nodes.
+func (c *canonicalizer) edge(e *canonicalEdge) {
+	if e.op == "--" && e.target < e.source {
+		e.source, e.target = e.target, e.source
+		tail, hasTail := e.attrs["tailport"]
+		head, hasHead := e.attrs["headport"]
+		delete(e.attrs, "tailport")
+		delete(e.attrs, "headport")
+		if hasHead {
+			e.attrs["tailport"] = head
+		}
+		if hasTail {
+			e.attrs["headport"] = tail
+		}
+	}
+	if c.strict {
+		key := e.source + "\x00" + e.target
+		if i, ok := c.edges[key]; ok {
+			maps.Copy(c.edgeList[i].attrs, e.attrs)
+			return
+		}
+		c.edges[key] = len(c.edgeList)
+	}
+	c.edgeList = append(c.edgeList, e)
+}
+
+// sortedChildren returns the subgraphs worth writing: named ones, and
+// anonymous ones with attributes or such subgraphs of their own, ordered
+// by their text.
+func (sg *canonicalSubgraph) sortedChildren() []*canonicalSubgraph {
+	var kept []*canonicalSubgraph
+	for _, child := range sg.children {
+		if child.id != "" || len(child.attrs) > 0 || len(child.sortedChildren()) > 0 {
+			kept = append(kept, child)
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool {
+		return kept[i].text(0) < kept[j].text(0)
+	})
+	return kept
+}
+
+// text writes the subgraph at the given indentation level.
+func (sg *canonicalSubgraph) text(level int) string {
+	indent := strings.Repeat("    ", level)
+	var sb strings.Builder
+	sb.WriteString(indent + "subgraph ")
+	if sg.id != "" {
+		sb.WriteString(sg.id + " ")
+	}
+	sb.WriteString("{\n")
+	writeAttrLines(&sb, sg.attrs, level+1)
+	for _, child := range sg.sortedChildren() {
+		sb.WriteString(child.text(level + 1))
+	}
+	ids := make([]string, 0, len(sg.nodes))
+	for id := range sg.nodes {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		sb.WriteString(indent + "    " + canonicalID(id, false) + "\n")
+	}
+	sb.WriteString(indent + "}\n")
+	return sb.String()
+}
+
+func applyCanonicalAttrs(attrs map[string]string, list *ast.AttrList) {
+	if list == nil {
+		return
+	}
+	for _, a := range list.Attrs {
+		setCanonicalAttr(attrs, a.Key, a.Value)
+	}
+}
+
+// setCanonicalAttr stores an attribute's canonical value text; an empty
+// value unsets it.
+func setCanonicalAttr(attrs map[string]string, key, value *ast.Ident) {
+	if key == nil {
+		return
+	}
+	if value == nil || (value.Name == "" && !value.HTML) {
+		delete(attrs, key.Name)
+		return
+	}
+	attrs[key.Name] = canonicalID(value.Name, value.HTML)
+}
+
+// writeAttrLines writes graph attributes, one key=value line each.
+func writeAttrLines(sb *strings.Builder, attrs map[string]string, level int) {
+	indent := strings.Repeat("    ", level)
+	for _, k := range sortedKeys(attrs) {
+		sb.WriteString(indent + canonicalID(k, false) + "=" + attrs[k] + "\n")
+	}
+}
+
+// canonicalAttrs formats node or edge attributes as a sorted list, or ""
+// when there are none.
+func canonicalAttrs(attrs map[string]string) string {
+	if len(attrs) == 0 {
+		return ""
+	}
+	list := make([]string, 0, len(attrs))
+	for _, k := range sortedKeys(attrs) {
+		list = append(list, canonicalID(k, false)+"="+attrs[k])
+	}
+	return " [" + strings.Join(list, ", ") + "]"
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// canonicalPort formats a node:port[:compass] suffix as a port value.
+func canonicalPort(p *ast.Port) string {
+	if p == nil || p.ID == nil {
+		return ""
+	}
+	port := p.ID.Name
+	if p.Compass != nil {
+		port += ":" + p.Compass.Name
+	}
+	return canonicalID(port, false)
+}
+
+var (
+	plainID   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	numeralID = regexp.MustCompile(`^-?(\.[0-9]+|[0-9]+(\.[0-9]*)?)$`)
+)
+
+// canonicalID writes an ID plainly when the lexer would read it back as
+// the same identifier or numeral, and quoted otherwise.
+func canonicalID(name string, html bool) string {
+	if html {
+		return "<" + name + ">"
+	}
+	if plainID.MatchString(name) && token.Lookup(strings.ToLower(name)) == token.IDENT {
+		return name
+	}
+	if numeralID.MatchString(name) {
+		return name
+	}
+
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i := 0; i < len(name); i++ {
+		switch ch := name[i]; ch {
+		case '"':
+			sb.WriteString(`\"`)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		case '\\':
+			// Label escapes like \N are kept as the lexer keeps them
+			if i+1 < len(name) && strings.IndexByte("NGTH", name[i+1]) >= 0 {
+				sb.WriteByte('\\')
+			} else {
+				sb.WriteString(`\\`)
+			}
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
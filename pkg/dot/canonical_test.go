@@ -0,0 +1,135 @@
+package dot
+
+import "testing"
+
+func canonicalize(t *testing.T, src string) string {
+	t.Helper()
+	g, err := Parse("test", []byte(src))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	out, err := Canonicalize(g)
+	if err != nil {
+		t.Fatalf("canonicalize error: %v", err)
+	}
+	return string(out)
+}
+
+func TestCanonicalize(t *testing.T) {
+	got := canonicalize(t, `digraph G {
+		rankdir=LR
+		node [shape=box]
+		subgraph cluster_b { B }
+		B -> "A" [label="two words", color=""]
+		A [label="say \"hi\""]
+		subgraph cluster_a { label=First; A }
+		"node" -> B
+	}`)
+
+	want := `digraph G {
+    rankdir=LR
+    subgraph cluster_a {
+        label=First
+        A
+    }
+    subgraph cluster_b {
+        B
+    }
+    A [label="say \"hi\"", shape=box]
+    B [shape=box]
+    "node" [shape=box]
+    B -> A [label="two words"]
+    "node" -> B
+}
+`
+	if got != want {
+		t.Errorf("unexpected canonical form:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The canonical form is its own canonical form
+	if again := canonicalize(t, got); again != got {
+		t.Errorf("expected canonicalizing twice to be stable, got:\n%s", again)
+	}
+}
+
+func TestCanonicalizeEquivalent(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{
+			"statement order and quoting",
+			`digraph G { A -> B; B -> C [color=red]; A [label="Start"] }`,
+			`digraph "G" { A [label=Start]; B -> C [color="red"]; A -> B }`,
+		},
+		{
+			"node defaults",
+			`digraph { node [shape=box]; A; B }`,
+			`digraph { B [shape=box]; A [shape="box"] }`,
+		},
+		{
+			"edge defaults in a subgraph",
+			`digraph { { edge [color=red]; A -> B } B -> C }`,
+			`digraph { B -> C; A -> B [color=red] }`,
+		},
+		{
+			"node groups and chains",
+			`digraph { A -> {B C} -> D }`,
+			`digraph { C -> D; A -> C; B -> D; A -> B }`,
+		},
+		{
+			"undirected edge ends",
+			`graph { A:p -- B }`,
+			`graph { B -- A [headport=p] }`,
+		},
+		{
+			"graph attributes",
+			`digraph { rankdir=LR; bgcolor=white }`,
+			`digraph { graph [bgcolor="white", rankdir="LR"] }`,
+		},
+		{
+			"subgraphs",
+			`digraph { subgraph cluster_a { label=X; A } subgraph cluster_b { B } A -> B }`,
+			`digraph { A -> B; subgraph cluster_b { B }; subgraph cluster_a { A }; subgraph cluster_a { graph [label="X"] } }`,
+		},
+		{
+			"strict edges merge",
+			`strict digraph { A -> B; A -> B [color=red] }`,
+			`strict digraph { A -> B [color=red] }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := canonicalize(t, tt.a), canonicalize(t, tt.b)
+			if a != b {
+				t.Errorf("expected identical canonical forms, got:\n%s\nand:\n%s", a, b)
+			}
+		})
+	}
+}
+
+func TestCanonicalizeDifferent(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+	}{
+		{"attribute value", `digraph { A [color=red] }`, `digraph { A [color=blue] }`},
+		{"edge direction", `digraph { A -> B }`, `digraph { B -> A }`},
+		{"graph type", `digraph { A -> B }`, `graph { A -- B }`},
+		{"strict", `strict digraph { A -> B }`, `digraph { A -> B }`},
+		{"repeated edges", `digraph { A -> B; A -> B }`, `digraph { A -> B }`},
+		{"defaults after creation", `digraph { A; node [shape=box] }`, `digraph { node [shape=box]; A }`},
+		{"cluster membership", `digraph { subgraph cluster_a { A } B }`, `digraph { subgraph cluster_a { B } A }`},
+		{"html and quoted strings", `digraph { A [label=<b>] }`, `digraph { A [label="b"] }`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := canonicalize(t, tt.a), canonicalize(t, tt.b)
+			if a == b {
+				t.Errorf("expected different canonical forms, both got:\n%s", a)
+			}
+		})
+	}
+}
@@ -3,12 +3,16 @@
 package dot
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
+	"time"
 
 	"github.com/anthonybishopric/dot2d3/pkg/ast"
 	"github.com/anthonybishopric/dot2d3/pkg/d3"
 	"github.com/anthonybishopric/dot2d3/pkg/lexer"
 	"github.com/anthonybishopric/dot2d3/pkg/parser"
+	"github.com/anthonybishopric/dot2d3/pkg/token"
 )
 
 // Parse parses DOT source code and returns the AST.
@@ -18,11 +22,51 @@ func Parse(filename string, src []byte) (*ast.Graph, error) {
 	return p.Parse()
 }
 
+// ParseContext is like Parse, but stops with ctx's error once ctx is done,
+// for callers bounding how long parsing may take.
+func ParseContext(ctx context.Context, filename string, src []byte) (*ast.Graph, error) {
+	l := lexer.New(filename, src)
+	p := parser.New(l)
+	p.SetContext(ctx)
+	return p.Parse()
+}
+
+// ParseFile parses DOT source holding one or more graphs and returns their
+// ASTs in order.
+func ParseFile(filename string, src []byte) ([]*ast.Graph, error) {
+	l := lexer.New(filename, src)
+	p := parser.New(l)
+	return p.ParseFile()
+}
+
+// ParsePartial parses DOT source up to the first error and returns the
+// partial AST along with the position where parsing stopped.
+// See parser.Parser.ParsePartial.
+func ParsePartial(filename string, src []byte) (*ast.Graph, token.Position, error) {
+	l := lexer.New(filename, src)
+	p := parser.New(l)
+	return p.ParsePartial()
+}
+
+// ParseFragment parses a bare DOT statement list, without the enclosing
+// graph header and braces.
+func ParseFragment(filename string, src []byte) ([]ast.Statement, error) {
+	l := lexer.New(filename, src)
+	p := parser.New(l)
+	return p.ParseFragment()
+}
+
 // ToD3Graph converts an AST graph to a D3-compatible graph structure.
 func ToD3Graph(graph *ast.Graph) (*d3.Graph, error) {
 	return d3.Convert(graph)
 }
 
+// ToD3GraphContext is like ToD3Graph, but stops with ctx's error once ctx
+// is done.
+func ToD3GraphContext(ctx context.Context, graph *ast.Graph) (*d3.Graph, error) {
+	return d3.ConvertContext(ctx, graph, d3.ConvertOptions{})
+}
+
 // ToJSON generates JSON output for D3 visualization.
 func ToJSON(graph *ast.Graph) ([]byte, error) {
 	d3g, err := ToD3Graph(graph)
@@ -32,6 +76,145 @@ func ToJSON(graph *ast.Graph) ([]byte, error) {
 	return json.MarshalIndent(d3g, "", "  ")
 }
 
+// ConvertOptions configures conversion. See d3.ConvertOptions.
+type ConvertOptions = d3.ConvertOptions
+
+// ToJSONWithOptions is like ToJSON, converting with opts; set opts.Meta to
+// include graph statistics under "meta".
+func ToJSONWithOptions(graph *ast.Graph, opts ConvertOptions) ([]byte, error) {
+	d3g, err := d3.ConvertWithOptions(graph, opts)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(d3g, "", "  ")
+}
+
+// ToJSONFormat generates JSON output in the given format:
+//
+//   - "d3" (or ""): the D3 force-graph shape produced by ToJSON
+//   - "cytoscape": {"elements": {"nodes": [{"data": {...}}], "edges": [{"data": {...}}]}}
+//   - "adjacency": a map of node ID to the IDs of its neighbors
+//   - "matrix": {"nodes": [...], "matrix": [[...]]}, edge counts between nodes
+func ToJSONFormat(graph *ast.Graph, format string) ([]byte, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case "", "d3":
+		return json.MarshalIndent(d3g, "", "  ")
+	case "cytoscape":
+		return json.MarshalIndent(toCytoscape(d3g), "", "  ")
+	case "adjacency":
+		return json.MarshalIndent(toAdjacency(d3g), "", "  ")
+	case "matrix":
+		return json.MarshalIndent(d3g.AdjacencyMatrix(), "", "  ")
+	default:
+		return nil, fmt.Errorf("unknown JSON format %q", format)
+	}
+}
+
+type cytoscapeGraph struct {
+	Elements cytoscapeElements `json:"elements"`
+}
+
+type cytoscapeElements struct {
+	Nodes []cytoscapeElement `json:"nodes"`
+	Edges []cytoscapeElement `json:"edges"`
+}
+
+type cytoscapeElement struct {
+	Data map[string]string `json:"data"`
+}
+
+func toCytoscape(g *d3.Graph) cytoscapeGraph {
+	out := cytoscapeGraph{
+		Elements: cytoscapeElements{
+			Nodes: make([]cytoscapeElement, 0, len(g.Nodes)),
+			Edges: make([]cytoscapeElement, 0, len(g.Links)),
+		},
+	}
+
+	for _, n := range g.Nodes {
+		data := map[string]string{"id": n.ID}
+		if n.Label != "" {
+			data["label"] = n.Label
+		}
+		out.Elements.Nodes = append(out.Elements.Nodes, cytoscapeElement{Data: data})
+	}
+
+	for i, l := range g.Links {
+		data := map[string]string{
+			"id":     fmt.Sprintf("e%d", i),
+			"source": l.Source,
+			"target": l.Target,
+		}
+		if l.Label != "" {
+			data["label"] = l.Label
+		}
+		out.Elements.Edges = append(out.Elements.Edges, cytoscapeElement{Data: data})
+	}
+
+	return out
+}
+
+func toAdjacency(g *d3.Graph) map[string][]string {
+	adj := make(map[string][]string, len(g.Nodes))
+	for _, n := range g.Nodes {
+		adj[n.ID] = []string{}
+	}
+	for _, l := range g.Links {
+		adj[l.Source] = append(adj[l.Source], l.Target)
+		// Undirected edges are traversable from both ends
+		if !g.Directed && l.Source != l.Target {
+			adj[l.Target] = append(adj[l.Target], l.Source)
+		}
+	}
+	return adj
+}
+
+// DegreeCentrality returns the number of edges incident to each node.
+// See d3.Graph.DegreeCentrality.
+func DegreeCentrality(graph *ast.Graph) (map[string]int, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+	return d3g.DegreeCentrality(), nil
+}
+
+// BetweennessCentrality returns the betweenness centrality of each node.
+// See d3.Graph.BetweennessCentrality.
+func BetweennessCentrality(graph *ast.Graph) (map[string]float64, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+	return d3g.BetweennessCentrality(), nil
+}
+
+// StronglyConnectedComponents returns the graph's strongly connected
+// components. See d3.Graph.StronglyConnectedComponents.
+func StronglyConnectedComponents(graph *ast.Graph) ([][]string, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+	return d3g.StronglyConnectedComponents(), nil
+}
+
+// Reachable converts graph and prunes it to the nodes reachable from
+// roots and the edges among them; with directed set, only following edges
+// forward. See d3.Graph.Reachable.
+func Reachable(graph *ast.Graph, roots []string, directed bool) (*d3.Graph, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+	return d3g.Reachable(roots, directed)
+}
+
 // RenderOptions configures HTML rendering.
 type RenderOptions = d3.RenderOptions
 
@@ -57,6 +240,35 @@ func ToHTMLWithValidation(graph *ast.Graph, opts RenderOptions) ([]byte, *PathVa
 	return d3.RenderHTMLWithValidation(d3g, opts)
 }
 
+// RenderResult is the output of Render.
+type RenderResult struct {
+	HTML       []byte
+	Graph      *d3.Graph             // The converted graph, as rendered
+	Validation *PathValidationResult // nil unless opts.PathAST was set
+
+	ConvertDuration time.Duration
+	RenderDuration  time.Duration
+}
+
+// Render converts and renders graph in one call, returning the HTML along
+// with the converted graph, the path validation result and timings.
+func Render(graph *ast.Graph, opts RenderOptions) (*RenderResult, error) {
+	start := time.Now()
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+	result := &RenderResult{Graph: d3g, ConvertDuration: time.Since(start)}
+
+	start = time.Now()
+	result.HTML, result.Validation, err = d3.RenderHTMLWithValidation(d3g, opts)
+	if err != nil {
+		return nil, err
+	}
+	result.RenderDuration = time.Since(start)
+	return result, nil
+}
+
 // ParseAndRenderHTML is a convenience function that parses DOT and renders HTML.
 func ParseAndRenderHTML(filename string, src []byte, opts RenderOptions) ([]byte, error) {
 	graph, err := Parse(filename, src)
@@ -0,0 +1,184 @@
+package dot
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToJSONFormatD3(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph { A -> B -> C }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToJSONFormat(g, "d3")
+	if err != nil {
+		t.Fatalf("format error: %v", err)
+	}
+
+	var parsed struct {
+		Nodes []map[string]interface{} `json:"nodes"`
+		Links []map[string]interface{} `json:"links"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if len(parsed.Nodes) != 3 {
+		t.Errorf("expected 3 nodes, got %d", len(parsed.Nodes))
+	}
+	if len(parsed.Links) != 2 {
+		t.Errorf("expected 2 links, got %d", len(parsed.Links))
+	}
+}
+
+func TestToJSONFormatCytoscape(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph { A -> B -> C }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToJSONFormat(g, "cytoscape")
+	if err != nil {
+		t.Fatalf("format error: %v", err)
+	}
+
+	var parsed struct {
+		Elements struct {
+			Nodes []struct {
+				Data map[string]string `json:"data"`
+			} `json:"nodes"`
+			Edges []struct {
+				Data map[string]string `json:"data"`
+			} `json:"edges"`
+		} `json:"elements"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if len(parsed.Elements.Nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %d", len(parsed.Elements.Nodes))
+	}
+	for _, n := range parsed.Elements.Nodes {
+		if n.Data["id"] == "" {
+			t.Error("expected node data to have an id")
+		}
+	}
+
+	if len(parsed.Elements.Edges) != 2 {
+		t.Fatalf("expected 2 edges, got %d", len(parsed.Elements.Edges))
+	}
+	first := parsed.Elements.Edges[0].Data
+	if first["source"] != "A" || first["target"] != "B" {
+		t.Errorf("expected first edge A -> B, got %s -> %s", first["source"], first["target"])
+	}
+}
+
+func TestToJSONFormatAdjacency(t *testing.T) {
+	g, err := Parse("test", []byte(`graph { A -- B; A -- C }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToJSONFormat(g, "adjacency")
+	if err != nil {
+		t.Fatalf("format error: %v", err)
+	}
+
+	var adj map[string][]string
+	if err := json.Unmarshal(out, &adj); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if len(adj["A"]) != 2 {
+		t.Errorf("expected A to have 2 neighbors, got %v", adj["A"])
+	}
+	// Undirected edges appear on both endpoints
+	if len(adj["B"]) != 1 || adj["B"][0] != "A" {
+		t.Errorf("expected B neighbors [A], got %v", adj["B"])
+	}
+}
+
+func TestToJSONFormatUnknown(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph { A -> B }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	if _, err := ToJSONFormat(g, "graphml"); err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestCentrality(t *testing.T) {
+	g, err := Parse("test", []byte(`graph { A -- B -- C; B -- D }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	degree, err := DegreeCentrality(g)
+	if err != nil {
+		t.Fatalf("degree error: %v", err)
+	}
+	if degree["B"] != 3 || degree["A"] != 1 {
+		t.Errorf("expected B to have degree 3 and A degree 1, got %v", degree)
+	}
+
+	betweenness, err := BetweennessCentrality(g)
+	if err != nil {
+		t.Fatalf("betweenness error: %v", err)
+	}
+	for _, id := range []string{"A", "C", "D"} {
+		if betweenness[id] >= betweenness["B"] {
+			t.Errorf("expected B to be most central, got %v", betweenness)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph { A -> B -> C }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	path, err := Parse("path", []byte(`digraph { A -> B }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	result, err := Render(g, RenderOptions{PathAST: path})
+	if err != nil {
+		t.Fatalf("render error: %v", err)
+	}
+
+	if len(result.HTML) == 0 {
+		t.Error("expected HTML")
+	}
+	if result.Graph == nil || len(result.Graph.Nodes) != 3 || len(result.Graph.Links) != 2 {
+		t.Errorf("expected converted graph with 3 nodes and 2 links, got %+v", result.Graph)
+	}
+	if result.Validation == nil || !result.Validation.Valid {
+		t.Errorf("expected valid path result, got %+v", result.Validation)
+	}
+	if result.ConvertDuration <= 0 || result.RenderDuration <= 0 {
+		t.Errorf("expected timings, got convert %v, render %v", result.ConvertDuration, result.RenderDuration)
+	}
+}
+
+func TestReachable(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph { A -> B -> C; D -> A; X }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	pruned, err := Reachable(g, []string{"A"}, true)
+	if err != nil {
+		t.Fatalf("reachable error: %v", err)
+	}
+	if len(pruned.Nodes) != 3 || len(pruned.Links) != 2 {
+		t.Errorf("expected A, B and C with 2 edges, got %+v", pruned)
+	}
+
+	if _, err := Reachable(g, []string{"missing"}, true); err == nil {
+		t.Error("expected an error for an unknown root")
+	}
+}
@@ -0,0 +1,120 @@
+package dot
+
+import (
+	"encoding/xml"
+	"strconv"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/d3"
+)
+
+// graphMLKey is an attribute exported as GraphML <data>, declared once in
+// a <key> element.
+type graphMLKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+// graphMLNodeKeys and graphMLEdgeKeys are the DOT attributes exported for
+// nodes and edges. Key IDs are prefixed with what they apply to, since a
+// node and an edge key can't share an ID.
+var (
+	graphMLNodeKeys = []string{"label", "color", "fillcolor", "shape", "style", "class", "group"}
+	graphMLEdgeKeys = []string{"label", "color", "style", "class", "weight"}
+)
+
+type graphMLDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	XMLNS   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   graphMLGraph `xml:"graph"`
+}
+
+type graphMLGraph struct {
+	ID          string        `xml:"id,attr,omitempty"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphMLNode `xml:"node"`
+	Edges       []graphMLEdge `xml:"edge"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	ID       string        `xml:"id,attr"`
+	Source   string        `xml:"source,attr"`
+	Target   string        `xml:"target,attr"`
+	Directed string        `xml:"directed,attr,omitempty"`
+	Data     []graphMLData `xml:"data"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ToGraphML converts a graph to GraphML, for tools such as Gephi and yEd.
+//
+// The graph's type becomes the edgedefault, and edges whose operator
+// doesn't match it set their own directed attribute. Nodes are written in
+// source order with their label, colors, shape, style, class and group as
+// <data>; edges, with IDs e0, e1, ..., carry their label, color, style,
+// class and weight. Subgraphs and all other attributes are dropped.
+func ToGraphML(graph *ast.Graph) ([]byte, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := graphMLDoc{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Graph: graphMLGraph{ID: d3g.GraphID, EdgeDefault: "undirected"},
+	}
+	if d3g.Directed {
+		doc.Graph.EdgeDefault = "directed"
+	}
+	for _, name := range graphMLNodeKeys {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: "node_" + name, For: "node", Name: name, Type: "string"})
+	}
+	for _, name := range graphMLEdgeKeys {
+		doc.Keys = append(doc.Keys, graphMLKey{ID: "edge_" + name, For: "edge", Name: name, Type: "string"})
+	}
+
+	nodes := make(map[string]d3.Node, len(d3g.Nodes))
+	for _, n := range d3g.Nodes {
+		nodes[n.ID] = n
+	}
+	for _, id := range nodeOrder(graph, nodes) {
+		n := nodes[id]
+		gn := graphMLNode{ID: id}
+		for _, name := range graphMLNodeKeys {
+			if v := n.Attr(name); v != "" {
+				gn.Data = append(gn.Data, graphMLData{Key: "node_" + name, Value: v})
+			}
+		}
+		doc.Graph.Nodes = append(doc.Graph.Nodes, gn)
+	}
+
+	for i, l := range d3g.Links {
+		ge := graphMLEdge{ID: "e" + strconv.Itoa(i), Source: l.Source, Target: l.Target}
+		if directed := d3g.LinkDirected(l); directed != d3g.Directed {
+			ge.Directed = strconv.FormatBool(directed)
+		}
+		for _, name := range graphMLEdgeKeys {
+			if v := l.Attr(name); v != "" {
+				ge.Data = append(ge.Data, graphMLData{Key: "edge_" + name, Value: v})
+			}
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, ge)
+	}
+
+	out, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(out, '\n')...), nil
+}
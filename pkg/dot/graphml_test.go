@@ -0,0 +1,109 @@
+package dot
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestToGraphML(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph deps {
+		A [label="Start & go", color=red]
+		A -> B [label="<calls>", weight=2]
+		B -> C
+		C -- A
+		subgraph cluster_a { C }
+	}`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToGraphML(g)
+	if err != nil {
+		t.Fatalf("graphml error: %v", err)
+	}
+	if !strings.HasPrefix(string(out), `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("expected an XML declaration, got %.40q", out)
+	}
+
+	var doc graphMLDoc
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("expected well-formed XML: %v\n%s", err, out)
+	}
+	if doc.XMLName.Space != "http://graphml.graphdrawing.org/xmlns" {
+		t.Errorf("expected the GraphML namespace, got %q", doc.XMLName.Space)
+	}
+	if doc.Graph.ID != "deps" || doc.Graph.EdgeDefault != "directed" {
+		t.Errorf("expected graph deps with edgedefault directed, got %q %q", doc.Graph.ID, doc.Graph.EdgeDefault)
+	}
+	if len(doc.Graph.Nodes) != 3 || len(doc.Graph.Edges) != 3 {
+		t.Fatalf("expected 3 nodes and 3 edges, got %d and %d", len(doc.Graph.Nodes), len(doc.Graph.Edges))
+	}
+
+	data := func(items []graphMLData) map[string]string {
+		m := make(map[string]string)
+		for _, d := range items {
+			m[d.Key] = d.Value
+		}
+		return m
+	}
+	a := doc.Graph.Nodes[0]
+	if a.ID != "A" || data(a.Data)["node_label"] != "Start & go" || data(a.Data)["node_color"] != "red" {
+		t.Errorf("expected A with its label and color, got %+v", a)
+	}
+	// C's label and group are only the defaults from its ID and cluster
+	if c := doc.Graph.Nodes[2]; c.ID != "C" || len(c.Data) != 0 {
+		t.Errorf("expected C without data, got %+v", c)
+	}
+	e0 := doc.Graph.Edges[0]
+	if e0.ID != "e0" || e0.Source != "A" || e0.Target != "B" || e0.Directed != "" {
+		t.Errorf("expected e0 A -> B following the edgedefault, got %+v", e0)
+	}
+	if d := data(e0.Data); d["edge_label"] != "<calls>" || d["edge_weight"] != "2" {
+		t.Errorf("expected e0's label and weight, got %v", d)
+	}
+	if e2 := doc.Graph.Edges[2]; e2.Directed != "false" {
+		t.Errorf("expected the -- edge to be marked undirected, got %q", e2.Directed)
+	}
+
+	// Every data key is declared
+	keys := make(map[string]string)
+	for _, k := range doc.Keys {
+		keys[k.ID] = k.For
+	}
+	for _, n := range doc.Graph.Nodes {
+		for _, d := range n.Data {
+			if keys[d.Key] != "node" {
+				t.Errorf("node data key %q is not declared for nodes", d.Key)
+			}
+		}
+	}
+	for _, e := range doc.Graph.Edges {
+		for _, d := range e.Data {
+			if keys[d.Key] != "edge" {
+				t.Errorf("edge data key %q is not declared for edges", d.Key)
+			}
+		}
+	}
+}
+
+func TestToGraphMLUndirected(t *testing.T) {
+	g, err := Parse("test", []byte(`graph { A -- B; B -> C }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	out, err := ToGraphML(g)
+	if err != nil {
+		t.Fatalf("graphml error: %v", err)
+	}
+	var doc graphMLDoc
+	if err := xml.Unmarshal(out, &doc); err != nil {
+		t.Fatalf("expected well-formed XML: %v", err)
+	}
+	if doc.Graph.EdgeDefault != "undirected" || doc.Graph.ID != "" {
+		t.Errorf("expected an anonymous undirected graph, got %q %q", doc.Graph.ID, doc.Graph.EdgeDefault)
+	}
+	if doc.Graph.Edges[0].Directed != "" || doc.Graph.Edges[1].Directed != "true" {
+		t.Errorf("expected only the -> edge to be marked directed, got %+v", doc.Graph.Edges)
+	}
+}
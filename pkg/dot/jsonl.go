@@ -0,0 +1,45 @@
+package dot
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/d3"
+)
+
+// jsonlNode and jsonlEdge are the records written by WriteJSONL: the
+// converted node or edge with a "type" tag.
+type jsonlNode struct {
+	Type string `json:"type"`
+	d3.Node
+}
+
+type jsonlEdge struct {
+	Type string `json:"type"`
+	d3.Link
+}
+
+// WriteJSONL writes graph to w as JSON Lines: one object per line, each
+// node tagged {"type": "node", ...} and then each edge tagged
+// {"type": "edge", ...}, with the same fields as in ToJSON. Records are
+// written as they are encoded, for line-oriented tools and pipelines.
+func WriteJSONL(w io.Writer, graph *ast.Graph) error {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	for _, n := range d3g.Nodes {
+		if err := enc.Encode(jsonlNode{Type: "node", Node: n}); err != nil {
+			return err
+		}
+	}
+	for _, l := range d3g.Links {
+		if err := enc.Encode(jsonlEdge{Type: "edge", Link: l}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package dot
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestWriteJSONL(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph {
+		A [label="Start", color=red]
+		A -> B [label="go"]
+		B -> C
+		C -> A
+	}`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteJSONL(&buf, g); err != nil {
+		t.Fatalf("WriteJSONL error: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	counts := make(map[string]int)
+	sawEdge := false
+	for i, line := range lines {
+		var record map[string]any
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v\n%s", i+1, err, line)
+		}
+		typ, _ := record["type"].(string)
+		counts[typ]++
+
+		switch typ {
+		case "node":
+			if sawEdge {
+				t.Errorf("line %d: expected nodes before edges", i+1)
+			}
+			if record["id"] == "A" && (record["label"] != "Start" || record["color"] != "red") {
+				t.Errorf("expected A's attributes in its record, got %s", line)
+			}
+		case "edge":
+			sawEdge = true
+			if record["source"] == "A" && record["label"] != "go" {
+				t.Errorf("expected the edge label in its record, got %s", line)
+			}
+		default:
+			t.Errorf("line %d: unexpected type %q", i+1, typ)
+		}
+	}
+
+	if counts["node"] != 3 || counts["edge"] != 3 {
+		t.Errorf("expected 3 node and 3 edge records, got %v", counts)
+	}
+}
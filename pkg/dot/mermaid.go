@@ -0,0 +1,247 @@
+package dot
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/d3"
+)
+
+// ToMermaid converts a graph to Mermaid flowchart syntax.
+//
+// Nodes keep their labels and, where Mermaid has an equivalent, their
+// shapes; colors become style lines. Edges keep their labels, and dashed,
+// dotted and bold styles. Each node is drawn in the first subgraph it
+// appears in, and subgraphs are not nested. Ports, HTML label markup,
+// gradients, peripheries and all other attributes are dropped.
+func ToMermaid(graph *ast.Graph) ([]byte, error) {
+	d3g, err := ToD3Graph(graph)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "flowchart %s\n", mermaidDirection(d3g.Attributes["rankdir"]))
+
+	nodes := make(map[string]d3.Node, len(d3g.Nodes))
+	for _, n := range d3g.Nodes {
+		nodes[n.ID] = n
+	}
+	order := nodeOrder(graph, nodes)
+	ids := mermaidIDs(order)
+
+	// Nodes in source order, grouped into their subgraph's block
+	drawn := make(map[string]bool)
+	for _, sg := range d3g.Subgraphs {
+		var members []string
+		for _, id := range order {
+			if nodes[id].Group == sg.ID && !drawn[id] {
+				members = append(members, id)
+			}
+		}
+		if len(members) == 0 {
+			continue
+		}
+
+		title := sg.Label
+		if title == "" {
+			title = sg.ID
+		}
+		fmt.Fprintf(&buf, "    subgraph %s [\"%s\"]\n", mermaidSubgraphID(sg.ID), mermaidText(title))
+		for _, id := range members {
+			fmt.Fprintf(&buf, "        %s\n", mermaidNode(ids[id], nodes[id]))
+			drawn[id] = true
+		}
+		buf.WriteString("    end\n")
+	}
+	for _, id := range order {
+		if !drawn[id] {
+			fmt.Fprintf(&buf, "    %s\n", mermaidNode(ids[id], nodes[id]))
+		}
+	}
+
+	for _, l := range d3g.Links {
+		arrow := mermaidArrow(d3g.LinkDirected(l), l.Style)
+		if l.Label != "" {
+			arrow += "|" + strings.ReplaceAll(mermaidText(l.Label), "|", "#124;") + "|"
+		}
+		fmt.Fprintf(&buf, "    %s %s %s\n", ids[l.Source], arrow, ids[l.Target])
+	}
+
+	for _, id := range order {
+		if style := mermaidStyle(nodes[id]); style != "" {
+			fmt.Fprintf(&buf, "    style %s %s\n", ids[id], style)
+		}
+	}
+	for i, l := range d3g.Links {
+		if c := mermaidColor(l.Color); c != "" {
+			fmt.Fprintf(&buf, "    linkStyle %d stroke:%s\n", i, c)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// mermaidDirection maps a Graphviz rankdir onto a flowchart direction.
+func mermaidDirection(rankdir string) string {
+	switch strings.ToUpper(rankdir) {
+	case "LR", "RL", "BT":
+		return strings.ToUpper(rankdir)
+	default:
+		return "TD"
+	}
+}
+
+// nodeOrder returns node IDs in order of first appearance in the source,
+// since the converted graph's node order is arbitrary.
+func nodeOrder(graph *ast.Graph, nodes map[string]d3.Node) []string {
+	var order []string
+	seen := make(map[string]bool)
+	ast.Walk(graph, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.AttrList, *ast.AttrAssign:
+			return false
+		case *ast.NodeID:
+			id := n.ID.Name
+			if _, ok := nodes[id]; ok && !seen[id] {
+				seen[id] = true
+				order = append(order, id)
+			}
+			return false
+		}
+		return true
+	})
+	return order
+}
+
+var (
+	mermaidIdentRe  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	mermaidUnsafeRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// mermaidIDs returns a Mermaid-safe ID for every node: the DOT ID itself
+// when it is a plain identifier, otherwise a generated nN.
+func mermaidIDs(order []string) map[string]string {
+	ids := make(map[string]string, len(order))
+	used := make(map[string]bool)
+	for _, id := range order {
+		if mermaidIdentRe.MatchString(id) && !mermaidReserved(id) {
+			ids[id] = id
+			used[id] = true
+		}
+	}
+	next := 0
+	for _, id := range order {
+		if _, ok := ids[id]; ok {
+			continue
+		}
+		for used[fmt.Sprintf("n%d", next)] {
+			next++
+		}
+		ids[id] = fmt.Sprintf("n%d", next)
+		used[ids[id]] = true
+	}
+	return ids
+}
+
+// mermaidReserved reports whether id is a Mermaid keyword that breaks
+// flowchart parsing when used as a node ID.
+func mermaidReserved(id string) bool {
+	switch strings.ToLower(id) {
+	case "end", "subgraph", "graph", "flowchart", "style", "linkstyle", "classdef", "class", "click", "direction":
+		return true
+	}
+	return false
+}
+
+// mermaidSubgraphID makes a subgraph ID safe to use in a subgraph line.
+func mermaidSubgraphID(id string) string {
+	return mermaidUnsafeRe.ReplaceAllString(id, "_")
+}
+
+// mermaidShapes maps Graphviz shapes onto Mermaid node delimiters.
+var mermaidShapes = map[string][2]string{
+	"box":           {"[", "]"},
+	"rect":          {"[", "]"},
+	"rectangle":     {"[", "]"},
+	"square":        {"[", "]"},
+	"ellipse":       {"([", "])"},
+	"oval":          {"([", "])"},
+	"circle":        {"((", "))"},
+	"doublecircle":  {"(((", ")))"},
+	"diamond":       {"{", "}"},
+	"hexagon":       {"{{", "}}"},
+	"cylinder":      {"[(", ")]"},
+	"parallelogram": {"[/", "/]"},
+	"trapezium":     {"[/", "\\]"},
+	"invtrapezium":  {"[\\", "/]"},
+}
+
+// mermaidNode renders a node declaration: id["label"] in its shape.
+func mermaidNode(id string, n d3.Node) string {
+	shape, ok := mermaidShapes[strings.ToLower(n.Shape)]
+	if !ok {
+		shape = mermaidShapes["ellipse"] // Graphviz's default shape
+		if n.Shape != "" {
+			shape = mermaidShapes["box"]
+		}
+	}
+	label := n.Label
+	if label == "" {
+		label = n.ID
+	}
+	return id + shape[0] + `"` + mermaidText(label) + `"` + shape[1]
+}
+
+// mermaidArrow returns the link operator for an edge.
+func mermaidArrow(directed bool, style string) string {
+	switch {
+	case strings.Contains(style, "dashed"), strings.Contains(style, "dotted"):
+		if directed {
+			return "-.->"
+		}
+		return "-.-"
+	case strings.Contains(style, "bold"):
+		if directed {
+			return "==>"
+		}
+		return "==="
+	default:
+		if directed {
+			return "-->"
+		}
+		return "---"
+	}
+}
+
+// mermaidStyle returns a style line's properties for a node's colors.
+func mermaidStyle(n d3.Node) string {
+	var props []string
+	if c := mermaidColor(n.FillColor); c != "" {
+		props = append(props, "fill:"+c)
+	}
+	if c := mermaidColor(n.Color); c != "" {
+		props = append(props, "stroke:"+c)
+	}
+	if c := mermaidColor(n.FontColor); c != "" {
+		props = append(props, "color:"+c)
+	}
+	return strings.Join(props, ",")
+}
+
+// mermaidColor returns the first color of a Graphviz color list such as
+// "red;0.3:blue", since Mermaid styles take a single color.
+func mermaidColor(list string) string {
+	first, _, _ := strings.Cut(list, ":")
+	first, _, _ = strings.Cut(first, ";")
+	return d3.NormalizeColor(first)
+}
+
+// mermaidText escapes text for a quoted Mermaid label.
+func mermaidText(s string) string {
+	s = strings.ReplaceAll(s, `"`, "#quot;")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,84 @@
+package dot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToMermaid(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph {
+		subgraph cluster_db {
+			label="Storage"
+			db [shape=cylinder, label="Main \"DB\""]
+		}
+		api [shape=box, fillcolor=lightblue]
+		"web app" -> api [label="calls"]
+		api -> db [style=dashed, color=red]
+		end -> api
+	}`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToMermaid(g)
+	if err != nil {
+		t.Fatalf("mermaid error: %v", err)
+	}
+
+	want := `flowchart TD
+    subgraph cluster_db ["Storage"]
+        db[("Main #quot;DB#quot;")]
+    end
+    api["api"]
+    n0(["web app"])
+    n1(["end"])
+    n0 -->|calls| api
+    api -.-> db
+    n1 --> api
+    style api fill:#add8e6
+    linkStyle 1 stroke:#ff0000
+`
+	if string(out) != want {
+		t.Errorf("unexpected output:\n%s\nwant:\n%s", out, want)
+	}
+}
+
+func TestToMermaidUndirected(t *testing.T) {
+	g, err := Parse("test", []byte(`graph { rankdir=LR; A -- B [style=bold]; B -- C }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToMermaid(g)
+	if err != nil {
+		t.Fatalf("mermaid error: %v", err)
+	}
+	for _, want := range []string{"flowchart LR\n", "    A === B\n", "    B --- C\n"} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
+
+func TestToMermaidColorLists(t *testing.T) {
+	g, err := Parse("test", []byte(`digraph {
+		A [fillcolor="red;0.3:blue", color="green:yellow", fontcolor="white;0.5"]
+		A -> B [color="black:orange"]
+	}`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	out, err := ToMermaid(g)
+	if err != nil {
+		t.Fatalf("mermaid error: %v", err)
+	}
+	for _, want := range []string{
+		"    style A fill:#ff0000,stroke:#00ff00,color:#ffffff\n",
+		"    linkStyle 0 stroke:#000000\n",
+	} {
+		if !strings.Contains(string(out), want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package dot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/anthonybishopric/dot2d3/pkg/ast"
+	"github.com/anthonybishopric/dot2d3/pkg/d3"
+	"github.com/anthonybishopric/dot2d3/pkg/token"
+)
+
+// Warning is a problem that doesn't stop a graph from rendering but likely
+// isn't what the author meant.
+type Warning struct {
+	Pos     token.Position
+	Message string
+}
+
+func (w Warning) String() string {
+	return w.Pos.String() + ": " + w.Message
+}
+
+// Validate checks a parsed graph for likely mistakes: cluster subgraphs
+// sharing an ID (their nodes merge into one cluster), color attributes
+// the browser can't render, and edge operators that don't match the graph
+// type, such as -> in an undirected graph.
+func Validate(g *ast.Graph) []Warning {
+	v := &validator{clusters: make(map[string]token.Position), directed: g.Directed}
+	v.stmts(g.Statements)
+	return v.warnings
+}
+
+type validator struct {
+	warnings []Warning
+	clusters map[string]token.Position
+	directed bool
+}
+
+func (v *validator) warn(pos token.Position, format string, args ...any) {
+	v.warnings = append(v.warnings, Warning{Pos: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) stmts(stmts []ast.Statement) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.NodeStmt:
+			v.attrs(s.Attrs)
+		case *ast.EdgeStmt:
+			v.endpoint(s.Left)
+			for _, r := range s.Rights {
+				v.operator(r)
+				v.endpoint(r.Endpoint)
+			}
+			v.attrs(s.Attrs)
+		case *ast.AttrStmt:
+			v.attrs(s.Attrs)
+		case *ast.AttrAssign:
+			v.attr(s.Key, s.Value)
+		case *ast.Subgraph:
+			v.subgraph(s)
+		}
+	}
+}
+
+// operator warns about an edge operator that doesn't match the graph type,
+// which Graphviz rejects.
+func (v *validator) operator(r ast.EdgeRight) {
+	switch {
+	case r.Directed && !v.directed:
+		v.warn(r.OpPosition, "'->' edge in an undirected graph (use '--')")
+	case !r.Directed && v.directed:
+		v.warn(r.OpPosition, "'--' edge in a directed graph (use '->')")
+	}
+}
+
+func (v *validator) endpoint(ep ast.EdgeEndpoint) {
+	if sg, ok := ep.(*ast.Subgraph); ok {
+		v.subgraph(sg)
+	}
+}
+
+func (v *validator) subgraph(sg *ast.Subgraph) {
+	if sg.ID != nil && strings.HasPrefix(sg.ID.Name, "cluster") {
+		if first, ok := v.clusters[sg.ID.Name]; ok {
+			v.warn(sg.Pos(), "duplicate cluster id %q (first defined at %s)", sg.ID.Name, first)
+		} else {
+			v.clusters[sg.ID.Name] = sg.Pos()
+		}
+	}
+	v.stmts(sg.Statements)
+}
+
+func (v *validator) attrs(list *ast.AttrList) {
+	if list == nil {
+		return
+	}
+	for _, a := range list.Attrs {
+		v.attr(a.Key, a.Value)
+	}
+}
+
+// ignoredAttrs are Graphviz layout and styling attributes that have no
+// effect on the rendered visualization.
+var ignoredAttrs = map[string]bool{
+	"rankdir":    true, // only turns edge labels; the force layout has no direction
+	"rank":       true,
+	"ranksep":    true,
+	"nodesep":    true,
+	"newrank":    true,
+	"ordering":   true,
+	"constraint": true,
+	"arrowhead":  true,
+	"arrowtail":  true,
+	"arrowsize":  true,
+	"dir":        true,
+	"fontname":   true,
+	"margin":     true,
+	"pad":        true,
+	"dpi":        true,
+	"layout":     true,
+	"overlap":    true,
+	"sep":        true,
+}
+
+// IgnoredAttributes returns the Graphviz attributes used in g that the
+// renderer doesn't draw, such as rankdir or nodesep, in order of first use.
+func IgnoredAttributes(g *ast.Graph) []string {
+	var keys []string
+	seen := make(map[string]bool)
+	visitAttrs(g.Statements, func(key *ast.Ident) {
+		if ignoredAttrs[key.Name] && !seen[key.Name] {
+			seen[key.Name] = true
+			keys = append(keys, key.Name)
+		}
+	})
+	return keys
+}
+
+// visitAttrs calls fn with the key of every attribute in stmts, including
+// those inside subgraphs.
+func visitAttrs(stmts []ast.Statement, fn func(key *ast.Ident)) {
+	list := func(l *ast.AttrList) {
+		if l == nil {
+			return
+		}
+		for _, a := range l.Attrs {
+			fn(a.Key)
+		}
+	}
+	endpoint := func(ep ast.EdgeEndpoint) {
+		if sg, ok := ep.(*ast.Subgraph); ok {
+			visitAttrs(sg.Statements, fn)
+		}
+	}
+
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *ast.NodeStmt:
+			list(s.Attrs)
+		case *ast.EdgeStmt:
+			endpoint(s.Left)
+			for _, r := range s.Rights {
+				endpoint(r.Endpoint)
+			}
+			list(s.Attrs)
+		case *ast.AttrStmt:
+			list(s.Attrs)
+		case *ast.AttrAssign:
+			fn(s.Key)
+		case *ast.Subgraph:
+			visitAttrs(s.Statements, fn)
+		}
+	}
+}
+
+// colorAttrs are the attributes whose values are colors.
+var colorAttrs = map[string]bool{
+	"color":          true,
+	"fillcolor":      true,
+	"fontcolor":      true,
+	"bgcolor":        true,
+	"pencolor":       true,
+	"labelfontcolor": true,
+}
+
+func (v *validator) attr(key, value *ast.Ident) {
+	if !colorAttrs[key.Name] {
+		return
+	}
+	// Color lists: "red:blue" or "red;0.3:blue"
+	for _, c := range strings.Split(value.Name, ":") {
+		c, _, _ = strings.Cut(c, ";")
+		if c != "" && !isColor(c) {
+			v.warn(value.Pos(), "invalid %s %q", key.Name, c)
+		}
+	}
+}
+
+var hexColor = regexp.MustCompile(`^((#|0x)([0-9a-fA-F]{3}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})|[0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+
+// isColor reports whether c is a color the renderer can draw: a hex code
+// (#rgb, #rrggbb, #rrggbbaa, 0x or bare hex), a Graphviz HSV triple with
+// components from 0 to 1, a CSS or X11 color name, or a scheme reference
+// such as "/accent3/2" or "/blues9/7".
+func isColor(c string) bool {
+	if hexColor.MatchString(c) {
+		return true
+	}
+	if cssColors[strings.ToLower(c)] {
+		return true
+	}
+	// X11 names, scheme references and HSV triples the converter resolves
+	n := d3.NormalizeColor(c)
+	return n != c && strings.HasPrefix(n, "#")
+}
+
+// cssColors is the set of CSS named colors.
+var cssColors = func() map[string]bool {
+	names := make(map[string]bool)
+	for _, name := range strings.Fields(`aliceblue antiquewhite aqua aquamarine azure beige bisque black
+		blanchedalmond blue blueviolet brown burlywood cadetblue chartreuse chocolate coral
+		cornflowerblue cornsilk crimson cyan darkblue darkcyan darkgoldenrod darkgray darkgreen
+		darkgrey darkkhaki darkmagenta darkolivegreen darkorange darkorchid darkred darksalmon
+		darkseagreen darkslateblue darkslategray darkslategrey darkturquoise darkviolet deeppink
+		deepskyblue dimgray dimgrey dodgerblue firebrick floralwhite forestgreen fuchsia gainsboro
+		ghostwhite gold goldenrod gray green greenyellow grey honeydew hotpink indianred indigo
+		ivory khaki lavender lavenderblush lawngreen lemonchiffon lightblue lightcoral lightcyan
+		lightgoldenrodyellow lightgray lightgreen lightgrey lightpink lightsalmon lightseagreen
+		lightskyblue lightslategray lightslategrey lightsteelblue lightyellow lime limegreen linen
+		magenta maroon mediumaquamarine mediumblue mediumorchid mediumpurple mediumseagreen
+		mediumslateblue mediumspringgreen mediumturquoise mediumvioletred midnightblue mintcream
+		mistyrose moccasin navajowhite navy oldlace olive olivedrab orange orangered orchid
+		palegoldenrod palegreen paleturquoise palevioletred papayawhip peachpuff peru pink plum
+		powderblue purple rebeccapurple red rosybrown royalblue saddlebrown salmon sandybrown
+		seagreen seashell sienna silver skyblue slateblue slategray slategrey snow springgreen
+		steelblue tan teal thistle tomato transparent turquoise violet wheat white whitesmoke
+		yellow yellowgreen`) {
+		names[name] = true
+	}
+	return names
+}()
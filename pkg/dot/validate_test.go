@@ -0,0 +1,66 @@
+package dot
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"clean", `digraph { A [color="#ff0000", fillcolor=lightblue]; B [color="0.6 0.4 1.0"]; A -> B [color="red:blue"] }`, nil},
+		{"duplicate cluster", `digraph { subgraph cluster_a { A } subgraph cluster_a { B } }`, []string{`1:36: duplicate cluster id "cluster_a" (first defined at 1:11)`}},
+		{"bad color", `digraph { A [color=notacolor]; node [fillcolor="#12345"] }`, []string{`invalid color "notacolor"`, `invalid fillcolor "#12345"`}},
+		{"nested", `digraph { A -> subgraph { edge [color=reed] B } }`, []string{`invalid color "reed"`}},
+		{"hsv out of range", `digraph { A [color="0.5 0.5 2"]; B [color="0.1,0.2,0.3,0.4"]; C [color="/blues9/3"] }`, []string{`invalid color "0.5 0.5 2"`, `invalid color "0.1,0.2,0.3,0.4"`}},
+		{"arrow in graph", `graph { A -- B -> C }`, []string{`1:16: '->' edge in an undirected graph`}},
+		{"dashes in digraph", `digraph { A -> B; subgraph { B -- C } }`, []string{`1:32: '--' edge in a directed graph`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g, err := Parse("", []byte(tt.input))
+			if err != nil {
+				t.Fatalf("parse error: %v", err)
+			}
+
+			warnings := Validate(g)
+			if len(warnings) != len(tt.want) {
+				t.Fatalf("expected %d warnings, got %v", len(tt.want), warnings)
+			}
+			for i, w := range warnings {
+				if !strings.Contains(w.String(), tt.want[i]) {
+					t.Errorf("warning %d: expected %q in %q", i, tt.want[i], w.String())
+				}
+			}
+		})
+	}
+}
+
+func TestIgnoredAttributes(t *testing.T) {
+	g, err := Parse("", []byte(`digraph {
+		rankdir=LR
+		graph [nodesep=1, label="ok"]
+		A [shape=box, fontname=Arial, fontsize=10]
+		subgraph cluster_a { B -> C [arrowhead=none, rankdir=TB] }
+	}`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+
+	got := strings.Join(IgnoredAttributes(g), ", ")
+	if want := "rankdir, nodesep, fontname, arrowhead"; got != want {
+		t.Errorf("expected ignored attributes %q, got %q", want, got)
+	}
+
+	g, err = Parse("", []byte(`digraph { A [label="x", color=red, peripheries=2]; A -> B [tailport=s, headport=n] }`))
+	if err != nil {
+		t.Fatalf("parse error: %v", err)
+	}
+	if ignored := IgnoredAttributes(g); len(ignored) != 0 {
+		t.Errorf("expected no ignored attributes, got %v", ignored)
+	}
+}
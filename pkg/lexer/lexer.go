@@ -2,6 +2,7 @@
 package lexer
 
 import (
+	"bytes"
 	"strings"
 	"unicode"
 	"unicode/utf8"
@@ -20,6 +21,15 @@ type Lexer struct {
 
 	filename string
 	Errors   []Error
+
+	// AllowSingleQuotes makes 'text' scan like "text", for files written
+	// in non-standard dialects. It is off by default.
+	AllowSingleQuotes bool
+
+	// ScanComments makes Scan return comments as token.COMMENT, with the
+	// comment's full text (delimiters included) as the literal, instead of
+	// skipping them.
+	ScanComments bool
 }
 
 // Error represents a lexer error.
@@ -32,6 +42,9 @@ func (e Error) Error() string {
 	return e.Pos.String() + ": " + e.Msg
 }
 
+// utf8BOM is the UTF-8 encoded byte order mark.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
 // New creates a new Lexer for the given source.
 func New(filename string, src []byte) *Lexer {
 	l := &Lexer{
@@ -40,6 +53,10 @@ func New(filename string, src []byte) *Lexer {
 		line:     1,
 		column:   0,
 	}
+	// Skip a leading UTF-8 byte order mark, as written by some Windows editors
+	if bytes.HasPrefix(src, utf8BOM) {
+		l.rdOffset = len(utf8BOM)
+	}
 	l.next() // initialize ch
 	return l
 }
@@ -155,15 +172,15 @@ func (l *Lexer) scanNumber() string {
 	return string(l.src[start:l.offset])
 }
 
-func (l *Lexer) scanString() (string, bool) {
-	// Already consumed opening "
+func (l *Lexer) scanString(quote rune) (string, bool) {
+	// Already consumed opening quote
 	var sb strings.Builder
 	for {
 		if l.ch == -1 || l.ch == '\n' {
 			return sb.String(), false // unterminated
 		}
-		if l.ch == '"' {
-			l.next() // consume closing "
+		if l.ch == quote {
+			l.next() // consume closing quote
 			return sb.String(), true
 		}
 		if l.ch == '\\' {
@@ -179,6 +196,10 @@ func (l *Lexer) scanString() (string, bool) {
 				sb.WriteRune('"')
 			case '\\':
 				sb.WriteRune('\\')
+			case 'N', 'G', 'T', 'H':
+				// Graphviz label escapes, expanded by the converter
+				sb.WriteRune('\\')
+				sb.WriteRune(l.ch)
 			default:
 				// DOT allows escaped newlines and other chars
 				sb.WriteRune(l.ch)
@@ -223,32 +244,28 @@ func (l *Lexer) Scan() (pos token.Position, tok token.Token, lit string) {
 
 	// Handle comments and preprocessor lines
 	for {
-		if l.ch == '/' {
-			if l.peek() == '/' {
-				l.next() // consume first /
-				l.next() // consume second /
-				l.skipLineComment()
-				l.skipWhitespace()
-				pos = l.pos()
-				continue
-			} else if l.peek() == '*' {
-				l.next() // consume /
-				l.next() // consume *
-				if !l.skipBlockComment() {
-					l.error(pos, "unterminated block comment")
-				}
-				l.skipWhitespace()
-				pos = l.pos()
-				continue
+		start := l.offset
+		if l.ch == '/' && l.peek() == '/' {
+			l.next() // consume first /
+			l.next() // consume second /
+			l.skipLineComment()
+		} else if l.ch == '/' && l.peek() == '*' {
+			l.next() // consume /
+			l.next() // consume *
+			if !l.skipBlockComment() {
+				l.error(pos, "unterminated block comment")
 			}
-		}
-		if l.ch == '#' {
+		} else if l.ch == '#' {
 			l.skipLineComment()
-			l.skipWhitespace()
-			pos = l.pos()
-			continue
+		} else {
+			break
 		}
-		break
+
+		if l.ScanComments {
+			return pos, token.COMMENT, strings.TrimRight(string(l.src[start:l.offset]), "\r")
+		}
+		l.skipWhitespace()
+		pos = l.pos()
 	}
 
 	switch {
@@ -275,10 +292,11 @@ func (l *Lexer) Scan() (pos token.Position, tok token.Token, lit string) {
 			l.next()
 		}
 
-	case l.ch == '"':
-		l.next() // consume opening "
+	case l.ch == '"' || (l.ch == '\'' && l.AllowSingleQuotes):
+		quote := l.ch
+		l.next() // consume opening quote
 		var ok bool
-		lit, ok = l.scanString()
+		lit, ok = l.scanString(quote)
 		if !ok {
 			l.error(pos, "unterminated string")
 		}
@@ -339,6 +357,20 @@ func (l *Lexer) Scan() (pos token.Position, tok token.Token, lit string) {
 			tok = token.ILLEGAL
 		}
 
+	case l.ch == '\\':
+		// Like Graphviz, backslash escapes are only understood inside
+		// quoted strings: an unquoted foo\-bar is an error, not the ID
+		// "foo-bar". The escaped character is consumed with the backslash
+		// so it doesn't produce a second, confusing error of its own.
+		l.next()
+		lit = "\\"
+		if l.ch != -1 && !unicode.IsSpace(l.ch) {
+			lit += string(l.ch)
+			l.next()
+		}
+		l.error(pos, "unexpected "+lit+": backslash escapes are only allowed in quoted strings; quote the ID instead")
+		tok = token.ILLEGAL
+
 	default:
 		if unicode.IsPrint(l.ch) {
 			l.error(pos, "unexpected character: "+string(l.ch))
@@ -1,6 +1,7 @@
 package lexer
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/anthonybishopric/dot2d3/pkg/token"
@@ -92,6 +93,17 @@ func TestLexer(t *testing.T) {
 				{token.EOF, ""},
 			},
 		},
+		{
+			name:  "label escapes",
+			input: `"\N of \G: \T -> \H\n"`,
+			tokens: []struct {
+				tok token.Token
+				lit string
+			}{
+				{token.STRING, "\\N of \\G: \\T -> \\H\n"},
+				{token.EOF, ""},
+			},
+		},
 		{
 			name:  "html string",
 			input: `<<b>bold</b>>`,
@@ -252,3 +264,173 @@ func TestLexerErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestLexerBackslashInUnquotedID(t *testing.T) {
+	// Escapes are only understood in quoted strings, as in Graphviz. An
+	// escape in an unquoted ID is one illegal token with one error at the
+	// backslash; the IDs around it are left as they are.
+	tests := []struct {
+		input   string
+		illegal string
+		tokens  []token.Token
+	}{
+		{`foo\-bar`, `\-`, []token.Token{token.IDENT, token.ILLEGAL, token.IDENT}},
+		{`foo\ bar`, `\`, []token.Token{token.IDENT, token.ILLEGAL, token.IDENT}},
+		{`foo\`, `\`, []token.Token{token.IDENT, token.ILLEGAL}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			l := New("test", []byte(tt.input))
+
+			var idents []string
+			for i, want := range tt.tokens {
+				_, tok, lit := l.Scan()
+				if tok != want {
+					t.Fatalf("token %d: expected %v, got %v %q", i, want, tok, lit)
+				}
+				switch tok {
+				case token.IDENT:
+					idents = append(idents, lit)
+				case token.ILLEGAL:
+					if lit != tt.illegal {
+						t.Errorf("expected illegal literal %q, got %q", tt.illegal, lit)
+					}
+				}
+			}
+			if _, tok, _ := l.Scan(); tok != token.EOF {
+				t.Errorf("expected EOF, got %v", tok)
+			}
+
+			if idents[0] != "foo" || (len(idents) > 1 && idents[1] != "bar") {
+				t.Errorf("expected the IDs around the escape to be unchanged, got %q", idents)
+			}
+			if len(l.Errors) != 1 {
+				t.Fatalf("expected exactly one error, got %v", l.Errors)
+			}
+			if e := l.Errors[0]; e.Pos.Column != 4 || !strings.Contains(e.Msg, "quoted") {
+				t.Errorf("expected an error at the backslash suggesting quotes, got %v", e)
+			}
+		})
+	}
+
+	// Quoted, the same characters are one ID
+	l := New("test", []byte(`"foo\-bar"`))
+	if _, tok, lit := l.Scan(); tok != token.STRING || lit != "foo-bar" || len(l.Errors) > 0 {
+		t.Errorf("expected quoted STRING \"foo-bar\", got %v %q (errors %v)", tok, lit, l.Errors)
+	}
+}
+
+func TestLexerSingleQuotes(t *testing.T) {
+	tests := []struct {
+		input string
+		lit   string
+	}{
+		{`'hello world'`, "hello world"},
+		{`'say "hi"'`, `say "hi"`},
+		{`'it\'s'`, "it's"},
+	}
+
+	for _, tt := range tests {
+		l := New("test", []byte(tt.input))
+		l.AllowSingleQuotes = true
+
+		_, tok, lit := l.Scan()
+		if tok != token.STRING || lit != tt.lit {
+			t.Errorf("%s: expected STRING %q, got %v %q", tt.input, tt.lit, tok, lit)
+		}
+		if len(l.Errors) > 0 {
+			t.Errorf("%s: unexpected errors: %v", tt.input, l.Errors)
+		}
+	}
+
+	l := New("test", []byte(`'hello`))
+	l.AllowSingleQuotes = true
+	l.Scan()
+	if len(l.Errors) == 0 {
+		t.Error("expected error for unterminated single-quoted string")
+	}
+
+	l = New("test", []byte(`'hello'`))
+	if _, tok, _ := l.Scan(); tok != token.ILLEGAL || len(l.Errors) == 0 {
+		t.Errorf("expected single quotes to be rejected by default, got %v", tok)
+	}
+}
+
+func TestLexerBOMAndCRLF(t *testing.T) {
+	input := "\xEF\xBB\xBFdigraph G {\r\n  A -> B\r\n  // comment\r\n  C [label=\"x\"]\r\n}\r\n"
+	l := New("test", []byte(input))
+
+	expected := []struct {
+		tok          token.Token
+		lit          string
+		line, column int
+	}{
+		{token.DIGRAPH, "", 1, 1},
+		{token.IDENT, "G", 1, 9},
+		{token.LBRACE, "", 1, 11},
+		{token.IDENT, "A", 2, 3},
+		{token.ARROW, "", 2, 5},
+		{token.IDENT, "B", 2, 8},
+		{token.IDENT, "C", 4, 3},
+		{token.LBRACKET, "", 4, 5},
+		{token.IDENT, "label", 4, 6},
+		{token.EQUAL, "", 4, 11},
+		{token.STRING, "x", 4, 12},
+		{token.RBRACKET, "", 4, 15},
+		{token.RBRACE, "", 5, 1},
+		{token.EOF, "", 0, 0},
+	}
+
+	for i, want := range expected {
+		pos, tok, lit := l.Scan()
+		if tok != want.tok || (want.lit != "" && lit != want.lit) {
+			t.Errorf("token %d: expected %v %q, got %v %q", i, want.tok, want.lit, tok, lit)
+		}
+		if want.tok != token.EOF && (pos.Line != want.line || pos.Column != want.column) {
+			t.Errorf("token %d (%v): expected %d:%d, got %d:%d", i, tok, want.line, want.column, pos.Line, pos.Column)
+		}
+	}
+	if len(l.Errors) > 0 {
+		t.Errorf("unexpected errors: %v", l.Errors)
+	}
+}
+
+func TestLexerScanComments(t *testing.T) {
+	input := "// header\r\ndigraph { /* inline */ A # preprocessor\n}"
+
+	l := New("test", []byte(input))
+	l.ScanComments = true
+	expected := []struct {
+		tok  token.Token
+		lit  string
+		line int
+	}{
+		{token.COMMENT, "// header", 1},
+		{token.DIGRAPH, "", 2},
+		{token.LBRACE, "", 2},
+		{token.COMMENT, "/* inline */", 2},
+		{token.IDENT, "A", 2},
+		{token.COMMENT, "# preprocessor", 2},
+		{token.RBRACE, "", 3},
+		{token.EOF, "", 3},
+	}
+	for i, want := range expected {
+		pos, tok, lit := l.Scan()
+		if tok != want.tok || (want.lit != "" && lit != want.lit) || pos.Line != want.line {
+			t.Errorf("token %d: expected %v %q on line %d, got %v %q on line %d", i, want.tok, want.lit, want.line, tok, lit, pos.Line)
+		}
+	}
+
+	// Comments are skipped by default
+	l = New("test", []byte(input))
+	for {
+		_, tok, _ := l.Scan()
+		if tok == token.COMMENT {
+			t.Fatal("expected comments to be skipped without ScanComments")
+		}
+		if tok == token.EOF {
+			break
+		}
+	}
+}
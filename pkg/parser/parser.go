@@ -2,6 +2,7 @@
 package parser
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
@@ -25,6 +26,20 @@ type Parser struct {
 	peekLit string
 
 	Errors []Error
+
+	// Comments from a lexer with ScanComments set, waiting to be attached
+	// to a statement, and the line of the last consumed token
+	comments []*ast.Comment
+	lastLine int
+
+	// Partial parsing (see ParsePartial): stop at the first error
+	partial    bool
+	stopped    bool
+	lexErr     *lexer.Error // lexer error raised while scanning the current token
+	peekLexErr *lexer.Error // lexer error raised while scanning the lookahead
+
+	// ctx, when set with SetContext, stops parsing once it is done
+	ctx context.Context
 }
 
 // Error represents a parser error.
@@ -46,15 +61,60 @@ func New(l *lexer.Lexer) *Parser {
 	return p
 }
 
+// SetContext makes the parser stop, as if the input ended, once ctx is
+// done; parsing then returns ctx's error.
+func (p *Parser) SetContext(ctx context.Context) {
+	p.ctx = ctx
+}
+
 func (p *Parser) next() {
+	if p.stopped {
+		return
+	}
+	if p.ctx != nil && p.ctx.Err() != nil {
+		p.Errors = append(p.Errors, Error{Pos: p.peekPos, Msg: p.ctx.Err().Error()})
+		p.stopped = true
+		p.tok = token.EOF
+		p.peekTok = token.EOF
+		return
+	}
+	p.lastLine = p.pos.Line
 	p.pos = p.peekPos
 	p.tok = p.peekTok
 	p.lit = p.peekLit
-	p.peekPos, p.peekTok, p.peekLit = p.lexer.Scan()
+	p.lexErr = p.peekLexErr
+
+	n := len(p.lexer.Errors)
+	for {
+		p.peekPos, p.peekTok, p.peekLit = p.lexer.Scan()
+		if p.peekTok != token.COMMENT {
+			break
+		}
+		p.comments = append(p.comments, &ast.Comment{Position: p.peekPos, Text: p.peekLit})
+	}
+	p.peekLexErr = nil
+	if len(p.lexer.Errors) > n {
+		e := p.lexer.Errors[n]
+		p.peekLexErr = &e
+	}
+
+	if p.partial && p.lexErr != nil {
+		// The current token could not be scanned cleanly
+		p.error(p.lexErr.Pos, p.lexErr.Msg)
+	}
 }
 
 func (p *Parser) error(pos token.Position, msg string) {
+	if p.stopped {
+		return
+	}
 	p.Errors = append(p.Errors, Error{Pos: pos, Msg: msg})
+	if p.partial {
+		// Pretend the input ends here so every production unwinds
+		p.stopped = true
+		p.tok = token.EOF
+		p.peekTok = token.EOF
+	}
 }
 
 func (p *Parser) errorf(pos token.Position, format string, args ...interface{}) {
@@ -70,6 +130,21 @@ func (p *Parser) expect(tok token.Token) token.Position {
 	return pos
 }
 
+// takeComments removes and returns the pending comments that come before
+// the current token and, if maxLine is positive, start on or before maxLine.
+func (p *Parser) takeComments(maxLine int) []*ast.Comment {
+	var taken, rest []*ast.Comment
+	for _, c := range p.comments {
+		if (p.tok == token.EOF || c.Position.Offset < p.pos.Offset) && (maxLine <= 0 || c.Position.Line <= maxLine) {
+			taken = append(taken, c)
+		} else {
+			rest = append(rest, c)
+		}
+	}
+	p.comments = rest
+	return taken
+}
+
 // isID returns true if the current token can be an ID.
 func (p *Parser) isID() bool {
 	return p.tok == token.IDENT || p.tok == token.STRING || p.tok == token.HTML
@@ -78,7 +153,39 @@ func (p *Parser) isID() bool {
 // Parse parses a complete DOT graph.
 func (p *Parser) Parse() (*ast.Graph, error) {
 	g := p.parseGraph()
+	return g, p.err()
+}
+
+// ParseFile parses every graph in the input, for files that hold several
+// graphs one after another. A graph may start right after the previous
+// one's closing brace, as in `digraph A {}digraph B {}`.
+func (p *Parser) ParseFile() ([]*ast.Graph, error) {
+	var graphs []*ast.Graph
+	for p.tok != token.EOF {
+		g := p.parseGraph()
+		graphs = append(graphs, g)
+		if len(p.Errors) > 0 {
+			break
+		}
+	}
+	if len(graphs) == 0 {
+		p.errorf(p.pos, "expected 'graph' or 'digraph', got %s", p.tok)
+	}
+	return graphs, p.err()
+}
 
+// ParseFragment parses a bare statement list with no graph header or
+// enclosing braces, such as `A -> B; C [shape=box]`.
+func (p *Parser) ParseFragment() ([]ast.Statement, error) {
+	stmts := p.parseStmtList()
+	if p.tok != token.EOF {
+		p.errorf(p.pos, "unexpected %s", p.tok)
+	}
+	return stmts, p.err()
+}
+
+// err combines lexer and parser errors into one error, or returns nil.
+func (p *Parser) err() error {
 	// Collect all errors
 	var allErrors []error
 	for _, e := range p.lexer.Errors {
@@ -93,15 +200,37 @@ func (p *Parser) Parse() (*ast.Graph, error) {
 		for _, e := range allErrors {
 			msgs = append(msgs, e.Error())
 		}
-		return g, fmt.Errorf("parse errors:\n%s", strings.Join(msgs, "\n"))
+		return fmt.Errorf("parse errors:\n%s", strings.Join(msgs, "\n"))
+	}
+
+	return nil
+}
+
+// ParsePartial parses a DOT graph up to the first error, for callers such
+// as editors that want structure for the valid prefix of incomplete input.
+// It returns the statements parsed before the error, the position where
+// parsing stopped, and the error. On success the position is the zero
+// Position and the error is nil.
+func (p *Parser) ParsePartial() (*ast.Graph, token.Position, error) {
+	p.partial = true
+	if p.lexErr != nil {
+		// The very first token was malformed
+		p.error(p.lexErr.Pos, p.lexErr.Msg)
+	}
+
+	g := p.parseGraph()
+	if len(p.Errors) == 0 {
+		return g, token.Position{}, nil
 	}
 
-	return g, nil
+	first := p.Errors[0]
+	return g, first.Pos, fmt.Errorf("parse error: %s", first.Error())
 }
 
 // parseGraph parses: [ 'strict' ] ('graph' | 'digraph') [ ID ] '{' stmt_list '}'
 func (p *Parser) parseGraph() *ast.Graph {
 	g := &ast.Graph{Position: p.pos}
+	g.Leading = p.takeComments(0)
 
 	// Optional 'strict'
 	if p.tok == token.STRICT {
@@ -129,7 +258,15 @@ func (p *Parser) parseGraph() *ast.Graph {
 	// '{' stmt_list '}'
 	p.expect(token.LBRACE)
 	g.Statements = p.parseStmtList()
+	g.Trailing = p.takeComments(0)
 	p.expect(token.RBRACE)
+	// After the closing brace. When another graph follows, comments on
+	// later lines lead that graph instead.
+	maxLine := 0
+	if p.tok != token.EOF {
+		maxLine = p.lastLine
+	}
+	g.Trailing = append(g.Trailing, p.takeComments(maxLine)...)
 
 	return g
 }
@@ -139,14 +276,33 @@ func (p *Parser) parseStmtList() []Statement {
 	var stmts []Statement
 
 	for p.tok != token.RBRACE && p.tok != token.EOF {
+		leading := p.takeComments(0)
 		stmt := p.parseStmt()
-		if stmt != nil {
-			stmts = append(stmts, stmt)
+		if p.stopped {
+			// Keep subgraphs, which hold their own valid prefix, but drop
+			// any other statement the error interrupted
+			if sub, ok := stmt.(*ast.Subgraph); ok {
+				stmts = append(stmts, sub)
+			}
+			break
 		}
 		// Optional semicolon
 		if p.tok == token.SEMICOLON {
 			p.next()
 		}
+		if stmt != nil {
+			trivia := stmt.Comments()
+			trivia.Leading = leading
+			trivia.Trailing = append(trivia.Trailing, p.takeComments(p.lastLine)...)
+			stmts = append(stmts, stmt)
+		}
+	}
+
+	// Comments before the closing brace belong to the last statement; in
+	// an empty body they are left for the enclosing graph or subgraph
+	if len(stmts) > 0 {
+		last := stmts[len(stmts)-1].Comments()
+		last.Trailing = append(last.Trailing, p.takeComments(0)...)
 	}
 
 	return stmts
@@ -167,10 +323,14 @@ func (p *Parser) parseStmt() Statement {
 		// attr_stmt: edge attr_list
 		return p.parseAttrStmt(ast.EdgeAttr)
 	case token.SUBGRAPH, token.LBRACE:
-		// subgraph
+		// subgraph, or node group when used as an edge endpoint ({A B} -> C)
+		anonymous := p.tok == token.LBRACE
 		sub := p.parseSubgraph()
 		// Check if this is actually an edge statement
 		if p.tok == token.ARROW || p.tok == token.DASHDASH {
+			if group := nodeGroupOf(sub); anonymous && group != nil {
+				return p.parseEdgeStmt(group)
+			}
 			return p.parseEdgeStmt(sub)
 		}
 		return sub
@@ -260,6 +420,7 @@ func (p *Parser) parseEdgeStmt(left ast.EdgeEndpoint) *ast.EdgeStmt {
 	// Parse edge RHS chain
 	for p.tok == token.ARROW || p.tok == token.DASHDASH {
 		directed := p.tok == token.ARROW
+		opPos := p.pos
 		p.next()
 
 		var endpoint ast.EdgeEndpoint
@@ -273,9 +434,10 @@ func (p *Parser) parseEdgeStmt(left ast.EdgeEndpoint) *ast.EdgeStmt {
 		}
 
 		stmt.Rights = append(stmt.Rights, ast.EdgeRight{
-			Position: endpoint.Pos(),
-			Directed: directed,
-			Endpoint: endpoint,
+			Position:   endpoint.Pos(),
+			OpPosition: opPos,
+			Directed:   directed,
+			Endpoint:   endpoint,
 		})
 	}
 
@@ -293,49 +455,29 @@ func (p *Parser) parseSubgraphOrGroup() ast.EdgeEndpoint {
 		return p.parseSubgraph()
 	}
 
-	// Might be a node group {A B C} or anonymous subgraph
-	pos := p.pos
-	p.expect(token.LBRACE)
-
-	// Peek to see if this looks like a node group (just IDs) or subgraph (statements)
-	// A node group contains only node IDs separated by spaces
-	// For simplicity, we'll parse as a subgraph if we see statements
-	// Otherwise as a node group
-
-	// Try to parse as node group first
-	var nodes []*ast.NodeID
-	for p.isID() {
-		nodes = append(nodes, p.parseNodeID())
-	}
-
-	if p.tok == token.RBRACE && len(nodes) > 0 {
-		p.next()
-		return &ast.NodeGroup{
-			Position: pos,
-			Nodes:    nodes,
-		}
+	// An anonymous subgraph containing only bare node IDs is a node group
+	sub := p.parseSubgraph()
+	if group := nodeGroupOf(sub); group != nil {
+		return group
 	}
+	return sub
+}
 
-	// Not a simple node group, parse as subgraph
-	// We already consumed some nodes, convert them to statements
-	var stmts []Statement
-	for _, n := range nodes {
-		stmts = append(stmts, &ast.NodeStmt{
-			Position: n.Position,
-			NodeID:   n,
-		})
+// nodeGroupOf returns the node group equivalent of an anonymous subgraph
+// whose body is only attribute-less node statements, or nil otherwise.
+func nodeGroupOf(sub *ast.Subgraph) *ast.NodeGroup {
+	if sub.ID != nil || len(sub.Statements) == 0 {
+		return nil
 	}
-
-	// Continue parsing statements
-	moreStmts := p.parseStmtList()
-	stmts = append(stmts, moreStmts...)
-
-	p.expect(token.RBRACE)
-
-	return &ast.Subgraph{
-		Position:   pos,
-		Statements: stmts,
+	group := &ast.NodeGroup{Position: sub.Position}
+	for _, stmt := range sub.Statements {
+		n, ok := stmt.(*ast.NodeStmt)
+		if !ok || n.Attrs != nil {
+			return nil
+		}
+		group.Nodes = append(group.Nodes, n.NodeID)
 	}
+	return group
 }
 
 // parseSubgraph parses: [ 'subgraph' [ ID ] ] '{' stmt_list '}'
@@ -351,6 +493,7 @@ func (p *Parser) parseSubgraph() *ast.Subgraph {
 
 	p.expect(token.LBRACE)
 	sub.Statements = p.parseStmtList()
+	sub.Trailing = p.takeComments(0)
 	p.expect(token.RBRACE)
 
 	return sub
@@ -395,7 +538,9 @@ func (p *Parser) parseAttrList() *ast.AttrList {
 	for p.tok == token.LBRACKET {
 		p.next()
 
-		// Parse a_list: ID '=' ID [ (';' | ',') ] [ a_list ]
+		// Parse a_list: ID '=' ID [ (';' | ',') ] [ a_list ]. The list may
+		// be empty ("[]"), and a separator may follow the last attribute
+		// ("[a=1,]").
 		for p.isID() {
 			attr := &ast.Attr{Position: p.pos}
 			attr.Key = p.parseIdent()
@@ -409,7 +554,8 @@ func (p *Parser) parseAttrList() *ast.AttrList {
 					attr.Value = &ast.Ident{Position: p.pos, Name: ""}
 				}
 			} else {
-				// Attribute without value (treat as true)
+				// Attribute without value, as in [rounded] (treat as true).
+				// Not standard DOT, but common in hand-written files.
 				attr.Value = &ast.Ident{Position: attr.Key.Position, Name: "true"}
 			}
 
@@ -421,7 +567,19 @@ func (p *Parser) parseAttrList() *ast.AttrList {
 			}
 		}
 
-		p.expect(token.RBRACKET)
+		if p.tok != token.RBRACKET {
+			// Report a stray token such as the second comma in [a=1,,b=2]
+			// once, then skip the rest of the list rather than failing
+			// again on its closing bracket.
+			p.errorf(p.pos, "expected attribute or ], got %s", p.tok)
+			for p.tok != token.RBRACKET && p.tok != token.RBRACE && p.tok != token.EOF {
+				p.next()
+			}
+			if p.tok != token.RBRACKET {
+				break
+			}
+		}
+		p.next() // consume ]
 	}
 
 	return list
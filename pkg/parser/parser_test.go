@@ -1,6 +1,8 @@
 package parser
 
 import (
+	"context"
+	"strings"
 	"testing"
 
 	"github.com/anthonybishopric/dot2d3/pkg/ast"
@@ -121,6 +123,53 @@ func TestParseNodeAttributes(t *testing.T) {
 	}
 }
 
+func TestParseAttributeSeparators(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  [][2]string
+	}{
+		{"semicolons", `digraph { A [a=1; b=2] }`, [][2]string{{"a", "1"}, {"b", "2"}}},
+		{"spaces", `digraph { A [a=1 b=2] }`, [][2]string{{"a", "1"}, {"b", "2"}}},
+		{"flag", `digraph { A [rounded] }`, [][2]string{{"rounded", "true"}}},
+		{"mixed", `digraph { A [rounded; a=1, b=2 c=3][d=4] }`, [][2]string{{"rounded", "true"}, {"a", "1"}, {"b", "2"}, {"c", "3"}, {"d", "4"}}},
+		{"empty", `digraph { A [] }`, nil},
+		{"empty lists", `digraph { A [][] [a=1] [] }`, [][2]string{{"a", "1"}}},
+		{"trailing comma", `digraph { A [a=1,] }`, [][2]string{{"a", "1"}}},
+		{"trailing semicolon", `digraph { A [a=1; b=2;] }`, [][2]string{{"a", "1"}, {"b", "2"}}},
+		{"trailing flag", `digraph { A [a=1, rounded,] }`, [][2]string{{"a", "1"}, {"rounded", "true"}}},
+		{"quoted flag", `digraph { A ["rounded"] }`, [][2]string{{"rounded", "true"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New("test", []byte(tt.input))
+			p := New(l)
+			g, err := p.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(g.Statements) != 1 {
+				t.Fatalf("expected 1 statement, got %d", len(g.Statements))
+			}
+			node, ok := g.Statements[0].(*ast.NodeStmt)
+			if !ok {
+				t.Fatalf("expected NodeStmt, got %T", g.Statements[0])
+			}
+
+			if node.Attrs == nil || len(node.Attrs.Attrs) != len(tt.want) {
+				t.Fatalf("expected %d attributes, got %v", len(tt.want), node.Attrs)
+			}
+			for i, attr := range node.Attrs.Attrs {
+				if attr.Key.Name != tt.want[i][0] || attr.Value.Name != tt.want[i][1] {
+					t.Errorf("attribute %d: expected %s=%s, got %s=%s", i, tt.want[i][0], tt.want[i][1], attr.Key.Name, attr.Value.Name)
+				}
+			}
+		})
+	}
+}
+
 func TestParseEdgeAttributes(t *testing.T) {
 	input := `digraph { A -> B [label="connects", style=dashed] }`
 
@@ -280,6 +329,64 @@ func TestParseEdgeShorthand(t *testing.T) {
 	}
 }
 
+func TestParseGroupToGroupEdge(t *testing.T) {
+	input := `digraph { {A B} -> {C; D} }`
+
+	l := lexer.New("test", []byte(input))
+	p := New(l)
+	g, err := p.Parse()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	edge, ok := g.Statements[0].(*ast.EdgeStmt)
+	if !ok {
+		t.Fatalf("expected EdgeStmt, got %T", g.Statements[0])
+	}
+
+	left, ok := edge.Left.(*ast.NodeGroup)
+	if !ok {
+		t.Fatalf("expected NodeGroup on left, got %T", edge.Left)
+	}
+	if len(left.Nodes) != 2 {
+		t.Errorf("expected 2 nodes in left group, got %d", len(left.Nodes))
+	}
+
+	right, ok := edge.Rights[0].Endpoint.(*ast.NodeGroup)
+	if !ok {
+		t.Fatalf("expected NodeGroup on right, got %T", edge.Rights[0].Endpoint)
+	}
+	if len(right.Nodes) != 2 {
+		t.Errorf("expected 2 nodes in right group, got %d", len(right.Nodes))
+	}
+}
+
+func TestParseAnonymousSubgraphEdge(t *testing.T) {
+	input := `digraph { {A -> B} -> {C [color=red]} }`
+
+	l := lexer.New("test", []byte(input))
+	p := New(l)
+	g, err := p.Parse()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	edge, ok := g.Statements[0].(*ast.EdgeStmt)
+	if !ok {
+		t.Fatalf("expected EdgeStmt, got %T", g.Statements[0])
+	}
+
+	// Bodies with edges or attributes stay subgraphs
+	if _, ok := edge.Left.(*ast.Subgraph); !ok {
+		t.Errorf("expected Subgraph on left, got %T", edge.Left)
+	}
+	if _, ok := edge.Rights[0].Endpoint.(*ast.Subgraph); !ok {
+		t.Errorf("expected Subgraph on right, got %T", edge.Rights[0].Endpoint)
+	}
+}
+
 func TestParseComments(t *testing.T) {
 	input := `
 	// Line comment
@@ -305,6 +412,59 @@ func TestParseComments(t *testing.T) {
 	}
 }
 
+func TestParseCommentTrivia(t *testing.T) {
+	input := `// graph header
+digraph {
+	// about A
+	/* and B */
+	A -> B; // trailing
+	subgraph s {
+		// empty body
+	}
+	C [label=x] /* inline */
+	// dangling
+}
+// footer
+`
+
+	l := lexer.New("test", []byte(input))
+	l.ScanComments = true
+	g, err := New(l).Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(g.Statements) != 3 {
+		t.Fatalf("expected 3 statements, got %d", len(g.Statements))
+	}
+
+	texts := func(cs []*ast.Comment) string {
+		var out []string
+		for _, c := range cs {
+			out = append(out, c.Text)
+		}
+		return strings.Join(out, "|")
+	}
+
+	tests := []struct {
+		name           string
+		trivia         *ast.Trivia
+		leading, trail string
+	}{
+		{"graph", g.Comments(), "// graph header", "// footer"},
+		{"edge", g.Statements[0].Comments(), "// about A|/* and B */", "// trailing"},
+		{"subgraph", g.Statements[1].Comments(), "", "// empty body"},
+		{"node", g.Statements[2].Comments(), "", "/* inline */|// dangling"},
+	}
+	for _, tt := range tests {
+		if got := texts(tt.trivia.Leading); got != tt.leading {
+			t.Errorf("%s: expected leading %q, got %q", tt.name, tt.leading, got)
+		}
+		if got := texts(tt.trivia.Trailing); got != tt.trail {
+			t.Errorf("%s: expected trailing %q, got %q", tt.name, tt.trail, got)
+		}
+	}
+}
+
 func TestParseHTMLLabel(t *testing.T) {
 	input := `digraph { A [label=<<b>Bold</b>>] }`
 
@@ -327,6 +487,55 @@ func TestParseHTMLLabel(t *testing.T) {
 	}
 }
 
+func TestParseQuotedPunctuation(t *testing.T) {
+	labels := []string{
+		"a [b] {c}",
+		"one; two",
+		"A -> B",
+		"A -- B",
+		"]}[{;,=->",
+		`say \"[hi]\"`,
+	}
+
+	for _, label := range labels {
+		t.Run(label, func(t *testing.T) {
+			input := `digraph { A [label="` + label + `"]; A -> B [label="` + label + `"]; C }`
+
+			l := lexer.New("test", []byte(input))
+			p := New(l)
+			g, err := p.Parse()
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(g.Statements) != 3 {
+				t.Fatalf("expected 3 statements, got %d", len(g.Statements))
+			}
+
+			want := strings.ReplaceAll(label, `\"`, `"`)
+			node, ok := g.Statements[0].(*ast.NodeStmt)
+			if !ok {
+				t.Fatalf("expected NodeStmt, got %T", g.Statements[0])
+			}
+			if got := node.Attrs.Get("label"); got != want {
+				t.Errorf("expected node label %q, got %q", want, got)
+			}
+
+			edge, ok := g.Statements[1].(*ast.EdgeStmt)
+			if !ok {
+				t.Fatalf("expected EdgeStmt, got %T", g.Statements[1])
+			}
+			if len(edge.Rights) != 1 {
+				t.Errorf("expected a single edge, got %d", len(edge.Rights))
+			}
+			if got := edge.Attrs.Get("label"); got != want {
+				t.Errorf("expected edge label %q, got %q", want, got)
+			}
+		})
+	}
+}
+
 func TestParseCaseInsensitiveKeywords(t *testing.T) {
 	input := `DIGRAPH { NODE [shape=box] A -> B }`
 
@@ -372,3 +581,325 @@ func TestParsePort(t *testing.T) {
 		t.Errorf("expected port 'port1', got %s", leftNode.Port.ID.Name)
 	}
 }
+
+func TestParsePartialTrailingError(t *testing.T) {
+	input := `digraph G { A -> B; C [color=red]; D -> }`
+
+	l := lexer.New("test", []byte(input))
+	p := New(l)
+	g, pos, err := p.ParsePartial()
+
+	if err == nil {
+		t.Fatal("expected error for trailing syntax error")
+	}
+
+	if g.ID == nil || g.ID.Name != "G" {
+		t.Errorf("expected graph ID 'G', got %v", g.ID)
+	}
+
+	// The two complete statements before the error are kept
+	if len(g.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(g.Statements))
+	}
+	if _, ok := g.Statements[0].(*ast.EdgeStmt); !ok {
+		t.Errorf("expected EdgeStmt, got %T", g.Statements[0])
+	}
+	if _, ok := g.Statements[1].(*ast.NodeStmt); !ok {
+		t.Errorf("expected NodeStmt, got %T", g.Statements[1])
+	}
+
+	// Parsing stopped at the closing brace
+	if pos.Line != 1 || pos.Column != 41 {
+		t.Errorf("expected stop position 1:41, got %s", pos)
+	}
+}
+
+func TestParsePartialNestedSubgraph(t *testing.T) {
+	input := "digraph {\n  subgraph cluster_0 {\n    A; B\n    C -> = \n  }\n}"
+
+	l := lexer.New("test", []byte(input))
+	p := New(l)
+	g, pos, err := p.ParsePartial()
+
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if pos.Line != 4 {
+		t.Errorf("expected stop on line 4, got %s", pos)
+	}
+
+	if len(g.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(g.Statements))
+	}
+	sub, ok := g.Statements[0].(*ast.Subgraph)
+	if !ok {
+		t.Fatalf("expected Subgraph, got %T", g.Statements[0])
+	}
+	if len(sub.Statements) != 2 {
+		t.Errorf("expected 2 statements in subgraph, got %d", len(sub.Statements))
+	}
+}
+
+func TestParsePartialLexerError(t *testing.T) {
+	input := "digraph { A -> B\n  C [label=\"unterminated\n}"
+
+	l := lexer.New("test", []byte(input))
+	p := New(l)
+	g, pos, err := p.ParsePartial()
+
+	if err == nil {
+		t.Fatal("expected error for unterminated string")
+	}
+	if pos.Line != 2 {
+		t.Errorf("expected stop on line 2, got %s", pos)
+	}
+	if len(g.Statements) != 1 {
+		t.Errorf("expected 1 statement, got %d", len(g.Statements))
+	}
+}
+
+func TestParsePartialValidInput(t *testing.T) {
+	l := lexer.New("test", []byte(`digraph { A -> B }`))
+	p := New(l)
+	g, pos, err := p.ParsePartial()
+
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if pos.IsValid() {
+		t.Errorf("expected no stop position, got %s", pos)
+	}
+	if len(g.Statements) != 1 {
+		t.Errorf("expected 1 statement, got %d", len(g.Statements))
+	}
+}
+
+// describeStmt summarizes a statement for order assertions.
+func describeStmt(stmt ast.Statement) string {
+	switch s := stmt.(type) {
+	case *ast.NodeStmt:
+		return "node " + s.NodeID.ID.Name
+	case *ast.EdgeStmt:
+		desc := "edge " + describeEndpoint(s.Left)
+		for _, r := range s.Rights {
+			desc += "->" + describeEndpoint(r.Endpoint)
+		}
+		return desc
+	case *ast.AttrStmt:
+		return "attr " + s.Kind.String()
+	case *ast.AttrAssign:
+		return "assign " + s.Key.Name
+	case *ast.Subgraph:
+		desc := "subgraph{"
+		for i, inner := range s.Statements {
+			if i > 0 {
+				desc += "; "
+			}
+			desc += describeStmt(inner)
+		}
+		return desc + "}"
+	default:
+		return "?"
+	}
+}
+
+func describeEndpoint(ep ast.EdgeEndpoint) string {
+	switch e := ep.(type) {
+	case *ast.NodeID:
+		return e.ID.Name
+	case *ast.NodeGroup:
+		desc := "{"
+		for i, n := range e.Nodes {
+			if i > 0 {
+				desc += " "
+			}
+			desc += n.ID.Name
+		}
+		return desc + "}"
+	case *ast.Subgraph:
+		return describeStmt(e)
+	default:
+		return "?"
+	}
+}
+
+func TestParseStatementOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name:  "nodes then edge in anonymous subgraph",
+			input: `digraph { { A B ; C -> D } }`,
+			want:  []string{"subgraph{node A; node B; edge C->D}"},
+		},
+		{
+			name:  "node group statement followed by statements",
+			input: `digraph { { A B } ; C -> D ; E }`,
+			want:  []string{"subgraph{node A; node B}", "edge C->D", "node E"},
+		},
+		{
+			name:  "node group edge between statements",
+			input: `digraph { X ; { A B } -> C ; D }`,
+			want:  []string{"node X", "edge {A B}->C", "node D"},
+		},
+		{
+			name:  "mixed statement kinds",
+			input: `digraph { rankdir=LR; node [shape=box]; B; A -> B; subgraph s { D; C }; edge [color=red] }`,
+			want:  []string{"assign rankdir", "attr node", "node B", "edge A->B", "subgraph{node D; node C}", "attr edge"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lexer.New("test", []byte(tt.input))
+			p := New(l)
+			g, err := p.Parse()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(g.Statements) != len(tt.want) {
+				t.Fatalf("expected %d statements, got %d", len(tt.want), len(g.Statements))
+			}
+			for i, stmt := range g.Statements {
+				if got := describeStmt(stmt); got != tt.want[i] {
+					t.Errorf("statement %d: expected %q, got %q", i, tt.want[i], got)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFragment(t *testing.T) {
+	l := lexer.New("test", []byte(`A -> B; C [shape=box]`))
+	p := New(l)
+	stmts, err := p.ParseFragment()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(stmts))
+	}
+	if got := describeStmt(stmts[0]); got != "edge A->B" {
+		t.Errorf("expected edge A->B, got %q", got)
+	}
+	node, ok := stmts[1].(*ast.NodeStmt)
+	if !ok {
+		t.Fatalf("expected NodeStmt, got %T", stmts[1])
+	}
+	if node.NodeID.ID.Name != "C" || node.Attrs.Get("shape") != "box" {
+		t.Errorf("expected C [shape=box], got %s %v", node.NodeID.ID.Name, node.Attrs)
+	}
+}
+
+func TestParseFragmentErrors(t *testing.T) {
+	for _, input := range []string{`A -> B }`, `A -> `, `digraph { A }`} {
+		l := lexer.New("test", []byte(input))
+		p := New(l)
+		if _, err := p.ParseFragment(); err == nil {
+			t.Errorf("expected error for %q", input)
+		}
+	}
+}
+
+func TestParseAttributeListErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"doubled separator", `digraph { A [a=1,,b=2]; B }`, "1:18: expected attribute or ], got ,"},
+		{"lone separator", `digraph { node [,]; B }`, "1:17: expected attribute or ], got ,"},
+		{"missing key", `digraph { A [=1]; B }`, "1:14: expected attribute or ], got ="},
+		{"missing value", `digraph { A [a=]; B }`, "1:16: expected value after '='"},
+		{"unclosed", `digraph { A [a=1; B }`, "1:21: expected attribute or ], got }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := New(lexer.New("test", []byte(tt.input)))
+			p.Parse()
+
+			// Each case has one mistake, so one error and no knock-on
+			// errors from the rest of the list
+			if len(p.Errors) != 1 {
+				t.Fatalf("expected 1 error, got %v", p.Errors)
+			}
+			if got := p.Errors[0].Error(); !strings.HasSuffix(got, tt.want) {
+				t.Errorf("expected error %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseFileAdjacentGraphs(t *testing.T) {
+	input := `digraph A { x -> y; y -> z }graph B{a -- b}// after B
+strict digraph C {}`
+
+	l := lexer.New("test", []byte(input))
+	l.ScanComments = true
+	graphs, err := New(l).ParseFile()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(graphs) != 3 {
+		t.Fatalf("expected 3 graphs, got %d", len(graphs))
+	}
+
+	tests := []struct {
+		id       string
+		directed bool
+		strict   bool
+		stmts    int
+	}{
+		{"A", true, false, 2},
+		{"B", false, false, 1},
+		{"C", true, true, 0},
+	}
+	for i, tt := range tests {
+		g := graphs[i]
+		if g.ID == nil || g.ID.Name != tt.id {
+			t.Errorf("graph %d: expected ID %q, got %v", i, tt.id, g.ID)
+			continue
+		}
+		if g.Directed != tt.directed || g.Strict != tt.strict {
+			t.Errorf("graph %s: expected directed=%v strict=%v, got %v %v", tt.id, tt.directed, tt.strict, g.Directed, g.Strict)
+		}
+		if len(g.Statements) != tt.stmts {
+			t.Errorf("graph %s: expected %d statements, got %d", tt.id, tt.stmts, len(g.Statements))
+		}
+	}
+
+	// The comment after B's brace is on B's line, so it trails B
+	if len(graphs[1].Trailing) != 1 || len(graphs[2].Leading) != 0 {
+		t.Errorf("expected the comment to trail B, got %d trailing and %d leading C", len(graphs[1].Trailing), len(graphs[2].Leading))
+	}
+
+	if _, err := New(lexer.New("test", []byte(`digraph A {}}`))).ParseFile(); err == nil {
+		t.Error("expected an error for a stray brace after a graph")
+	}
+	if _, err := New(lexer.New("test", []byte(""))).ParseFile(); err == nil {
+		t.Error("expected an error for input with no graph")
+	}
+}
+
+func TestParseContext(t *testing.T) {
+	src := []byte(`digraph { A -> B; B -> C; C -> A }`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(lexer.New("test", src))
+	p.SetContext(ctx)
+	cancel()
+	if _, err := p.Parse(); err == nil || !strings.Contains(err.Error(), "context canceled") {
+		t.Errorf("expected parsing to stop with the context's error, got %v", err)
+	}
+
+	p = New(lexer.New("test", src))
+	p.SetContext(context.Background())
+	if g, err := p.Parse(); err != nil || len(g.Statements) != 3 {
+		t.Errorf("expected a live context not to affect parsing, got %v", err)
+	}
+}
@@ -101,10 +101,10 @@ func Lookup(ident string) Token {
 
 // Position represents a position in source code.
 type Position struct {
-	Filename string
-	Offset   int // byte offset
-	Line     int // 1-indexed line number
-	Column   int // 1-indexed column number
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"` // byte offset
+	Line     int    `json:"line"`   // 1-indexed line number
+	Column   int    `json:"column"` // 1-indexed column number
 }
 
 // String returns a string representation of the position.